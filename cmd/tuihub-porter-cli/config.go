@@ -0,0 +1,95 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	librarian "github.com/tuihub/protos/pkg/librarian/v1"
+
+	"google.golang.org/protobuf/encoding/protojson"
+	"gopkg.in/yaml.v3"
+)
+
+// featureFlags builds a librarian.FeatureRequest from command line flags.
+type featureFlags struct {
+	id         *string
+	region     *string
+	configFile *string
+	contextID  *int64
+}
+
+func (f *featureFlags) register(flags *flag.FlagSet) {
+	f.id = flags.String("id", "", "feature id, required")
+	f.region = flags.String("region", "", "feature region")
+	f.configFile = flags.String("config", "", "JSON or YAML file sent as config_json")
+	f.contextID = flags.Int64("context-id", 0, "context id, omitted when 0")
+}
+
+func (f *featureFlags) request() (*librarian.FeatureRequest, error) {
+	if *f.id == "" {
+		return nil, errors.New("-id is required")
+	}
+	req := &librarian.FeatureRequest{
+		Id:         *f.id,
+		Region:     *f.region,
+		ConfigJson: "",
+		ContextId:  nil,
+	}
+	if *f.configFile != "" {
+		b, err := loadJSON(*f.configFile)
+		if err != nil {
+			return nil, err
+		}
+		req.ConfigJson = string(b)
+	}
+	if *f.contextID != 0 {
+		req.ContextId = &librarian.InternalID{Id: *f.contextID}
+	}
+	return req, nil
+}
+
+// loadJSON reads a JSON or YAML file, chosen by extension, and returns it as JSON.
+func loadJSON(name string) ([]byte, error) {
+	b, err := os.ReadFile(name)
+	if err != nil {
+		return nil, err
+	}
+	switch strings.ToLower(filepath.Ext(name)) {
+	case ".yaml", ".yml":
+		var v interface{}
+		if err = yaml.Unmarshal(b, &v); err != nil {
+			return nil, fmt.Errorf("parse %s: %w", name, err)
+		}
+		return json.Marshal(v)
+	default:
+		if !json.Valid(b) {
+			return nil, fmt.Errorf("parse %s: invalid JSON", name)
+		}
+		return b, nil
+	}
+}
+
+func loadFeedItems(name string) ([]*librarian.FeedItem, error) {
+	b, err := loadJSON(name)
+	if err != nil {
+		return nil, err
+	}
+	var raws []json.RawMessage
+	if err = json.Unmarshal(b, &raws); err != nil {
+		return nil, fmt.Errorf("parse %s: expect a list of feed items: %w", name, err)
+	}
+	items := make([]*librarian.FeedItem, 0, len(raws))
+	for i, raw := range raws {
+		item := new(librarian.FeedItem)
+		if err = protojson.Unmarshal(raw, item); err != nil {
+			return nil, fmt.Errorf("parse %s: item %d: %w", name, i, err)
+		}
+		items = append(items, item)
+	}
+	return items, nil
+}
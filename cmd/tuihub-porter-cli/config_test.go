@@ -0,0 +1,104 @@
+package main
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+func writeFile(t *testing.T, name string, content string) string {
+	t.Helper()
+	p := filepath.Join(t.TempDir(), name)
+	if err := os.WriteFile(p, []byte(content), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	return p
+}
+
+func TestLoadJSON(t *testing.T) {
+	want := map[string]interface{}{
+		"url":  "https://example.com/feed",
+		"tags": []interface{}{"a", "b"},
+		"n":    float64(3),
+	}
+	tests := []struct {
+		name    string
+		file    string
+		content string
+		wantErr bool
+	}{
+		{"yaml", "config.yaml", "url: https://example.com/feed\ntags: [a, b]\nn: 3\n", false},
+		{"yml", "config.YML", "url: https://example.com/feed\ntags:\n  - a\n  - b\nn: 3\n", false},
+		{"json", "config.json", `{"url":"https://example.com/feed","tags":["a","b"],"n":3}`, false},
+		{"no extension is json", "config", `{"url":"https://example.com/feed","tags":["a","b"],"n":3}`, false},
+		{"invalid yaml", "config.yaml", "url: [\n", true},
+		{"invalid json", "config.json", `{"url":`, true},
+	}
+	for _, tt := range tests {
+		b, err := loadJSON(writeFile(t, tt.file, tt.content))
+		if (err != nil) != tt.wantErr {
+			t.Errorf("%s: error = %v, wantErr %v", tt.name, err, tt.wantErr)
+			continue
+		}
+		if tt.wantErr {
+			continue
+		}
+		var got map[string]interface{}
+		if err = json.Unmarshal(b, &got); err != nil {
+			t.Errorf("%s: %v", tt.name, err)
+			continue
+		}
+		if !reflect.DeepEqual(got, want) {
+			t.Errorf("%s: got %v, want %v", tt.name, got, want)
+		}
+	}
+	if _, err := loadJSON(filepath.Join(t.TempDir(), "missing.json")); err == nil {
+		t.Error("expected error for missing file")
+	}
+}
+
+func TestLoadFeedItems(t *testing.T) {
+	items, err := loadFeedItems(writeFile(t, "items.yaml", "- title: first\n  link: https://a\n- title: second\n"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(items) != 2 || items[0].GetTitle() != "first" || items[0].GetLink() != "https://a" ||
+		items[1].GetTitle() != "second" {
+		t.Errorf("items = %v", items)
+	}
+
+	tests := []struct {
+		name    string
+		content string
+	}{
+		{"not a list", `{"title":"x"}`},
+		{"unknown field", `[{"title":"ok"},{"no_such_field":1}]`},
+		{"wrong type", `[{"title":1}]`},
+	}
+	for _, tt := range tests {
+		if _, err = loadFeedItems(writeFile(t, "items.json", tt.content)); err == nil {
+			t.Errorf("%s: expected error", tt.name)
+		}
+	}
+}
+
+func TestFeatureFlagsRequest(t *testing.T) {
+	var f featureFlags
+	id, region, config, contextID := "", "", "", int64(0)
+	f.id, f.region, f.configFile, f.contextID = &id, &region, &config, &contextID
+	if _, err := f.request(); err == nil {
+		t.Error("expected error for empty id")
+	}
+	id = "rss"
+	config = writeFile(t, "c.yaml", "url: x\n")
+	contextID = 7
+	req, err := f.request()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if req.GetId() != "rss" || req.GetConfigJson() != `{"url":"x"}` || req.GetContextId().GetId() != 7 {
+		t.Errorf("request = %v", req)
+	}
+}
@@ -0,0 +1,286 @@
+// Command tuihub-porter-cli calls a porter directly, acting as a minimal Sephirah.
+//
+//	tuihub-porter-cli -addr 127.0.0.1:9000 info
+//	tuihub-porter-cli -addr 127.0.0.1:9000 pull-feed -id rss -config feed.yaml
+package main
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"os"
+	"text/tabwriter"
+	"time"
+
+	porter "github.com/tuihub/protos/pkg/librarian/porter/v1"
+	librarian "github.com/tuihub/protos/pkg/librarian/v1"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/credentials/insecure"
+	"google.golang.org/protobuf/encoding/protojson"
+	"google.golang.org/protobuf/proto"
+)
+
+const usage = `usage: tuihub-porter-cli [global flags] <command> [flags]
+
+commands:
+  info             call GetPorterInformation and print features
+  enable           call EnablePorter
+  pull-account     call PullAccount
+  pull-app-info    call PullAppInfo
+  search-app-info  call SearchAppInfo
+  pull-feed        call PullFeed
+  push-feed-items  call PushFeedItems
+
+global flags:
+`
+
+type command func(ctx context.Context, c porter.LibrarianPorterServiceClient, args []string) error
+
+var commands = map[string]command{ //nolint:gochecknoglobals // command table
+	"info":            runInfo,
+	"enable":          runEnable,
+	"pull-account":    runPullAccount,
+	"pull-app-info":   runPullAppInfo,
+	"search-app-info": runSearchAppInfo,
+	"pull-feed":       runPullFeed,
+	"push-feed-items": runPushFeedItems,
+}
+
+var (
+	sephirahID   int64  //nolint:gochecknoglobals // global flag
+	refreshToken string //nolint:gochecknoglobals // global flag
+	autoEnable   bool   //nolint:gochecknoglobals // global flag
+)
+
+func main() {
+	flags := flag.NewFlagSet("tuihub-porter-cli", flag.ExitOnError)
+	flags.Usage = func() {
+		fmt.Fprint(flags.Output(), usage)
+		flags.PrintDefaults()
+	}
+	addr := flags.String("addr", "127.0.0.1:9000", "porter gRPC address")
+	timeout := flags.Duration("timeout", time.Minute, "timeout of each call")
+	flags.Int64Var(&sephirahID, "sephirah-id", 1, "sephirah id used to enable the porter")
+	flags.StringVar(&refreshToken, "refresh-token", "", "refresh token sent with EnablePorter")
+	flags.BoolVar(&autoEnable, "enable", true, "send EnablePorter before calling other methods")
+	_ = flags.Parse(os.Args[1:])
+	if flags.NArg() == 0 {
+		flags.Usage()
+		os.Exit(2) //nolint:mnd // usage error
+	}
+	cmd, ok := commands[flags.Arg(0)]
+	if !ok {
+		fmt.Fprintf(os.Stderr, "unknown command %q\n", flags.Arg(0))
+		flags.Usage()
+		os.Exit(2) //nolint:mnd // usage error
+	}
+	if err := run(*addr, *timeout, cmd, flags.Args()[1:]); err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(1)
+	}
+}
+
+func run(addr string, timeout time.Duration, cmd command, args []string) error {
+	conn, err := grpc.NewClient(addr, grpc.WithTransportCredentials(insecure.NewCredentials()))
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+	ctx, cancel := context.WithTimeout(context.Background(), timeout)
+	defer cancel()
+	return cmd(ctx, porter.NewLibrarianPorterServiceClient(conn), args)
+}
+
+func enable(ctx context.Context, c porter.LibrarianPorterServiceClient) (*porter.EnablePorterResponse, error) {
+	req := &porter.EnablePorterRequest{
+		SephirahId:   sephirahID,
+		RefreshToken: nil,
+	}
+	if refreshToken != "" {
+		req.RefreshToken = &refreshToken
+	}
+	return c.EnablePorter(ctx, req)
+}
+
+func ensureEnabled(ctx context.Context, c porter.LibrarianPorterServiceClient) error {
+	if !autoEnable {
+		return nil
+	}
+	resp, err := enable(ctx, c)
+	if err != nil {
+		return fmt.Errorf("enable porter: %w", err)
+	}
+	if resp.GetNeedRefreshToken() {
+		fmt.Fprintln(os.Stderr, "warning: porter requires a refresh token, set -refresh-token")
+	}
+	return nil
+}
+
+func printJSON(m proto.Message) error {
+	b, err := protojson.MarshalOptions{Multiline: true, Indent: "  "}.Marshal(m) //nolint:exhaustruct // defaults
+	if err != nil {
+		return err
+	}
+	fmt.Println(string(b))
+	return nil
+}
+
+func runInfo(ctx context.Context, c porter.LibrarianPorterServiceClient, args []string) error {
+	flags := flag.NewFlagSet("info", flag.ExitOnError)
+	asJSON := flags.Bool("json", false, "print raw response as JSON")
+	_ = flags.Parse(args)
+	resp, err := c.GetPorterInformation(ctx, new(porter.GetPorterInformationRequest))
+	if err != nil {
+		return err
+	}
+	if *asJSON {
+		return printJSON(resp)
+	}
+	s := resp.GetBinarySummary()
+	fmt.Printf("%s %s (%s)\n", s.GetName(), s.GetVersion(), s.GetBuildVersion())
+	fmt.Printf("global name: %s\n", resp.GetGlobalName())
+	if resp.GetRegion() != "" {
+		fmt.Printf("region: %s\n", resp.GetRegion())
+	}
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0) //nolint:mnd // padding
+	fmt.Fprintln(w, "\nKIND\tID\tNAME\tCONFIG\tCONTEXT")
+	f := resp.GetFeatureSummary()
+	for _, group := range []struct {
+		kind  string
+		flags []*librarian.FeatureFlag
+	}{
+		{"account platform", f.GetAccountPlatforms()},
+		{"app info source", f.GetAppInfoSources()},
+		{"feed source", f.GetFeedSources()},
+		{"notify destination", f.GetNotifyDestinations()},
+		{"feed item action", f.GetFeedItemActions()},
+		{"feed setter", f.GetFeedSetters()},
+		{"feed getter", f.GetFeedGetters()},
+	} {
+		for _, feature := range group.flags {
+			fmt.Fprintf(w, "%s\t%s\t%s\t%t\t%t\n", group.kind, feature.GetId(), feature.GetName(),
+				feature.GetConfigJsonSchema() != "", feature.GetRequireContext())
+		}
+	}
+	return w.Flush()
+}
+
+func runEnable(ctx context.Context, c porter.LibrarianPorterServiceClient, args []string) error {
+	flags := flag.NewFlagSet("enable", flag.ExitOnError)
+	_ = flags.Parse(args)
+	resp, err := enable(ctx, c)
+	if err != nil {
+		return err
+	}
+	return printJSON(resp)
+}
+
+func runPullAccount(ctx context.Context, c porter.LibrarianPorterServiceClient, args []string) error {
+	flags := flag.NewFlagSet("pull-account", flag.ExitOnError)
+	platform := flags.String("platform", "", "account platform id")
+	id := flags.String("id", "", "platform account id")
+	_ = flags.Parse(args)
+	if err := ensureEnabled(ctx, c); err != nil {
+		return err
+	}
+	resp, err := c.PullAccount(ctx, &porter.PullAccountRequest{
+		AccountId: &librarian.AccountID{
+			Platform:          *platform,
+			PlatformAccountId: *id,
+		},
+	})
+	if err != nil {
+		return err
+	}
+	return printJSON(resp)
+}
+
+func runPullAppInfo(ctx context.Context, c porter.LibrarianPorterServiceClient, args []string) error {
+	flags := flag.NewFlagSet("pull-app-info", flag.ExitOnError)
+	source := flags.String("source", "", "app info source id")
+	id := flags.String("id", "", "source app id")
+	_ = flags.Parse(args)
+	if err := ensureEnabled(ctx, c); err != nil {
+		return err
+	}
+	resp, err := c.PullAppInfo(ctx, &porter.PullAppInfoRequest{
+		AppInfoId: &librarian.AppInfoID{
+			Internal:    false,
+			Source:      *source,
+			SourceAppId: *id,
+		},
+	})
+	if err != nil {
+		return err
+	}
+	return printJSON(resp)
+}
+
+func runSearchAppInfo(ctx context.Context, c porter.LibrarianPorterServiceClient, args []string) error {
+	flags := flag.NewFlagSet("search-app-info", flag.ExitOnError)
+	name := flags.String("name", "", "app name to search")
+	_ = flags.Parse(args)
+	if err := ensureEnabled(ctx, c); err != nil {
+		return err
+	}
+	resp, err := c.SearchAppInfo(ctx, &porter.SearchAppInfoRequest{
+		Name: *name,
+	})
+	if err != nil {
+		return err
+	}
+	return printJSON(resp)
+}
+
+func runPullFeed(ctx context.Context, c porter.LibrarianPorterServiceClient, args []string) error {
+	flags := flag.NewFlagSet("pull-feed", flag.ExitOnError)
+	var f featureFlags
+	f.register(flags)
+	_ = flags.Parse(args)
+	source, err := f.request()
+	if err != nil {
+		return err
+	}
+	if err = ensureEnabled(ctx, c); err != nil {
+		return err
+	}
+	resp, err := c.PullFeed(ctx, &porter.PullFeedRequest{
+		Source: source,
+	})
+	if err != nil {
+		return err
+	}
+	return printJSON(resp)
+}
+
+func runPushFeedItems(ctx context.Context, c porter.LibrarianPorterServiceClient, args []string) error {
+	flags := flag.NewFlagSet("push-feed-items", flag.ExitOnError)
+	var f featureFlags
+	f.register(flags)
+	itemsFile := flags.String("items", "", "JSON or YAML file with a list of feed items, required")
+	_ = flags.Parse(args)
+	destination, err := f.request()
+	if err != nil {
+		return err
+	}
+	if *itemsFile == "" {
+		return errors.New("-items is required")
+	}
+	items, err := loadFeedItems(*itemsFile)
+	if err != nil {
+		return err
+	}
+	if err = ensureEnabled(ctx, c); err != nil {
+		return err
+	}
+	resp, err := c.PushFeedItems(ctx, &porter.PushFeedItemsRequest{
+		Destination: destination,
+		Items:       items,
+	})
+	if err != nil {
+		return err
+	}
+	return printJSON(resp)
+}
@@ -0,0 +1,164 @@
+// Command tuihub-porter scaffolds new porter modules.
+//
+//	tuihub-porter init -module github.com/you/porter-example -features feed,notify ./porter-example
+package main
+
+import (
+	"embed"
+	"errors"
+	"flag"
+	"fmt"
+	"io/fs"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+	"text/template"
+)
+
+//go:embed all:template
+var templates embed.FS
+
+const templateDir = "template"
+
+type features struct {
+	Account bool
+	AppInfo bool
+	Feed    bool
+	Notify  bool
+}
+
+type project struct {
+	Module     string
+	Name       string
+	GlobalName string
+	GoVersion  string
+	Features   features
+}
+
+func main() {
+	if len(os.Args) < 2 || os.Args[1] != "init" { //nolint:mnd // command and sub command
+		fmt.Fprintln(os.Stderr, "usage: tuihub-porter init [flags] <dir>")
+		os.Exit(2) //nolint:mnd // usage error
+	}
+	if err := runInit(os.Args[2:]); err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(1)
+	}
+}
+
+func runInit(args []string) error {
+	flags := flag.NewFlagSet("init", flag.ContinueOnError)
+	module := flags.String("module", "", "module path of the new porter, required")
+	name := flags.String("name", "", "binary name, defaults to the last element of module")
+	globalName := flags.String("global-name", "", "porter global name, defaults to module")
+	featureList := flags.String("features", "feed",
+		"comma separated features: account, app-info, feed, notify")
+	force := flags.Bool("force", false, "overwrite existing files")
+	if err := flags.Parse(args); err != nil {
+		return err
+	}
+	if *module == "" {
+		return errors.New("-module is required")
+	}
+	dir := flags.Arg(0)
+	if dir == "" {
+		dir = path.Base(*module)
+	}
+	p := project{
+		Module:     *module,
+		Name:       *name,
+		GlobalName: *globalName,
+		GoVersion:  "1.21",
+		Features:   features{},
+	}
+	if p.Name == "" {
+		p.Name = path.Base(*module)
+	}
+	if p.GlobalName == "" {
+		p.GlobalName = *module
+	}
+	var err error
+	if p.Features, err = parseFeatures(*featureList); err != nil {
+		return err
+	}
+	if err = render(dir, p, *force); err != nil {
+		return err
+	}
+	fmt.Printf("porter created in %s, run `go mod tidy` there to fetch dependencies\n", dir)
+	return nil
+}
+
+func parseFeatures(s string) (features, error) {
+	var f features
+	for _, item := range strings.Split(s, ",") {
+		switch strings.TrimSpace(item) {
+		case "account":
+			f.Account = true
+		case "app-info":
+			f.AppInfo = true
+		case "feed":
+			f.Feed = true
+		case "notify":
+			f.Notify = true
+		case "":
+		default:
+			return f, fmt.Errorf("unknown feature %q", item)
+		}
+	}
+	if f == (features{}) {
+		return f, errors.New("at least one feature is required")
+	}
+	return f, nil
+}
+
+// render executes every embedded template into dir. Template names map to file names
+// by dropping the .tmpl suffix, a leading underscore becomes a dot.
+// Nothing is written if any target exists and force is false.
+func render(dir string, p project, force bool) error {
+	targets := make(map[string]string)
+	err := fs.WalkDir(templates, templateDir, func(name string, d fs.DirEntry, err error) error {
+		if err != nil || d.IsDir() {
+			return err
+		}
+		rel := strings.TrimSuffix(strings.TrimPrefix(name, templateDir+"/"), ".tmpl")
+		if strings.HasPrefix(rel, "_") {
+			rel = "." + strings.TrimPrefix(rel, "_")
+		}
+		target := filepath.Join(dir, filepath.FromSlash(rel))
+		if _, err = os.Stat(target); err == nil && !force {
+			return fmt.Errorf("%s already exists, use -force to overwrite", target)
+		}
+		targets[name] = target
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+	for name, target := range targets {
+		if err = renderFile(name, target, p); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func renderFile(name string, target string, p project) (err error) {
+	t, err := template.ParseFS(templates, name)
+	if err != nil {
+		return err
+	}
+	if err = os.MkdirAll(filepath.Dir(target), 0o755); err != nil { //nolint:mnd // dir permission
+		return err
+	}
+	f, err := os.Create(target)
+	if err != nil {
+		return err
+	}
+	defer func() {
+		if cerr := f.Close(); err == nil {
+			err = cerr
+		}
+	}()
+	return t.Execute(f, p)
+}
@@ -0,0 +1,99 @@
+package main
+
+import (
+	"go/parser"
+	"go/token"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+var featureNames = []string{"account", "app-info", "feed", "notify"} //nolint:gochecknoglobals // test table
+
+func TestRenderAllFeatureCombinations(t *testing.T) {
+	for mask := 1; mask < 1<<len(featureNames); mask++ {
+		var names []string
+		for i, name := range featureNames {
+			if mask&(1<<i) != 0 {
+				names = append(names, name)
+			}
+		}
+		list := strings.Join(names, ",")
+		t.Run(list, func(t *testing.T) {
+			f, err := parseFeatures(list)
+			if err != nil {
+				t.Fatal(err)
+			}
+			dir := t.TempDir()
+			p := project{
+				Module:     "example.com/porter-x",
+				Name:       "porter-x",
+				GlobalName: "example.com/porter-x",
+				GoVersion:  "1.21",
+				Features:   f,
+			}
+			if err = render(dir, p, false); err != nil {
+				t.Fatal(err)
+			}
+			for _, name := range []string{
+				"go.mod", ".gitignore", "Dockerfile", "Makefile",
+				"main.go", "config.go", "handler.go", "handler_test.go",
+			} {
+				if _, err = os.Stat(filepath.Join(dir, name)); err != nil {
+					t.Error(err)
+				}
+			}
+			fset := token.NewFileSet()
+			goFiles, _ := filepath.Glob(filepath.Join(dir, "*.go"))
+			for _, file := range goFiles {
+				if _, err = parser.ParseFile(fset, file, nil, parser.AllErrors); err != nil {
+					t.Error(err)
+				}
+			}
+		})
+	}
+}
+
+func TestRenderExistingTarget(t *testing.T) {
+	dir := t.TempDir()
+	if err := os.WriteFile(filepath.Join(dir, "handler.go"), []byte("package main\n"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	p := project{
+		Module:     "example.com/porter-x",
+		Name:       "porter-x",
+		GlobalName: "example.com/porter-x",
+		GoVersion:  "1.21",
+		Features:   features{Feed: true},
+	}
+	if err := render(dir, p, false); err == nil {
+		t.Fatal("expected error for existing handler.go")
+	}
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(entries) != 1 {
+		t.Errorf("directory half generated: %d entries", len(entries))
+	}
+	if err = render(dir, p, true); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestParseFeatures(t *testing.T) {
+	if _, err := parseFeatures(""); err == nil {
+		t.Error("expected error for no features")
+	}
+	if _, err := parseFeatures("feed,unknown"); err == nil {
+		t.Error("expected error for unknown feature")
+	}
+	f, err := parseFeatures(" feed , notify ")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if f != (features{Feed: true, Notify: true}) {
+		t.Errorf("features = %+v", f)
+	}
+}
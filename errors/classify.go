@@ -0,0 +1,79 @@
+package errors
+
+import (
+	"strconv"
+	"time"
+
+	"google.golang.org/grpc/codes"
+)
+
+// IsUnauthenticated reports whether the token used to call Sephirah is missing or expired.
+func IsUnauthenticated(err error) bool {
+	return err != nil && Code(err) == codes.Unauthenticated
+}
+
+// IsPermissionDenied reports whether the caller is not allowed to perform the call.
+func IsPermissionDenied(err error) bool {
+	return err != nil && Code(err) == codes.PermissionDenied
+}
+
+// IsNotFound reports whether the requested resource does not exist.
+func IsNotFound(err error) bool {
+	return err != nil && Code(err) == codes.NotFound
+}
+
+// IsUnimplemented reports whether the called method is not implemented by the server.
+func IsUnimplemented(err error) bool {
+	return err != nil && Code(err) == codes.Unimplemented
+}
+
+// IsInvalidArgument reports whether the request was rejected as malformed.
+func IsInvalidArgument(err error) bool {
+	return err != nil && Code(err) == codes.InvalidArgument
+}
+
+// IsConfigInvalid reports whether err was created by ConfigInvalid.
+func IsConfigInvalid(err error) bool {
+	return Reason(err) == ReasonConfigInvalid
+}
+
+// IsAccountPrivate reports whether err was created by AccountPrivate.
+func IsAccountPrivate(err error) bool {
+	return Reason(err) == ReasonAccountPrivate
+}
+
+// IsRateLimited reports whether err was created by RateLimited or has the ResourceExhausted code.
+func IsRateLimited(err error) bool {
+	return err != nil && (Reason(err) == ReasonRateLimited || Code(err) == codes.ResourceExhausted)
+}
+
+// IsJobPending reports whether err was created by JobPending.
+func IsJobPending(err error) bool {
+	return Reason(err) == ReasonJobPending
+}
+
+// IsRetryable reports whether the same call may succeed later.
+func IsRetryable(err error) bool {
+	if err == nil {
+		return false
+	}
+	switch Code(err) { //nolint:exhaustive // other codes are not retryable
+	case codes.Unavailable, codes.ResourceExhausted, codes.Aborted, codes.DeadlineExceeded:
+		return true
+	default:
+		return false
+	}
+}
+
+// RetryAfter returns the delay attached by RateLimited.
+func RetryAfter(err error) (time.Duration, bool) {
+	se := FromError(err)
+	if se == nil {
+		return 0, false
+	}
+	seconds, parseErr := strconv.ParseInt(se.GetMetadata()[MetadataRetryAfter], 10, 64)
+	if parseErr != nil || seconds <= 0 {
+		return 0, false
+	}
+	return time.Duration(seconds) * time.Second, true
+}
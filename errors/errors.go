@@ -0,0 +1,135 @@
+// Package errors defines the errors porter handlers return to Sephirah and helpers to classify
+// errors returned by Sephirah.
+//
+// Every error carries a reason code and metadata, and maps to a fixed gRPC status code.
+package errors
+
+import (
+	"context"
+	"net/http"
+	"strconv"
+	"time"
+
+	kerrors "github.com/go-kratos/kratos/v2/errors"
+	"google.golang.org/grpc/codes"
+)
+
+// Error is a kratos error, it is converted to a gRPC status with the reason and metadata as ErrorInfo.
+type Error = kerrors.Error
+
+const (
+	ReasonNotEnabled          = "PORTER_NOT_ENABLED"
+	ReasonInvalidArgument     = "INVALID_ARGUMENT"
+	ReasonUnsupported         = "UNSUPPORTED"
+	ReasonConfigInvalid       = "CONFIG_INVALID"
+	ReasonNotFound            = "NOT_FOUND"
+	ReasonAccountPrivate      = "ACCOUNT_PRIVATE"
+	ReasonRateLimited         = "UPSTREAM_RATE_LIMITED"
+	ReasonUpstreamUnavailable = "UPSTREAM_UNAVAILABLE"
+	ReasonInternal            = "INTERNAL"
+	ReasonJobPending          = "JOB_PENDING"
+)
+
+const (
+	// MetadataRetryAfter is the metadata key of the seconds to wait before retrying a rate limited request.
+	MetadataRetryAfter = "retry_after"
+	// MetadataJobID is the metadata key of the job a JobPending error refers to.
+	MetadataJobID = "job_id"
+)
+
+// NotEnabled reports the porter is not enabled by the caller. Maps to PermissionDenied.
+func NotEnabled(format string, a ...interface{}) *Error {
+	return kerrors.Newf(http.StatusForbidden, ReasonNotEnabled, format, a...)
+}
+
+// InvalidArgument reports a malformed request. Maps to InvalidArgument.
+func InvalidArgument(format string, a ...interface{}) *Error {
+	return kerrors.Newf(http.StatusBadRequest, ReasonInvalidArgument, format, a...)
+}
+
+// Unsupported reports a request for a platform, source or destination the porter does not provide.
+// Maps to InvalidArgument.
+func Unsupported(format string, a ...interface{}) *Error {
+	return kerrors.Newf(http.StatusBadRequest, ReasonUnsupported, format, a...)
+}
+
+// ConfigInvalid reports a feature config that fails validation. Maps to InvalidArgument.
+func ConfigInvalid(format string, a ...interface{}) *Error {
+	return kerrors.Newf(http.StatusBadRequest, ReasonConfigInvalid, format, a...)
+}
+
+// NotFound reports the requested account, app or feed does not exist upstream. Maps to NotFound.
+func NotFound(format string, a ...interface{}) *Error {
+	return kerrors.Newf(http.StatusNotFound, ReasonNotFound, format, a...)
+}
+
+// AccountPrivate reports the upstream account hides the requested data. Maps to PermissionDenied.
+func AccountPrivate(format string, a ...interface{}) *Error {
+	return kerrors.Newf(http.StatusForbidden, ReasonAccountPrivate, format, a...)
+}
+
+// RateLimited reports the upstream rejected the request for exceeding its rate limit.
+// A positive retryAfter is attached as MetadataRetryAfter. Maps to ResourceExhausted.
+func RateLimited(retryAfter time.Duration, format string, a ...interface{}) *Error {
+	e := kerrors.Newf(http.StatusTooManyRequests, ReasonRateLimited, format, a...)
+	if retryAfter > 0 {
+		e = e.WithMetadata(map[string]string{
+			MetadataRetryAfter: strconv.FormatInt(int64(retryAfter.Round(time.Second)/time.Second), 10),
+		})
+	}
+	return e
+}
+
+// UpstreamUnavailable reports the upstream service can not be reached. Maps to Unavailable.
+func UpstreamUnavailable(format string, a ...interface{}) *Error {
+	return kerrors.Newf(http.StatusServiceUnavailable, ReasonUpstreamUnavailable, format, a...)
+}
+
+// JobPending reports the request is served by a job that has not finished yet, the same request
+// returns its result once it has. Maps to Unavailable so callers retry it.
+func JobPending(jobID string, retryAfter time.Duration, format string, a ...interface{}) *Error {
+	metadata := map[string]string{MetadataJobID: jobID}
+	if retryAfter > 0 {
+		metadata[MetadataRetryAfter] = strconv.FormatInt(int64(retryAfter.Round(time.Second)/time.Second), 10)
+	}
+	return kerrors.Newf(http.StatusServiceUnavailable, ReasonJobPending, format, a...).WithMetadata(metadata)
+}
+
+// Internal reports a bug or unexpected failure in the porter. Maps to Internal.
+func Internal(format string, a ...interface{}) *Error {
+	return kerrors.Newf(http.StatusInternalServerError, ReasonInternal, format, a...)
+}
+
+// FromError converts err to an Error, reading reason and metadata from gRPC statuses
+// and mapping context errors to their gRPC codes. It returns nil for a nil err.
+func FromError(err error) *Error {
+	if err == nil {
+		return nil
+	}
+	if se := new(Error); kerrors.As(err, &se) {
+		return se
+	}
+	switch {
+	case kerrors.Is(err, context.DeadlineExceeded):
+		return kerrors.New(http.StatusGatewayTimeout, kerrors.UnknownReason, err.Error()).WithCause(err)
+	case kerrors.Is(err, context.Canceled):
+		return kerrors.New(499, kerrors.UnknownReason, err.Error()).WithCause(err) //nolint:mnd // client closed request
+	}
+	return kerrors.FromError(err)
+}
+
+// Code returns the gRPC code of err, OK for nil.
+func Code(err error) codes.Code {
+	if err == nil {
+		return codes.OK
+	}
+	return FromError(err).GRPCStatus().Code()
+}
+
+// Reason returns the reason of err, empty if it has none.
+func Reason(err error) string {
+	if se := FromError(err); se != nil && se.Reason != kerrors.UnknownReason {
+		return se.Reason
+	}
+	return ""
+}
@@ -0,0 +1,94 @@
+package errors
+
+import (
+	"context"
+	"fmt"
+	"testing"
+	"time"
+
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// overWire simulates an error received from a gRPC call.
+func overWire(err error) error {
+	return status.ErrorProto(FromError(err).GRPCStatus().Proto())
+}
+
+func TestCode(t *testing.T) {
+	tests := []struct {
+		err  error
+		want codes.Code
+	}{
+		{nil, codes.OK},
+		{NotEnabled("a"), codes.PermissionDenied},
+		{InvalidArgument("a"), codes.InvalidArgument},
+		{Unsupported("a"), codes.InvalidArgument},
+		{ConfigInvalid("a"), codes.InvalidArgument},
+		{NotFound("a"), codes.NotFound},
+		{AccountPrivate("a"), codes.PermissionDenied},
+		{RateLimited(0, "a"), codes.ResourceExhausted},
+		{UpstreamUnavailable("a"), codes.Unavailable},
+		{Internal("a"), codes.Internal},
+		{JobPending("1", 0, "a"), codes.Unavailable},
+		{context.DeadlineExceeded, codes.DeadlineExceeded},
+		{fmt.Errorf("wrapped: %w", context.Canceled), codes.Canceled},
+		{status.Error(codes.Unauthenticated, "a"), codes.Unauthenticated},
+		{fmt.Errorf("plain"), codes.Internal},
+	}
+	for _, tt := range tests {
+		if got := Code(tt.err); got != tt.want {
+			t.Errorf("Code(%v) = %v, want %v", tt.err, got, tt.want)
+		}
+		if tt.err != nil {
+			if got := Code(overWire(tt.err)); got != tt.want {
+				t.Errorf("Code(overWire(%v)) = %v, want %v", tt.err, got, tt.want)
+			}
+		}
+	}
+}
+
+func TestClassify(t *testing.T) {
+	err := overWire(RateLimited(90*time.Second, "slow down %s", "please"))
+	if !IsRateLimited(err) || !IsRetryable(err) {
+		t.Errorf("rate limited error not classified: %v", err)
+	}
+	if d, ok := RetryAfter(err); !ok || d != 90*time.Second {
+		t.Errorf("RetryAfter = %v, %v", d, ok)
+	}
+	if se := FromError(err); se.Message != "slow down please" {
+		t.Errorf("message = %q", se.Message)
+	}
+	if _, ok := RetryAfter(RateLimited(0, "a")); ok {
+		t.Error("RetryAfter without delay")
+	}
+
+	if err = overWire(AccountPrivate("a")); !IsAccountPrivate(err) || !IsPermissionDenied(err) || IsRetryable(err) {
+		t.Errorf("account private error not classified: %v", err)
+	}
+	if err = overWire(ConfigInvalid("a")); !IsConfigInvalid(err) || !IsInvalidArgument(err) {
+		t.Errorf("config invalid error not classified: %v", err)
+	}
+	if err = status.Error(codes.Unauthenticated, "token expired"); !IsUnauthenticated(err) || Reason(err) != "" {
+		t.Errorf("unauthenticated error not classified: %v", err)
+	}
+	if !IsUnimplemented(status.Error(codes.Unimplemented, "a")) || IsUnimplemented(nil) {
+		t.Error("unimplemented error not classified")
+	}
+	if !IsNotFound(overWire(NotFound("a"))) {
+		t.Error("not found error not classified")
+	}
+	if !IsRetryable(UpstreamUnavailable("a")) || IsRetryable(nil) || IsRetryable(Internal("a")) {
+		t.Error("retryable errors not classified")
+	}
+	err = overWire(JobPending("42", 10*time.Second, "a"))
+	if !IsJobPending(err) || !IsRetryable(err) || FromError(err).GetMetadata()[MetadataJobID] != "42" {
+		t.Errorf("job pending error not classified: %v", err)
+	}
+	if d, ok := RetryAfter(err); !ok || d != 10*time.Second {
+		t.Errorf("job pending RetryAfter = %v, %v", d, ok)
+	}
+	if Reason(NotEnabled("a")) != ReasonNotEnabled || Reason(nil) != "" {
+		t.Error("reason not kept")
+	}
+}
@@ -0,0 +1,219 @@
+// Package feeds parses RSS 2.0, Atom 1.0 and JSON Feed documents into librarian feed messages
+// for PullFeed handlers.
+package feeds
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	librarian "github.com/tuihub/protos/pkg/librarian/v1"
+
+	"github.com/mmcdole/gofeed"
+	"google.golang.org/protobuf/types/known/timestamppb"
+)
+
+// MaxFeedSize is the largest document Parse reads.
+const MaxFeedSize = 16 << 20
+
+// Parse detects the format of a feed document and converts it. Common mistakes such as
+// unescaped ampersands and control characters in XML feeds are repaired before giving up.
+func Parse(r io.Reader) (*librarian.Feed, error) {
+	b, err := io.ReadAll(io.LimitReader(r, MaxFeedSize+1))
+	if err != nil {
+		return nil, err
+	}
+	if len(b) > MaxFeedSize {
+		return nil, fmt.Errorf("feed larger than %d bytes", MaxFeedSize)
+	}
+	f, err := gofeed.NewParser().Parse(bytes.NewReader(b))
+	if err != nil {
+		repaired := repairXML(b)
+		if bytes.Equal(repaired, b) {
+			return nil, err
+		}
+		var rerr error
+		if f, rerr = gofeed.NewParser().Parse(bytes.NewReader(repaired)); rerr != nil {
+			return nil, err
+		}
+	}
+	return convertFeed(f), nil
+}
+
+// ParseItems returns the items of a feed document, to be used with feedsync.Tracker.Fetch.
+func ParseItems(r io.Reader) ([]*librarian.FeedItem, error) {
+	f, err := Parse(r)
+	if err != nil {
+		return nil, err
+	}
+	return f.GetItems(), nil
+}
+
+// Fetch downloads and parses the feed at feedURL with client, http.DefaultClient if nil.
+func Fetch(ctx context.Context, client *http.Client, feedURL string) (*librarian.Feed, error) {
+	if client == nil {
+		client = http.DefaultClient
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, feedURL, nil)
+	if err != nil {
+		return nil, err
+	}
+	resp, err := client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		return nil, fmt.Errorf("fetch %s: unexpected status %s", feedURL, resp.Status)
+	}
+	f, err := Parse(resp.Body)
+	if err != nil {
+		return nil, err
+	}
+	if f.GetLink() == "" {
+		// resolve relative item links against the document itself, the site link stays unknown
+		resolveItemURLs(f, feedURL)
+	}
+	return f, nil
+}
+
+func convertFeed(f *gofeed.Feed) *librarian.Feed {
+	feed := &librarian.Feed{
+		Id:          nil,
+		Title:       strings.TrimSpace(f.Title),
+		Link:        strings.TrimSpace(f.Link),
+		Description: strings.TrimSpace(f.Description),
+		Items:       make([]*librarian.FeedItem, 0, len(f.Items)),
+		Language:    strings.TrimSpace(f.Language),
+		Image:       convertImage(f.Image),
+		Authors:     convertPersons(f.Authors),
+	}
+	for _, item := range f.Items {
+		if item != nil {
+			feed.Items = append(feed.Items, convertItem(item))
+		}
+	}
+	resolveItemURLs(feed, feed.GetLink())
+	return feed
+}
+
+func convertItem(item *gofeed.Item) *librarian.FeedItem {
+	res := &librarian.FeedItem{
+		Id:              nil,
+		Title:           strings.TrimSpace(item.Title),
+		Authors:         convertPersons(item.Authors),
+		Description:     item.Description,
+		Content:         item.Content,
+		Guid:            strings.TrimSpace(item.GUID),
+		Link:            strings.TrimSpace(item.Link),
+		Image:           convertImage(item.Image),
+		Published:       item.Published,
+		PublishedParsed: convertTime(item.PublishedParsed),
+		Updated:         item.Updated,
+		UpdatedParsed:   convertTime(item.UpdatedParsed),
+		Enclosures:      nil,
+		PublishPlatform: "",
+		ReadCount:       0,
+	}
+	if res.GetLink() == "" && len(item.Links) > 0 {
+		res.Link = strings.TrimSpace(item.Links[0])
+	}
+	if res.GetGuid() == "" {
+		res.Guid = res.GetLink()
+	}
+	// feeds often set only one of the dates
+	if res.GetPublishedParsed() == nil && res.GetUpdatedParsed() != nil {
+		res.Published = res.GetUpdated()
+		res.PublishedParsed = res.GetUpdatedParsed()
+	}
+	if res.GetUpdatedParsed() == nil && res.GetPublishedParsed() != nil {
+		res.Updated = res.GetPublished()
+		res.UpdatedParsed = res.GetPublishedParsed()
+	}
+	for _, e := range item.Enclosures {
+		if e == nil || strings.TrimSpace(e.URL) == "" {
+			continue
+		}
+		length := strings.TrimSpace(e.Length)
+		if length == "0" {
+			// unknown length
+			length = ""
+		}
+		res.Enclosures = append(res.Enclosures, &librarian.FeedEnclosure{
+			Url:    strings.TrimSpace(e.URL),
+			Length: length,
+			Type:   strings.TrimSpace(e.Type),
+		})
+		if res.GetImage() == nil && strings.HasPrefix(e.Type, "image/") {
+			res.Image = &librarian.FeedImage{Url: strings.TrimSpace(e.URL), Title: ""}
+		}
+	}
+	return res
+}
+
+func convertPersons(persons []*gofeed.Person) []*librarian.FeedPerson {
+	var res []*librarian.FeedPerson
+	for _, p := range persons {
+		if p == nil {
+			continue
+		}
+		name, email := strings.TrimSpace(p.Name), strings.TrimSpace(p.Email)
+		if name == "" && email == "" {
+			continue
+		}
+		if name == "" {
+			name = email
+		}
+		res = append(res, &librarian.FeedPerson{Name: name, Email: email})
+	}
+	return res
+}
+
+func convertImage(image *gofeed.Image) *librarian.FeedImage {
+	if image == nil || strings.TrimSpace(image.URL) == "" {
+		return nil
+	}
+	return &librarian.FeedImage{
+		Url:   strings.TrimSpace(image.URL),
+		Title: strings.TrimSpace(image.Title),
+	}
+}
+
+func convertTime(t *time.Time) *timestamppb.Timestamp {
+	if t == nil || t.IsZero() {
+		return nil
+	}
+	return timestamppb.New(t.UTC())
+}
+
+// resolveItemURLs makes relative URLs in items absolute against baseURL.
+func resolveItemURLs(feed *librarian.Feed, baseURL string) {
+	base, err := url.Parse(baseURL)
+	if err != nil || !base.IsAbs() {
+		return
+	}
+	resolve := func(s string) string {
+		u, uerr := url.Parse(s)
+		if s == "" || uerr != nil || u.IsAbs() {
+			return s
+		}
+		return base.ResolveReference(u).String()
+	}
+	if feed.GetImage() != nil {
+		feed.Image.Url = resolve(feed.GetImage().GetUrl())
+	}
+	for _, item := range feed.GetItems() {
+		item.Link = resolve(item.GetLink())
+		if item.GetImage() != nil {
+			item.Image.Url = resolve(item.GetImage().GetUrl())
+		}
+		for _, e := range item.GetEnclosures() {
+			e.Url = resolve(e.GetUrl())
+		}
+	}
+}
@@ -0,0 +1,157 @@
+package feeds
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	librarian "github.com/tuihub/protos/pkg/librarian/v1"
+)
+
+func parseFixture(t *testing.T, name string) *librarian.Feed {
+	t.Helper()
+	f, err := os.Open(filepath.Join("testdata", name))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+	feed, err := Parse(f)
+	if err != nil {
+		t.Fatalf("Parse(%s) error = %v", name, err)
+	}
+	return feed
+}
+
+func TestParseRSS(t *testing.T) {
+	feed := parseFixture(t, "rss.xml")
+	if feed.GetTitle() != "Example RSS" || feed.GetLanguage() != "en-us" ||
+		feed.GetImage().GetUrl() != "https://example.com/logo.png" {
+		t.Errorf("feed = %v", feed)
+	}
+	if len(feed.GetItems()) != 2 {
+		t.Fatalf("items = %d", len(feed.GetItems()))
+	}
+	first, second := feed.GetItems()[0], feed.GetItems()[1]
+	if first.GetLink() != "https://example.com/blog/posts/1" || first.GetGuid() != "post-1" {
+		t.Errorf("first link = %q, guid = %q", first.GetLink(), first.GetGuid())
+	}
+	if len(first.GetAuthors()) != 1 || first.GetAuthors()[0].GetName() != "Alice" ||
+		first.GetAuthors()[0].GetEmail() != "alice@example.com" {
+		t.Errorf("first authors = %v", first.GetAuthors())
+	}
+	want := time.Date(2006, 1, 2, 22, 4, 5, 0, time.UTC)
+	if !first.GetPublishedParsed().AsTime().Equal(want) || !first.GetUpdatedParsed().AsTime().Equal(want) {
+		t.Errorf("first published = %v, updated = %v", first.GetPublishedParsed(), first.GetUpdatedParsed())
+	}
+	if first.GetImage().GetUrl() != "https://example.com/cover.jpg" {
+		t.Errorf("first image = %v", first.GetImage())
+	}
+	// no guid, falls back to the link
+	if second.GetGuid() != "https://example.com/blog/posts/2" {
+		t.Errorf("second guid = %q", second.GetGuid())
+	}
+	if len(second.GetAuthors()) != 1 || second.GetAuthors()[0].GetName() != "Bob" {
+		t.Errorf("second authors = %v", second.GetAuthors())
+	}
+	if len(second.GetEnclosures()) != 1 || second.GetEnclosures()[0].GetUrl() != "https://example.com/audio/2.mp3" ||
+		second.GetEnclosures()[0].GetType() != "audio/mpeg" {
+		t.Errorf("second enclosures = %v", second.GetEnclosures())
+	}
+	if second.GetImage() != nil {
+		t.Errorf("audio enclosure used as image: %v", second.GetImage())
+	}
+}
+
+func TestParseAtom(t *testing.T) {
+	feed := parseFixture(t, "atom.xml")
+	if feed.GetTitle() != "Example Atom" || len(feed.GetAuthors()) != 1 ||
+		feed.GetAuthors()[0].GetEmail() != "carol@example.org" {
+		t.Errorf("feed = %v", feed)
+	}
+	item := feed.GetItems()[0]
+	if item.GetLink() != "https://example.org/2006/01/02/entry" ||
+		item.GetGuid() != "urn:uuid:1225c695-cfb8-4ebb-aaaa-80da344efa6a" {
+		t.Errorf("item link = %q, guid = %q", item.GetLink(), item.GetGuid())
+	}
+	if item.GetContent() != "<p>Full text</p>" || item.GetDescription() != "Some text." {
+		t.Errorf("item content = %q, description = %q", item.GetContent(), item.GetDescription())
+	}
+	// only updated is set
+	want := time.Date(2006, 1, 2, 7, 4, 5, 0, time.UTC)
+	if !item.GetPublishedParsed().AsTime().Equal(want) {
+		t.Errorf("item published = %v", item.GetPublishedParsed())
+	}
+}
+
+func TestParseJSONFeed(t *testing.T) {
+	feed := parseFixture(t, "feed.json")
+	if feed.GetTitle() != "Example JSON Feed" || feed.GetLink() != "https://example.net/" {
+		t.Errorf("feed = %v", feed)
+	}
+	item := feed.GetItems()[0]
+	if item.GetGuid() != "json-1" || item.GetContent() != "<p>Hello</p>" ||
+		item.GetImage().GetUrl() != "https://example.net/1.png" {
+		t.Errorf("item = %v", item)
+	}
+	if len(item.GetAuthors()) != 1 || item.GetAuthors()[0].GetName() != "Erin" {
+		t.Errorf("item authors = %v", item.GetAuthors())
+	}
+	if len(item.GetEnclosures()) != 1 || item.GetEnclosures()[0].GetType() != "audio/mpeg" {
+		t.Errorf("item enclosures = %v", item.GetEnclosures())
+	}
+}
+
+func TestParseMalformed(t *testing.T) {
+	feed := parseFixture(t, "malformed.xml")
+	if feed.GetTitle() != "Tom & Jerry" {
+		t.Errorf("title = %q", feed.GetTitle())
+	}
+	item := feed.GetItems()[0]
+	if item.GetTitle() != "Cats & mice & dogs" || item.GetLink() != "https://example.com/a?x=1&y=2" {
+		t.Errorf("item title = %q, link = %q", item.GetTitle(), item.GetLink())
+	}
+	if item.GetPublished() != "not a date" || item.GetPublishedParsed() != nil {
+		t.Errorf("item published = %q, %v", item.GetPublished(), item.GetPublishedParsed())
+	}
+
+	if _, err := Parse(strings.NewReader("not a feed")); err == nil {
+		t.Error("Parse(not a feed) succeeded")
+	}
+}
+
+func TestFetch(t *testing.T) {
+	b, err := os.ReadFile(filepath.Join("testdata", "feed.json"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/feed.json":
+			_, _ = w.Write(b)
+		case "/nolink.xml":
+			_, _ = w.Write([]byte(`<rss version="2.0"><channel><title>t</title><item><link>/a</link></item></channel></rss>`))
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+	defer srv.Close()
+	feed, err := Fetch(context.Background(), srv.Client(), srv.URL+"/feed.json")
+	if err != nil || len(feed.GetItems()) != 1 {
+		t.Errorf("Fetch = %v, %v", feed, err)
+	}
+	feed, err = Fetch(context.Background(), srv.Client(), srv.URL+"/nolink.xml")
+	if err != nil || len(feed.GetItems()) != 1 {
+		t.Fatalf("Fetch without link = %v, %v", feed, err)
+	}
+	if feed.GetLink() != "" || feed.GetItems()[0].GetLink() != srv.URL+"/a" {
+		t.Errorf("link = %q, item link = %q", feed.GetLink(), feed.GetItems()[0].GetLink())
+	}
+	if _, err = Fetch(context.Background(), srv.Client(), srv.URL+"/missing"); err == nil {
+		t.Error("Fetch of missing feed succeeded")
+	}
+}
@@ -0,0 +1,87 @@
+package feeds
+
+import (
+	"bytes"
+)
+
+var (
+	cdataStart = []byte("<![CDATA[")
+	cdataEnd   = []byte("]]>")
+)
+
+// repairXML escapes ampersands that do not start an entity and drops control characters
+// that are not allowed in XML. Ampersands in CDATA sections are kept. JSON documents are returned unchanged.
+func repairXML(b []byte) []byte {
+	trimmed := bytes.TrimLeft(bytes.TrimPrefix(b, []byte("\xef\xbb\xbf")), " \t\r\n")
+	if len(trimmed) == 0 || trimmed[0] != '<' {
+		return b
+	}
+	var buf bytes.Buffer
+	buf.Grow(len(trimmed))
+	for i := 0; i < len(trimmed); i++ {
+		c := trimmed[i]
+		if c == '<' && bytes.HasPrefix(trimmed[i:], cdataStart) {
+			end := bytes.Index(trimmed[i:], cdataEnd)
+			if end < 0 {
+				end = len(trimmed) - i
+			} else {
+				end += len(cdataEnd)
+			}
+			for _, cc := range trimmed[i : i+end] {
+				if !isControl(cc) {
+					buf.WriteByte(cc)
+				}
+			}
+			i += end - 1
+			continue
+		}
+		switch {
+		case c == '&' && !isEntity(trimmed[i+1:]):
+			buf.WriteString("&amp;")
+		case isControl(c):
+		default:
+			buf.WriteByte(c)
+		}
+	}
+	return buf.Bytes()
+}
+
+// isControl reports whether c is a control character not allowed in XML.
+func isControl(c byte) bool {
+	return c < 0x20 && c != '\t' && c != '\n' && c != '\r'
+}
+
+// isEntity reports whether b, following an ampersand, is a named or numeric entity reference.
+func isEntity(b []byte) bool {
+	end := bytes.IndexByte(b, ';')
+	if end <= 0 || end > 32 { //nolint:mnd // longer names are not entities
+		return false
+	}
+	name := b[:end]
+	if name[0] == '#' {
+		digits := name[1:]
+		hex := len(digits) > 0 && (digits[0] == 'x' || digits[0] == 'X')
+		if hex {
+			digits = digits[1:]
+		}
+		if len(digits) == 0 {
+			return false
+		}
+		for _, c := range digits {
+			if !isDigit(c) && !(hex && isHexLetter(c)) {
+				return false
+			}
+		}
+		return true
+	}
+	for i, c := range name {
+		if !isLetter(c) && (i == 0 || !isDigit(c)) {
+			return false
+		}
+	}
+	return true
+}
+
+func isDigit(c byte) bool     { return c >= '0' && c <= '9' }
+func isLetter(c byte) bool    { return (c >= 'a' && c <= 'z') || (c >= 'A' && c <= 'Z') }
+func isHexLetter(c byte) bool { return (c >= 'a' && c <= 'f') || (c >= 'A' && c <= 'F') }
@@ -0,0 +1,22 @@
+package feeds
+
+import "testing"
+
+func TestRepairXML(t *testing.T) {
+	tests := []struct {
+		in, want string
+	}{
+		{"<a>Tom & Jerry</a>", "<a>Tom &amp; Jerry</a>"},
+		{"<a>&amp;&lt;&#38;&#x26;</a>", "<a>&amp;&lt;&#38;&#x26;</a>"},
+		{"<a>&#xZZ; &;</a>", "<a>&amp;#xZZ; &amp;;</a>"},
+		{"<a>x\x0by\tz</a>", "<a>xy\tz</a>"},
+		{"\xef\xbb\xbf <a/>", "<a/>"},
+		{`{"a":"&"}`, `{"a":"&"}`},
+		{"<a><![CDATA[Tom & Jerry]]> & <![CDATA[&x]]></a>", "<a><![CDATA[Tom & Jerry]]> &amp; <![CDATA[&x]]></a>"},
+	}
+	for _, tt := range tests {
+		if got := string(repairXML([]byte(tt.in))); got != tt.want {
+			t.Errorf("repairXML(%q) = %q, want %q", tt.in, got, tt.want)
+		}
+	}
+}
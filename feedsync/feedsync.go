@@ -0,0 +1,252 @@
+// Package feedsync lets PullFeed handlers return only new or changed feed items.
+//
+// A Tracker remembers the ids and content hashes of items it has returned for each feed source and config,
+// and the ETag and Last-Modified validators of the upstream feed, in a pluggable Store. Items are
+// remembered when their pull is committed, after the PullFeed reply has been sent.
+package feedsync
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"net/http"
+	"sync"
+
+	librarian "github.com/tuihub/protos/pkg/librarian/v1"
+	"google.golang.org/protobuf/proto"
+)
+
+const defaultWindow = 1000
+
+// Seen is an item returned before.
+type Seen struct {
+	ID   string `json:"id"`
+	Hash string `json:"hash"`
+}
+
+// State is what a Tracker remembers about one feed.
+type State struct {
+	ETag         string `json:"etag,omitempty"`
+	LastModified string `json:"last_modified,omitempty"`
+	// Items are ordered from least to most recently seen.
+	Items []Seen `json:"items,omitempty"`
+}
+
+// Store persists feed states. Load returns nil without error for unknown keys.
+type Store interface {
+	Load(ctx context.Context, key string) (*State, error)
+	Save(ctx context.Context, key string, state *State) error
+}
+
+type Tracker struct {
+	store  Store
+	window int
+	client *http.Client
+	locks  sync.Map // key -> *sync.Mutex
+}
+
+type Option func(*Tracker)
+
+// WithWindow sets how many items are remembered per feed, defaults to 1000.
+// Items that drop out of the window are returned again if the upstream still lists them.
+func WithWindow(n int) Option {
+	return func(t *Tracker) {
+		if n > 0 {
+			t.window = n
+		}
+	}
+}
+
+// WithHTTPClient sets the client used by Fetch, defaults to http.DefaultClient.
+func WithHTTPClient(c *http.Client) Option {
+	return func(t *Tracker) {
+		t.client = c
+	}
+}
+
+// New creates a Tracker keeping states in store, a MemoryStore if store is nil.
+func New(store Store, options ...Option) *Tracker {
+	if store == nil {
+		store = NewMemoryStore()
+	}
+	t := &Tracker{
+		store:  store,
+		window: defaultWindow,
+		client: http.DefaultClient,
+		locks:  sync.Map{},
+	}
+	for _, o := range options {
+		o(t)
+	}
+	return t
+}
+
+// Key identifies a feed by the requested source id and config.
+func Key(source *librarian.FeatureRequest) string {
+	sum := sha256.Sum256([]byte(source.GetId() + "\x00" + source.GetConfigJson()))
+	return hex.EncodeToString(sum[:])
+}
+
+// ItemID returns the guid of item, falling back to its link and then to a hash of its title and publish time.
+func ItemID(item *librarian.FeedItem) string {
+	if item.GetGuid() != "" {
+		return item.GetGuid()
+	}
+	if item.GetLink() != "" {
+		return item.GetLink()
+	}
+	sum := sha256.Sum256([]byte(item.GetTitle() + "\x00" + item.GetPublished()))
+	return hex.EncodeToString(sum[:])
+}
+
+func itemHash(item *librarian.FeedItem) (string, error) {
+	b, err := proto.MarshalOptions{Deterministic: true}.Marshal(item)
+	if err != nil {
+		return "", err
+	}
+	sum := sha256.Sum256(b)
+	return hex.EncodeToString(sum[:]), nil
+}
+
+// Pull holds the new or changed items of one pull. They are only remembered once Commit is called,
+// so items of a PullFeed reply that never reaches Librarian are returned again by the next pull:
+//
+//	tuihub.OnReplySent(ctx, func(ctx context.Context) { _ = pull.Commit(ctx) })
+type Pull struct {
+	Items []*librarian.FeedItem
+
+	tracker *Tracker
+	key     string
+	current []Seen
+	header  http.Header
+}
+
+// Commit remembers the items of the pull, along with the validators of the upstream response.
+// Pulls of the same feed may be committed in any order.
+func (p *Pull) Commit(ctx context.Context) error {
+	if p.tracker == nil {
+		return nil
+	}
+	return p.tracker.commit(ctx, p.key, p.current, p.header)
+}
+
+// Filter returns the items of the feed identified by key that are new or changed since the last
+// committed pull.
+func (t *Tracker) Filter(ctx context.Context, key string, items []*librarian.FeedItem) (*Pull, error) {
+	return t.filter(ctx, key, items, nil)
+}
+
+// filter compares items with the stored state, keeping validators from header for the commit if it is not nil.
+func (t *Tracker) filter(
+	ctx context.Context,
+	key string,
+	items []*librarian.FeedItem,
+	header http.Header,
+) (*Pull, error) {
+	state, err := t.store.Load(ctx, key)
+	if err != nil {
+		return nil, err
+	}
+	known := make(map[string]string)
+	if state != nil {
+		for _, s := range state.Items {
+			known[s.ID] = s.Hash
+		}
+	}
+	current := make([]Seen, 0, len(items))
+	inCurrent := make(map[string]bool, len(items))
+	var res []*librarian.FeedItem
+	for _, item := range items {
+		id := ItemID(item)
+		hash, hashErr := itemHash(item)
+		if hashErr != nil {
+			return nil, hashErr
+		}
+		if inCurrent[id] {
+			continue
+		}
+		inCurrent[id] = true
+		current = append(current, Seen{ID: id, Hash: hash})
+		if prev, ok := known[id]; !ok || prev != hash {
+			res = append(res, item)
+		}
+	}
+	return &Pull{Items: res, tracker: t, key: key, current: current, header: header}, nil
+}
+
+// commit merges current into the stored state of key.
+func (t *Tracker) commit(ctx context.Context, key string, current []Seen, header http.Header) error {
+	l, _ := t.locks.LoadOrStore(key, new(sync.Mutex))
+	mu := l.(*sync.Mutex) //nolint:errcheck // only *sync.Mutex is stored
+	mu.Lock()
+	defer mu.Unlock()
+	state, err := t.store.Load(ctx, key)
+	if err != nil {
+		return err
+	}
+	if state == nil {
+		state = new(State)
+	}
+	inCurrent := make(map[string]bool, len(current))
+	for _, s := range current {
+		inCurrent[s.ID] = true
+	}
+	next := make([]Seen, 0, len(state.Items)+len(current))
+	for _, s := range state.Items {
+		if !inCurrent[s.ID] {
+			next = append(next, s)
+		}
+	}
+	next = append(next, current...)
+	if len(next) > t.window {
+		next = next[len(next)-t.window:]
+	}
+	state.Items = next
+	if header != nil {
+		state.ETag = header.Get("ETag")
+		state.LastModified = header.Get("Last-Modified")
+	}
+	return t.store.Save(ctx, key, state)
+}
+
+// Fetch sends req with the validators stored for key and returns the new or changed items parsed
+// from the response body. It returns no items if the upstream answers 304 Not Modified.
+// The validators are saved by Pull.Commit, so an uncommitted pull is fetched in full again.
+func (t *Tracker) Fetch(
+	ctx context.Context,
+	key string,
+	req *http.Request,
+	parse func(io.Reader) ([]*librarian.FeedItem, error),
+) (*Pull, error) {
+	state, err := t.store.Load(ctx, key)
+	if err != nil {
+		return nil, err
+	}
+	req = req.Clone(ctx)
+	if state != nil {
+		if state.ETag != "" {
+			req.Header.Set("If-None-Match", state.ETag)
+		}
+		if state.LastModified != "" {
+			req.Header.Set("If-Modified-Since", state.LastModified)
+		}
+	}
+	resp, err := t.client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	switch {
+	case resp.StatusCode == http.StatusNotModified:
+		return new(Pull), nil
+	case resp.StatusCode < 200 || resp.StatusCode >= 300:
+		return nil, fmt.Errorf("fetch %s: unexpected status %s", req.URL, resp.Status)
+	}
+	items, err := parse(resp.Body)
+	if err != nil {
+		return nil, err
+	}
+	return t.filter(ctx, key, items, resp.Header)
+}
@@ -0,0 +1,139 @@
+package feedsync
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	librarian "github.com/tuihub/protos/pkg/librarian/v1"
+)
+
+func ids(items []*librarian.FeedItem) string {
+	s := make([]string, 0, len(items))
+	for _, item := range items {
+		s = append(s, ItemID(item))
+	}
+	return strings.Join(s, ",")
+}
+
+func TestFilter(t *testing.T) {
+	ctx := context.Background()
+	tr := New(nil, WithWindow(3))
+	key := Key(&librarian.FeatureRequest{Id: "rss", ConfigJson: `{"url":"a"}`})
+
+	steps := []struct {
+		items []*librarian.FeedItem
+		want  string
+	}{
+		{[]*librarian.FeedItem{{Guid: "1"}, {Guid: "2"}}, "1,2"},
+		{[]*librarian.FeedItem{{Guid: "1"}, {Guid: "2"}}, ""},
+		// changed content
+		{[]*librarian.FeedItem{{Guid: "1", Title: "new"}, {Guid: "2"}}, "1"},
+		// falls back to link, duplicates are returned once
+		{[]*librarian.FeedItem{{Link: "https://a/3"}, {Link: "https://a/3"}}, "https://a/3"},
+		// "2" is pushed out of the window by 4 and 5
+		{[]*librarian.FeedItem{{Guid: "4"}, {Guid: "5"}}, "4,5"},
+		{[]*librarian.FeedItem{{Guid: "2"}, {Guid: "5"}}, "2"},
+	}
+	for i, step := range steps {
+		pull, err := tr.Filter(ctx, key, step.items)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if ids(pull.Items) != step.want {
+			t.Errorf("step %d: got %q, want %q", i, ids(pull.Items), step.want)
+		}
+		if err = pull.Commit(ctx); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	other := Key(&librarian.FeatureRequest{Id: "rss", ConfigJson: `{"url":"b"}`})
+	if pull, _ := tr.Filter(ctx, other, []*librarian.FeedItem{{Guid: "1"}}); ids(pull.Items) != "1" {
+		t.Errorf("other config: got %q", ids(pull.Items))
+	}
+}
+
+func TestFilterUncommitted(t *testing.T) {
+	ctx := context.Background()
+	tr := New(nil)
+	items := []*librarian.FeedItem{{Guid: "1"}, {Guid: "2"}}
+	// the first reply was lost, nothing is remembered
+	if _, err := tr.Filter(ctx, "k", items); err != nil {
+		t.Fatal(err)
+	}
+	first, _ := tr.Filter(ctx, "k", items[:1])
+	second, _ := tr.Filter(ctx, "k", items)
+	if ids(first.Items) != "1" || ids(second.Items) != "1,2" {
+		t.Fatalf("got %q and %q", ids(first.Items), ids(second.Items))
+	}
+	// overlapping pulls merge when committed out of order
+	_ = second.Commit(ctx)
+	_ = first.Commit(ctx)
+	if pull, _ := tr.Filter(ctx, "k", items); len(pull.Items) != 0 {
+		t.Errorf("after commits: got %q", ids(pull.Items))
+	}
+}
+
+func TestFetch(t *testing.T) {
+	requests := 0
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests++
+		if r.Header.Get("If-None-Match") == `"v1"` &&
+			r.Header.Get("If-Modified-Since") == "Mon, 02 Jan 2006 15:04:05 GMT" {
+			w.WriteHeader(http.StatusNotModified)
+			return
+		}
+		w.Header().Set("ETag", `"v1"`)
+		w.Header().Set("Last-Modified", "Mon, 02 Jan 2006 15:04:05 GMT")
+		_, _ = io.WriteString(w, "1,2")
+	}))
+	defer srv.Close()
+
+	parse := func(r io.Reader) ([]*librarian.FeedItem, error) {
+		b, err := io.ReadAll(r)
+		if err != nil {
+			return nil, err
+		}
+		var items []*librarian.FeedItem
+		for _, id := range strings.Split(string(b), ",") {
+			items = append(items, &librarian.FeedItem{Guid: id})
+		}
+		return items, nil
+	}
+	ctx := context.Background()
+	store := NewMemoryStore()
+	tr := New(store, WithHTTPClient(srv.Client()))
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, srv.URL, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	pull, err := tr.Fetch(ctx, "k", req, parse)
+	if err != nil || ids(pull.Items) != "1,2" {
+		t.Fatalf("first fetch: got %q, err %v", ids(pull.Items), err)
+	}
+	if err = pull.Commit(ctx); err != nil {
+		t.Fatal(err)
+	}
+	pull, err = tr.Fetch(ctx, "k", req, parse)
+	if err != nil || len(pull.Items) != 0 {
+		t.Errorf("not modified fetch: got %q, err %v", ids(pull.Items), err)
+	}
+	if err = pull.Commit(ctx); err != nil {
+		t.Error(err)
+	}
+	if requests != 2 {
+		t.Errorf("requests = %d", requests)
+	}
+	if req.Header.Get("If-None-Match") != "" {
+		t.Error("Fetch modified the caller's request")
+	}
+	state, _ := store.Load(ctx, "k")
+	if state.ETag != `"v1"` || len(state.Items) != 2 {
+		t.Errorf("state = %+v", state)
+	}
+}
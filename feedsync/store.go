@@ -0,0 +1,42 @@
+package feedsync
+
+import (
+	"context"
+	"sync"
+)
+
+// MemoryStore keeps states in memory, they are lost when the porter restarts.
+type MemoryStore struct {
+	mu     sync.RWMutex
+	states map[string]*State
+}
+
+func NewMemoryStore() *MemoryStore {
+	return &MemoryStore{
+		mu:     sync.RWMutex{},
+		states: make(map[string]*State),
+	}
+}
+
+func (s *MemoryStore) Load(_ context.Context, key string) (*State, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	state, ok := s.states[key]
+	if !ok {
+		return nil, nil //nolint:nilnil // unknown key
+	}
+	return cloneState(state), nil
+}
+
+func (s *MemoryStore) Save(_ context.Context, key string, state *State) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.states[key] = cloneState(state)
+	return nil
+}
+
+func cloneState(state *State) *State {
+	c := *state
+	c.Items = append([]Seen(nil), state.Items...)
+	return &c
+}
@@ -0,0 +1,75 @@
+package tuihub
+
+import (
+	"context"
+	nethttp "net/http"
+
+	pb "github.com/tuihub/protos/pkg/librarian/porter/v1"
+
+	"github.com/go-kratos/kratos/v2/log"
+	"github.com/go-kratos/kratos/v2/middleware"
+	"github.com/go-kratos/kratos/v2/transport/http"
+)
+
+// RegisterHTTPGateway serves every porter RPC on srv as `POST /<full gRPC method name>` with
+// protojson bodies, e.g. `POST /librarian.porter.v1.LibrarianPorterService/PullFeed`.
+// Requests go through the same middlewares as NewServer, so service should be the one passed to NewServer.
+// Like admin endpoints, the gateway requires ServerConfig.AdminToken and is not served when it is empty.
+func RegisterHTTPGateway(
+	srv *http.Server,
+	c *ServerConfig,
+	service pb.LibrarianPorterServiceServer,
+	logger log.Logger,
+) {
+	if c.AdminToken == "" {
+		return
+	}
+	m := middleware.Chain(serverMiddlewares(c, service, logger)...)
+	r := srv.Route("/", func(h nethttp.Handler) nethttp.Handler {
+		return requireBearerToken(c.AdminToken, h)
+	})
+	gatewayRoute(r, m, pb.LibrarianPorterService_GetPorterInformation_FullMethodName, service.GetPorterInformation)
+	gatewayRoute(r, m, pb.LibrarianPorterService_EnablePorter_FullMethodName, service.EnablePorter)
+	gatewayRoute(r, m, pb.LibrarianPorterService_EnableContext_FullMethodName, service.EnableContext)
+	gatewayRoute(r, m, pb.LibrarianPorterService_DisableContext_FullMethodName, service.DisableContext)
+	gatewayRoute(r, m, pb.LibrarianPorterService_PullAccount_FullMethodName, service.PullAccount)
+	gatewayRoute(r, m, pb.LibrarianPorterService_PullAppInfo_FullMethodName, service.PullAppInfo)
+	gatewayRoute(r, m, pb.LibrarianPorterService_PullAccountAppInfoRelation_FullMethodName,
+		service.PullAccountAppInfoRelation)
+	gatewayRoute(r, m, pb.LibrarianPorterService_SearchAppInfo_FullMethodName, service.SearchAppInfo)
+	gatewayRoute(r, m, pb.LibrarianPorterService_PullFeed_FullMethodName, service.PullFeed)
+	gatewayRoute(r, m, pb.LibrarianPorterService_ExecFeedItemAction_FullMethodName, service.ExecFeedItemAction)
+	gatewayRoute(r, m, pb.LibrarianPorterService_EnableFeedSetter_FullMethodName, service.EnableFeedSetter)
+	gatewayRoute(r, m, pb.LibrarianPorterService_DisableFeedSetter_FullMethodName, service.DisableFeedSetter)
+	gatewayRoute(r, m, pb.LibrarianPorterService_EnableFeedGetter_FullMethodName, service.EnableFeedGetter)
+	gatewayRoute(r, m, pb.LibrarianPorterService_DisableFeedGetter_FullMethodName, service.DisableFeedGetter)
+	gatewayRoute(r, m, pb.LibrarianPorterService_PushFeedItems_FullMethodName, service.PushFeedItems)
+}
+
+func gatewayRoute[Req, Reply any](
+	r *http.Router,
+	m middleware.Middleware,
+	operation string,
+	call func(context.Context, *Req) (*Reply, error),
+) {
+	h := m(func(ctx context.Context, req interface{}) (interface{}, error) {
+		return call(ctx, req.(*Req)) //nolint:errcheck // the gateway only passes *Req
+	})
+	r.POST(operation, func(ctx http.Context) error {
+		in := new(Req)
+		if err := ctx.Bind(in); err != nil {
+			return err
+		}
+		http.SetOperation(ctx, operation)
+		reqCtx, sent := withReplySent(ctx)
+		out, err := h(reqCtx, in)
+		if err != nil {
+			return err
+		}
+		if err = ctx.Result(nethttp.StatusOK, out); err != nil {
+			return err
+		}
+		sent.sent(reqCtx)
+		return nil
+	})
+}
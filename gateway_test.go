@@ -0,0 +1,82 @@
+package tuihub
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	pb "github.com/tuihub/protos/pkg/librarian/porter/v1"
+
+	"github.com/go-kratos/kratos/v2/log"
+	"github.com/go-kratos/kratos/v2/middleware"
+	"github.com/go-kratos/kratos/v2/transport"
+)
+
+func TestHTTPGateway(t *testing.T) {
+	var operations []string
+	c := defaultServerConfig()
+	c.AdminToken = "secret"
+	c.Middlewares = []middleware.Middleware{
+		func(handler middleware.Handler) middleware.Handler {
+			return func(ctx context.Context, req interface{}) (interface{}, error) {
+				if tr, ok := transport.FromServerContext(ctx); ok {
+					operations = append(operations, tr.Operation())
+				}
+				return handler(ctx, req)
+			}
+		},
+	}
+	tests := []struct {
+		name    string
+		enabled bool
+		method  string
+		body    string
+		token   string
+		want    int
+	}{
+		{"ok", true, pb.LibrarianPorterService_PullFeed_FullMethodName, `{"source":{"id":"rss"}}`, "secret",
+			http.StatusOK},
+		{"no token", true, pb.LibrarianPorterService_PullFeed_FullMethodName, `{"source":{"id":"rss"}}`, "",
+			http.StatusUnauthorized},
+		{"unsupported source", true, pb.LibrarianPorterService_PullFeed_FullMethodName, `{"source":{"id":"atom"}}`,
+			"secret", http.StatusBadRequest},
+		{"not enabled", false, pb.LibrarianPorterService_PullFeed_FullMethodName, `{"source":{"id":"rss"}}`,
+			"secret", http.StatusForbidden},
+		{"bad body", true, pb.LibrarianPorterService_PullFeed_FullMethodName, `{"source":1}`, "secret",
+			http.StatusBadRequest},
+		{"unsupported platform", true, pb.LibrarianPorterService_PullAccount_FullMethodName,
+			`{"accountId":{"platform":"steam","platformAccountId":"1"}}`, "secret", http.StatusBadRequest},
+	}
+	for _, tt := range tests {
+		srv := NewHTTPServer(c)
+		RegisterHTTPGateway(srv, c, newTestService(tt.enabled), log.DefaultLogger)
+		r := httptest.NewRequest(http.MethodPost, tt.method, strings.NewReader(tt.body))
+		r.Header.Set("Content-Type", "application/json")
+		if tt.token != "" {
+			r.Header.Set("Authorization", "Bearer "+tt.token)
+		}
+		w := httptest.NewRecorder()
+		srv.ServeHTTP(w, r)
+		if w.Code != tt.want {
+			t.Errorf("%s: code = %d, want %d, body %s", tt.name, w.Code, tt.want, w.Body)
+		}
+	}
+	if len(operations) == 0 || operations[0] != pb.LibrarianPorterService_PullFeed_FullMethodName {
+		t.Errorf("operations = %v", operations)
+	}
+}
+
+func TestHTTPGatewayRequiresAdminToken(t *testing.T) {
+	c := defaultServerConfig()
+	srv := NewHTTPServer(c)
+	RegisterHTTPGateway(srv, c, newTestService(true), log.DefaultLogger)
+	r := httptest.NewRequest(http.MethodPost, pb.LibrarianPorterService_PullFeed_FullMethodName,
+		strings.NewReader(`{}`))
+	w := httptest.NewRecorder()
+	srv.ServeHTTP(w, r)
+	if w.Code != http.StatusNotFound {
+		t.Errorf("code = %d, want 404", w.Code)
+	}
+}
@@ -0,0 +1,45 @@
+package tuihub
+
+import (
+	"crypto/subtle"
+	nethttp "net/http"
+	"time"
+
+	tuihublogger "github.com/tuihub/tuihub-go/logger"
+
+	"github.com/go-kratos/kratos/v2/transport/http"
+)
+
+const adminLogLevelPath = "/admin/log/level"
+
+// NewHTTPServer creates the HTTP server for admin endpoints. Admin endpoints change porter
+// behavior at runtime and are only served when ServerConfig.AdminToken is set,
+// keep HTTPAddr on loopback or an admin network regardless.
+func NewHTTPServer(c *ServerConfig) *http.Server {
+	var opts = []http.ServerOption{
+		http.Address(c.HTTPAddr),
+	}
+	if c.Timeout != nil {
+		opts = append(opts, http.Timeout(*c.Timeout))
+	} else {
+		opts = append(opts, http.Timeout(time.Minute))
+	}
+	srv := http.NewServer(opts...)
+	if c.AdminToken != "" {
+		srv.Handle(adminLogLevelPath, requireBearerToken(c.AdminToken, tuihublogger.LevelHandler()))
+	}
+	return srv
+}
+
+// requireBearerToken rejects requests without `Authorization: Bearer <token>`.
+func requireBearerToken(token string, h nethttp.Handler) nethttp.Handler {
+	want := []byte("Bearer " + token)
+	return nethttp.HandlerFunc(func(w nethttp.ResponseWriter, r *nethttp.Request) {
+		if subtle.ConstantTimeCompare([]byte(r.Header.Get("Authorization")), want) != 1 {
+			w.Header().Set("WWW-Authenticate", "Bearer")
+			nethttp.Error(w, "unauthorized", nethttp.StatusUnauthorized)
+			return
+		}
+		h.ServeHTTP(w, r)
+	})
+}
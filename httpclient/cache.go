@@ -0,0 +1,256 @@
+package httpclient
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// CacheHeader is set on responses served from the cache.
+const CacheHeader = "X-From-Cache"
+
+type cacheTransport struct {
+	next    http.RoundTripper
+	dir     string
+	maxSize int64
+	now     func() time.Time
+}
+
+// cacheEntry is stored under a hash of the request URL, the URL itself is not stored as it may carry API keys.
+type cacheEntry struct {
+	// Vary is a hash of the request headers named by the Vary response header.
+	Vary       string      `json:"vary,omitempty"`
+	StatusCode int         `json:"status_code"`
+	Header     http.Header `json:"header"`
+	Body       []byte      `json:"body"`
+	StoredAt   time.Time   `json:"stored_at"`
+}
+
+// RoundTrip serves fresh GET responses from the cache and revalidates stale ones with
+// If-None-Match and If-Modified-Since. Requests setting their own validators or credentials
+// bypass the cache.
+func (t *cacheTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	if !cacheable(req) {
+		return t.next.RoundTrip(req)
+	}
+	key := cacheKey(req)
+	entry := t.load(key)
+	if entry != nil && entry.Vary != varyHash(entry.Header, req.Header) {
+		entry = nil
+	}
+	if entry != nil && !hasDirective(req.Header, "no-cache") && t.fresh(entry) {
+		return entry.response(req), nil
+	}
+	r := req
+	revalidate := entry != nil && (entry.Header.Get("ETag") != "" || entry.Header.Get("Last-Modified") != "")
+	if revalidate {
+		r = req.Clone(req.Context())
+		if etag := entry.Header.Get("ETag"); etag != "" {
+			r.Header.Set("If-None-Match", etag)
+		}
+		if lastModified := entry.Header.Get("Last-Modified"); lastModified != "" {
+			r.Header.Set("If-Modified-Since", lastModified)
+		}
+	}
+	resp, err := t.next.RoundTrip(r)
+	if err != nil {
+		return nil, err
+	}
+	if revalidate && resp.StatusCode == http.StatusNotModified {
+		_, _ = io.CopyN(io.Discard, resp.Body, maxDrainBody)
+		_ = resp.Body.Close()
+		for _, h := range []string{"Cache-Control", "Expires", "ETag", "Last-Modified", "Date"} {
+			if v := resp.Header.Get(h); v != "" {
+				entry.Header.Set(h, v)
+			}
+		}
+		entry.StoredAt = t.now()
+		t.store(key, entry)
+		return entry.response(req), nil
+	}
+	if resp.StatusCode != http.StatusOK || !storable(resp.Header) {
+		return resp, nil
+	}
+	body, err := io.ReadAll(io.LimitReader(resp.Body, t.maxSize+1))
+	if err != nil {
+		_ = resp.Body.Close()
+		return nil, err
+	}
+	if int64(len(body)) > t.maxSize {
+		resp.Body = readCloser{io.MultiReader(bytes.NewReader(body), resp.Body), resp.Body}
+		return resp, nil
+	}
+	_ = resp.Body.Close()
+	resp.Body = io.NopCloser(bytes.NewReader(body))
+	t.store(key, &cacheEntry{
+		Vary:       varyHash(resp.Header, req.Header),
+		StatusCode: resp.StatusCode,
+		Header:     resp.Header.Clone(),
+		Body:       body,
+		StoredAt:   t.now(),
+	})
+	return resp, nil
+}
+
+func (t *cacheTransport) fresh(e *cacheEntry) bool {
+	if hasDirective(e.Header, "no-cache") {
+		return false
+	}
+	age := t.now().Sub(e.StoredAt)
+	if maxAge, ok := directive(e.Header, "max-age"); ok {
+		seconds, err := strconv.Atoi(maxAge)
+		return err == nil && age < time.Duration(seconds)*time.Second
+	}
+	if expires, err := http.ParseTime(e.Header.Get("Expires")); err == nil {
+		return t.now().Before(expires)
+	}
+	return false
+}
+
+func (t *cacheTransport) path(key string) string {
+	return filepath.Join(t.dir, key[:2], key)
+}
+
+func (t *cacheTransport) load(key string) *cacheEntry {
+	data, err := os.ReadFile(t.path(key))
+	if err != nil {
+		return nil
+	}
+	entry := new(cacheEntry)
+	if err = json.Unmarshal(data, entry); err != nil {
+		return nil
+	}
+	return entry
+}
+
+// store writes e to a temporary file renamed into place so that readers never see a partial entry.
+// A failed write only costs a cache miss.
+func (t *cacheTransport) store(key string, e *cacheEntry) {
+	data, err := json.Marshal(e)
+	if err != nil {
+		return
+	}
+	path := t.path(key)
+	if err = os.MkdirAll(filepath.Dir(path), 0o700); err != nil { //nolint:mnd // owner only
+		return
+	}
+	f, err := os.CreateTemp(filepath.Dir(path), key+".*.tmp")
+	if err != nil {
+		return
+	}
+	_, err = f.Write(data)
+	if closeErr := f.Close(); err == nil {
+		err = closeErr
+	}
+	if err == nil {
+		err = os.Rename(f.Name(), path)
+	}
+	if err != nil {
+		_ = os.Remove(f.Name())
+	}
+}
+
+func (e *cacheEntry) response(req *http.Request) *http.Response {
+	header := e.Header.Clone()
+	header.Set(CacheHeader, "1")
+	return &http.Response{ //nolint:exhaustruct // defaults
+		Status:        fmt.Sprintf("%d %s", e.StatusCode, http.StatusText(e.StatusCode)),
+		StatusCode:    e.StatusCode,
+		Proto:         "HTTP/1.1",
+		ProtoMajor:    1,
+		ProtoMinor:    1,
+		Header:        header,
+		Body:          io.NopCloser(bytes.NewReader(e.Body)),
+		ContentLength: int64(len(e.Body)),
+		Request:       req,
+	}
+}
+
+func cacheable(req *http.Request) bool {
+	if req.Method != http.MethodGet {
+		return false
+	}
+	for _, h := range []string{"Authorization", "Cookie", "Range", "If-None-Match", "If-Modified-Since"} {
+		if req.Header.Get(h) != "" {
+			return false
+		}
+	}
+	return !hasDirective(req.Header, "no-store")
+}
+
+// storable reports whether a response can be served fresh or revalidated later.
+// The cache is shared by every account the porter serves, so private responses are not stored.
+func storable(h http.Header) bool {
+	if hasDirective(h, "no-store") || hasDirective(h, "private") || h.Get("Set-Cookie") != "" {
+		return false
+	}
+	for _, name := range varyNames(h) {
+		if name == "*" {
+			return false
+		}
+	}
+	_, maxAge := directive(h, "max-age")
+	return maxAge || h.Get("Expires") != "" || h.Get("ETag") != "" || h.Get("Last-Modified") != ""
+}
+
+func cacheKey(req *http.Request) string {
+	sum := sha256.Sum256([]byte(req.URL.String()))
+	return hex.EncodeToString(sum[:])
+}
+
+// varyHash hashes the request headers named by the Vary header of a response, empty without Vary.
+func varyHash(resp, req http.Header) string {
+	names := varyNames(resp)
+	if len(names) == 0 {
+		return ""
+	}
+	h := sha256.New()
+	for _, name := range names {
+		fmt.Fprintf(h, "%s:%q\n", name, req.Values(name))
+	}
+	return hex.EncodeToString(h.Sum(nil))
+}
+
+func varyNames(h http.Header) []string {
+	var names []string
+	for _, v := range h.Values("Vary") {
+		for _, name := range strings.Split(v, ",") {
+			if name = strings.TrimSpace(name); name != "" {
+				names = append(names, http.CanonicalHeaderKey(name))
+			}
+		}
+	}
+	return names
+}
+
+// directive returns the value of a Cache-Control directive.
+func directive(h http.Header, name string) (string, bool) {
+	for _, v := range h.Values("Cache-Control") {
+		for _, d := range strings.Split(v, ",") {
+			k, value, _ := strings.Cut(strings.TrimSpace(d), "=")
+			if strings.EqualFold(k, name) {
+				return strings.Trim(value, `"`), true
+			}
+		}
+	}
+	return "", false
+}
+
+func hasDirective(h http.Header, name string) bool {
+	_, ok := directive(h, name)
+	return ok
+}
+
+type readCloser struct {
+	io.Reader
+	io.Closer
+}
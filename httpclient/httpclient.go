@@ -0,0 +1,157 @@
+// Package httpclient builds the net/http client porters use to call upstream APIs.
+//
+// The client limits requests per host, retries idempotent requests honoring Retry-After,
+// caches GET responses on disk, reads the proxy from HTTP_PROXY, HTTPS_PROXY and NO_PROXY,
+// and records a span and metrics for each request. Pass the RPC context to requests
+// so that spans and metrics are tied to the porter RPC:
+//
+//	req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+//	resp, err := p.HTTPClient().Do(req)
+package httpclient
+
+import (
+	"net/http"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	librarian "github.com/tuihub/protos/pkg/librarian/v1"
+
+	"go.opentelemetry.io/otel/metric"
+	"go.opentelemetry.io/otel/trace"
+)
+
+const (
+	envCacheDir  = "HTTP_CLIENT_CACHE_DIR"
+	envRateLimit = "HTTP_CLIENT_RATE_LIMIT"
+
+	defaultTimeout           = 30 * time.Second
+	defaultMaxRetries        = 3
+	defaultRetryBackoff      = 500 * time.Millisecond
+	defaultMaxRetryWait      = time.Minute
+	defaultRateBurst         = 1
+	defaultMaxCacheEntrySize = 8 << 20
+)
+
+// Config configures clients created by New. Zero values disable the corresponding feature,
+// start from DefaultConfig or ConfigFromEnv.
+type Config struct {
+	// UserAgent is sent when a request has no User-Agent header, see UserAgent.
+	UserAgent string
+	// Timeout limits a whole request including retries.
+	Timeout time.Duration
+	// MaxRetries is the number of retries of idempotent requests failed with a network error,
+	// 429, 502, 503 or 504.
+	MaxRetries int
+	// RetryBackoff is the first wait between retries, doubled on each retry.
+	// Retry-After of the response takes precedence.
+	RetryBackoff time.Duration
+	// MaxRetryWait is the longest wait before a retry. Responses asking to wait longer are returned.
+	MaxRetryWait time.Duration
+	// RateLimit is the number of requests per second sent to each host.
+	RateLimit float64
+	// RateBurst is the number of requests sent to a host at once before RateLimit applies.
+	RateBurst int
+	// HostRateLimits overrides RateLimit for hosts such as `api.steampowered.com`.
+	HostRateLimits map[string]float64
+	// CacheDir enables the on-disk cache of GET responses.
+	CacheDir string
+	// MaxCacheEntrySize is the largest response body stored in the cache.
+	MaxCacheEntrySize int64
+	// Transport sends requests. Defaults to a clone of http.DefaultTransport using the proxy from env.
+	Transport http.RoundTripper
+	// TracerProvider and MeterProvider default to the otel global providers.
+	TracerProvider trace.TracerProvider
+	MeterProvider  metric.MeterProvider
+}
+
+// DefaultConfig returns the config used by porters without WithHTTPClientConfig.
+func DefaultConfig() Config {
+	return Config{
+		UserAgent:         "",
+		Timeout:           defaultTimeout,
+		MaxRetries:        defaultMaxRetries,
+		RetryBackoff:      defaultRetryBackoff,
+		MaxRetryWait:      defaultMaxRetryWait,
+		RateLimit:         0,
+		RateBurst:         defaultRateBurst,
+		HostRateLimits:    nil,
+		CacheDir:          "",
+		MaxCacheEntrySize: defaultMaxCacheEntrySize,
+		Transport:         nil,
+		TracerProvider:    nil,
+		MeterProvider:     nil,
+	}
+}
+
+// ConfigFromEnv returns DefaultConfig with CacheDir and RateLimit read from
+// HTTP_CLIENT_CACHE_DIR and HTTP_CLIENT_RATE_LIMIT.
+func ConfigFromEnv() Config {
+	c := DefaultConfig()
+	if dir, exist := os.LookupEnv(envCacheDir); exist {
+		c.CacheDir = dir
+	}
+	if v, exist := os.LookupEnv(envRateLimit); exist {
+		if limit, err := strconv.ParseFloat(v, 64); err == nil && limit >= 0 {
+			c.RateLimit = limit
+		}
+	}
+	return c
+}
+
+// New creates a client with c. Clients share nothing, create one per porter and reuse it.
+func New(c Config) *http.Client {
+	base := c.Transport
+	if base == nil {
+		t := http.DefaultTransport.(*http.Transport).Clone() //nolint:errcheck // always *http.Transport
+		t.Proxy = http.ProxyFromEnvironment
+		base = t
+	}
+	var rt = base
+	if c.RateLimit > 0 || len(c.HostRateLimits) > 0 {
+		rt = newRateLimitTransport(rt, c.RateLimit, c.RateBurst, c.HostRateLimits)
+	}
+	if c.MaxRetries > 0 {
+		rt = &retryTransport{
+			next:         rt,
+			maxRetries:   c.MaxRetries,
+			backoff:      c.RetryBackoff,
+			maxRetryWait: c.MaxRetryWait,
+		}
+	}
+	if c.CacheDir != "" {
+		rt = &cacheTransport{
+			next:    rt,
+			dir:     c.CacheDir,
+			maxSize: c.MaxCacheEntrySize,
+			now:     time.Now,
+		}
+	}
+	rt = newTelemetryTransport(rt, c.UserAgent, c.TracerProvider, c.MeterProvider)
+	return &http.Client{ //nolint:exhaustruct // defaults
+		Transport: rt,
+		Timeout:   c.Timeout,
+	}
+}
+
+// UserAgent returns `<name>/<version> (+<source code address>)` of a porter binary.
+func UserAgent(summary *librarian.PorterBinarySummary) string {
+	name := summary.GetName()
+	if name == "" {
+		name = "tuihub-porter"
+	}
+	version := summary.GetVersion()
+	if version == "" {
+		version = summary.GetBuildVersion()
+	}
+	var b strings.Builder
+	b.WriteString(strings.ReplaceAll(name, " ", "-"))
+	if version != "" {
+		b.WriteString("/" + strings.TrimPrefix(version, "v"))
+	}
+	if addr := summary.GetSourceCodeAddress(); addr != "" {
+		b.WriteString(" (+" + addr + ")")
+	}
+	return b.String()
+}
@@ -0,0 +1,253 @@
+package httpclient_test
+
+import (
+	"context"
+	"io"
+	"io/fs"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	librarian "github.com/tuihub/protos/pkg/librarian/v1"
+	"github.com/tuihub/tuihub-go/httpclient"
+)
+
+func testConfig() httpclient.Config {
+	c := httpclient.DefaultConfig()
+	c.RetryBackoff = time.Millisecond
+	return c
+}
+
+func get(t *testing.T, c *http.Client, url string) (*http.Response, string) {
+	t.Helper()
+	resp, err := c.Get(url)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return resp, string(body)
+}
+
+func TestRetryAfter(t *testing.T) {
+	var calls atomic.Int32
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+		if calls.Add(1) < 3 {
+			w.Header().Set("Retry-After", "0")
+			w.WriteHeader(http.StatusTooManyRequests)
+			return
+		}
+		_, _ = io.WriteString(w, "ok")
+	}))
+	defer srv.Close()
+
+	resp, body := get(t, httpclient.New(testConfig()), srv.URL)
+	if resp.StatusCode != http.StatusOK || body != "ok" || calls.Load() != 3 {
+		t.Errorf("status = %d, body = %q, calls = %d", resp.StatusCode, body, calls.Load())
+	}
+}
+
+func TestRetryGivesUp(t *testing.T) {
+	var calls atomic.Int32
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+		calls.Add(1)
+		w.Header().Set("Retry-After", "3600")
+		w.WriteHeader(http.StatusServiceUnavailable)
+	}))
+	defer srv.Close()
+
+	// waiting an hour exceeds MaxRetryWait, the response is returned as is
+	resp, _ := get(t, httpclient.New(testConfig()), srv.URL)
+	if resp.StatusCode != http.StatusServiceUnavailable || calls.Load() != 1 {
+		t.Errorf("status = %d, calls = %d", resp.StatusCode, calls.Load())
+	}
+	if d, ok := httpclient.RetryAfter(resp, time.Now()); !ok || d != time.Hour {
+		t.Errorf("RetryAfter = %v, %v", d, ok)
+	}
+
+	calls.Store(0)
+	resp, err := httpclient.New(testConfig()).Post(srv.URL, "text/plain", strings.NewReader("x"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+	if calls.Load() != 1 {
+		t.Errorf("POST retried: calls = %d", calls.Load())
+	}
+}
+
+func TestRateLimit(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(http.ResponseWriter, *http.Request) {}))
+	defer srv.Close()
+
+	c := testConfig()
+	c.RateLimit = 20
+	client := httpclient.New(c)
+	start := time.Now()
+	for i := 0; i < 3; i++ {
+		get(t, client, srv.URL)
+	}
+	// the first request uses the burst, two more wait 50ms each
+	if elapsed := time.Since(start); elapsed < 90*time.Millisecond {
+		t.Errorf("3 requests at 20/s took %v", elapsed)
+	}
+}
+
+func TestCache(t *testing.T) {
+	var calls, revalidated atomic.Int32
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		calls.Add(1)
+		w.Header().Set("ETag", `"v1"`)
+		if r.URL.Path == "/fresh" {
+			w.Header().Set("Cache-Control", "max-age=60")
+		}
+		if r.Header.Get("If-None-Match") == `"v1"` {
+			revalidated.Add(1)
+			w.WriteHeader(http.StatusNotModified)
+			return
+		}
+		_, _ = io.WriteString(w, "body of "+r.URL.Path)
+	}))
+	defer srv.Close()
+
+	c := testConfig()
+	c.CacheDir = t.TempDir()
+	client := httpclient.New(c)
+
+	for i := 0; i < 2; i++ {
+		resp, body := get(t, client, srv.URL+"/fresh")
+		if body != "body of /fresh" {
+			t.Errorf("fresh %d: body = %q", i, body)
+		}
+		if hit := resp.Header.Get(httpclient.CacheHeader) != ""; hit != (i == 1) {
+			t.Errorf("fresh %d: cache hit = %v", i, hit)
+		}
+	}
+	if calls.Load() != 1 {
+		t.Errorf("fresh: calls = %d, want 1", calls.Load())
+	}
+
+	for i := 0; i < 2; i++ {
+		resp, body := get(t, client, srv.URL+"/stale")
+		if resp.StatusCode != http.StatusOK || body != "body of /stale" {
+			t.Errorf("stale %d: status = %d, body = %q", i, resp.StatusCode, body)
+		}
+	}
+	if revalidated.Load() != 1 {
+		t.Errorf("stale: revalidated = %d, want 1", revalidated.Load())
+	}
+
+	// a new client reads the same cache directory
+	resp, _ := get(t, httpclient.New(c), srv.URL+"/fresh")
+	if resp.Header.Get(httpclient.CacheHeader) == "" {
+		t.Error("cache not persisted")
+	}
+	err := filepath.WalkDir(c.CacheDir, func(path string, d fs.DirEntry, err error) error {
+		if err != nil || d.IsDir() {
+			return err
+		}
+		if b, _ := os.ReadFile(path); strings.Contains(string(b), srv.URL) {
+			t.Errorf("%s stores the request URL", path)
+		}
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestCacheVary(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Cache-Control", "max-age=60")
+		switch r.URL.Path {
+		case "/vary":
+			w.Header().Set("Vary", "Accept-Language")
+		case "/private":
+			w.Header().Set("Cache-Control", "private, max-age=60")
+		case "/no-cache":
+			w.Header().Set("Cache-Control", "no-cache")
+			w.Header().Set("ETag", `"v1"`)
+			if r.Header.Get("If-None-Match") == `"v1"` {
+				w.WriteHeader(http.StatusNotModified)
+				return
+			}
+		}
+		_, _ = io.WriteString(w, r.URL.Path+" "+r.Header.Get("Accept-Language"))
+	}))
+	defer srv.Close()
+	c := testConfig()
+	c.CacheDir = t.TempDir()
+	client := httpclient.New(c)
+	getLang := func(path, lang string) (bool, string) {
+		req, err := http.NewRequestWithContext(context.Background(), http.MethodGet, srv.URL+path, nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		req.Header.Set("Accept-Language", lang)
+		resp, err := client.Do(req)
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer resp.Body.Close()
+		b, _ := io.ReadAll(resp.Body)
+		return resp.Header.Get(httpclient.CacheHeader) != "", string(b)
+	}
+
+	tests := []struct {
+		path, lang string
+		hit        bool
+		body       string
+	}{
+		{"/vary", "en", false, "/vary en"},
+		{"/vary", "ja", false, "/vary ja"},
+		{"/vary", "ja", true, "/vary ja"},
+		{"/private", "", false, "/private "},
+		{"/private", "", false, "/private "},
+		{"/no-cache", "", false, "/no-cache "},
+		// revalidated, then served from the cache
+		{"/no-cache", "", true, "/no-cache "},
+	}
+	for i, tt := range tests {
+		if hit, body := getLang(tt.path, tt.lang); hit != tt.hit || body != tt.body {
+			t.Errorf("%d %s: hit = %v, body = %q", i, tt.path, hit, body)
+		}
+	}
+}
+
+func TestUserAgent(t *testing.T) {
+	var got atomic.Value
+	srv := httptest.NewServer(http.HandlerFunc(func(_ http.ResponseWriter, r *http.Request) {
+		got.Store(r.Header.Get("User-Agent"))
+	}))
+	defer srv.Close()
+
+	c := testConfig()
+	c.UserAgent = httpclient.UserAgent(&librarian.PorterBinarySummary{
+		Name:              "tuihub-rss",
+		Version:           "v1.2.0",
+		SourceCodeAddress: "https://github.com/tuihub/tuihub-rss",
+	})
+	get(t, httpclient.New(c), srv.URL)
+	if want := "tuihub-rss/1.2.0 (+https://github.com/tuihub/tuihub-rss)"; got.Load() != want {
+		t.Errorf("User-Agent = %q, want %q", got.Load(), want)
+	}
+
+	req, _ := http.NewRequest(http.MethodGet, srv.URL, nil)
+	req.Header.Set("User-Agent", "custom")
+	resp, err := httpclient.New(c).Do(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+	if got.Load() != "custom" {
+		t.Errorf("User-Agent = %q, want custom", got.Load())
+	}
+}
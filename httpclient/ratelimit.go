@@ -0,0 +1,61 @@
+package httpclient
+
+import (
+	"net/http"
+	"sync"
+
+	"golang.org/x/time/rate"
+)
+
+type rateLimitTransport struct {
+	next     http.RoundTripper
+	limit    float64
+	burst    int
+	perHost  map[string]float64
+	mu       sync.Mutex
+	limiters map[string]*rate.Limiter
+}
+
+func newRateLimitTransport(
+	next http.RoundTripper, limit float64, burst int, perHost map[string]float64,
+) *rateLimitTransport {
+	if burst < 1 {
+		burst = 1
+	}
+	return &rateLimitTransport{
+		next:     next,
+		limit:    limit,
+		burst:    burst,
+		perHost:  perHost,
+		mu:       sync.Mutex{},
+		limiters: make(map[string]*rate.Limiter),
+	}
+}
+
+func (t *rateLimitTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	if l := t.limiter(req.URL.Hostname()); l != nil {
+		if err := l.Wait(req.Context()); err != nil {
+			return nil, err
+		}
+	}
+	return t.next.RoundTrip(req)
+}
+
+// limiter returns the limiter of host, nil if requests to host are not limited.
+func (t *rateLimitTransport) limiter(host string) *rate.Limiter {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if l, ok := t.limiters[host]; ok {
+		return l
+	}
+	limit, ok := t.perHost[host]
+	if !ok {
+		limit = t.limit
+	}
+	var l *rate.Limiter
+	if limit > 0 {
+		l = rate.NewLimiter(rate.Limit(limit), t.burst)
+	}
+	t.limiters[host] = l
+	return l
+}
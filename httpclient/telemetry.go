@@ -0,0 +1,110 @@
+package httpclient
+
+import (
+	"net/http"
+	"time"
+
+	"github.com/go-kratos/kratos/v2/transport"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/metric"
+	"go.opentelemetry.io/otel/metric/noop"
+	"go.opentelemetry.io/otel/trace"
+)
+
+const (
+	instrumentationName = "github.com/tuihub/tuihub-go/httpclient"
+
+	// OperationKey is the span and metric attribute holding the porter RPC operation of a request.
+	OperationKey = attribute.Key("tuihub.porter.operation")
+)
+
+// telemetryTransport sets the User-Agent and records a client span, the request count and
+// the request duration. Retries and cache hits are part of the recorded request.
+type telemetryTransport struct {
+	next      http.RoundTripper
+	userAgent string
+	tracer    trace.Tracer
+	requests  metric.Int64Counter
+	duration  metric.Float64Histogram
+}
+
+func newTelemetryTransport(
+	next http.RoundTripper, userAgent string, tp trace.TracerProvider, mp metric.MeterProvider,
+) *telemetryTransport {
+	if tp == nil {
+		tp = otel.GetTracerProvider()
+	}
+	if mp == nil {
+		mp = otel.GetMeterProvider()
+	}
+	meter := mp.Meter(instrumentationName)
+	requests, err := meter.Int64Counter("tuihub.porter.http.client.requests",
+		metric.WithDescription("Outbound HTTP requests of porter handlers."))
+	if err != nil {
+		requests, _ = noop.Meter{}.Int64Counter("")
+	}
+	duration, err := meter.Float64Histogram("tuihub.porter.http.client.duration",
+		metric.WithDescription("Duration of outbound HTTP requests of porter handlers."),
+		metric.WithUnit("s"))
+	if err != nil {
+		duration, _ = noop.Meter{}.Float64Histogram("")
+	}
+	return &telemetryTransport{
+		next:      next,
+		userAgent: userAgent,
+		tracer:    tp.Tracer(instrumentationName),
+		requests:  requests,
+		duration:  duration,
+	}
+}
+
+func (t *telemetryTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	attrs := []attribute.KeyValue{
+		attribute.String("http.request.method", req.Method),
+		attribute.String("server.address", req.URL.Hostname()),
+	}
+	if tr, ok := transport.FromServerContext(req.Context()); ok {
+		attrs = append(attrs, OperationKey.String(tr.Operation()))
+	}
+	ctx, span := t.tracer.Start(req.Context(), "HTTP "+req.Method,
+		trace.WithSpanKind(trace.SpanKindClient),
+		trace.WithAttributes(attrs...),
+		trace.WithAttributes(attribute.String("url.full", redactURL(req))),
+	)
+	defer span.End()
+
+	r := req.WithContext(ctx)
+	if t.userAgent != "" && req.Header.Get("User-Agent") == "" {
+		r = req.Clone(ctx)
+		r.Header.Set("User-Agent", t.userAgent)
+	}
+	start := time.Now()
+	resp, err := t.next.RoundTrip(r)
+	if err != nil {
+		span.RecordError(err)
+		span.SetStatus(codes.Error, err.Error())
+		attrs = append(attrs, attribute.String("error.type", "transport"))
+	} else {
+		attrs = append(attrs, attribute.Int("http.response.status_code", resp.StatusCode))
+		span.SetAttributes(attribute.Int("http.response.status_code", resp.StatusCode),
+			attribute.Bool("tuihub.http.cache_hit", resp.Header.Get(CacheHeader) != ""))
+		if resp.StatusCode >= http.StatusInternalServerError {
+			span.SetStatus(codes.Error, resp.Status)
+		}
+	}
+	set := metric.WithAttributes(attrs...)
+	t.requests.Add(ctx, 1, set)
+	t.duration.Record(ctx, time.Since(start).Seconds(), set)
+	return resp, err
+}
+
+// redactURL drops the query, which often carries API keys.
+func redactURL(req *http.Request) string {
+	u := *req.URL
+	u.User = nil
+	u.RawQuery = ""
+	u.Fragment = ""
+	return u.String()
+}
@@ -0,0 +1,492 @@
+// Package jobs runs long porter operations in the background so that they outlive the RPC deadline.
+//
+// A handler wraps its work with Do. The first call starts a job and waits for it until shortly
+// before the RPC deadline, then returns errors.JobPending, which Sephirah retries. A retried call
+// with the same request finds the job and returns its result once it has finished:
+//
+//	func (h *handler) PullAccountAppInfoRelation(ctx context.Context, req *porter.PullAccountAppInfoRelationRequest) (
+//		*porter.PullAccountAppInfoRelationResponse, error) {
+//		return jobs.Do(ctx, h.jobs, req, func(ctx context.Context, p *jobs.Progress) (
+//			*porter.PullAccountAppInfoRelationResponse, error) {
+//			return h.pullLibrary(ctx, req, p)
+//		})
+//	}
+//
+// Job states are kept in a pluggable Store, use a FileStore to keep finished results across restarts.
+package jobs
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/tuihub/tuihub-go/errors"
+	"github.com/tuihub/tuihub-go/logger"
+
+	kerrors "github.com/go-kratos/kratos/v2/errors"
+	"github.com/go-kratos/kratos/v2/transport"
+	"google.golang.org/protobuf/proto"
+)
+
+const (
+	defaultTimeout    = time.Hour
+	defaultRetention  = 10 * time.Minute
+	defaultRetryAfter = 10 * time.Second
+	defaultWaitMargin = 5 * time.Second
+)
+
+type State string
+
+const (
+	StateRunning   State = "running"
+	StateSucceeded State = "succeeded"
+	StateFailed    State = "failed"
+	StateCanceled  State = "canceled"
+)
+
+// Job is the state of a job.
+type Job struct {
+	ID    string `json:"id"`
+	State State  `json:"state"`
+	// Done, Total and Message are the last progress reported by the job.
+	Done    int64  `json:"done,omitempty"`
+	Total   int64  `json:"total,omitempty"`
+	Message string `json:"message,omitempty"`
+	// Result is the wire encoding of the result of a succeeded job.
+	Result []byte `json:"result,omitempty"`
+	// Error is set for failed and canceled jobs.
+	Error     *JobError `json:"error,omitempty"`
+	CreatedAt time.Time `json:"created_at"`
+	UpdatedAt time.Time `json:"updated_at"`
+}
+
+// JobError keeps the code, reason and message of the error a job returned.
+type JobError struct {
+	Code    int32  `json:"code"`
+	Reason  string `json:"reason,omitempty"`
+	Message string `json:"message,omitempty"`
+}
+
+// Finished reports whether the job is no longer running.
+func (j *Job) Finished() bool {
+	return j.State != StateRunning
+}
+
+// Err returns the error of a failed or canceled job.
+func (j *Job) Err() error {
+	if j.Error == nil {
+		return nil
+	}
+	return kerrors.New(int(j.Error.Code), j.Error.Reason, j.Error.Message)
+}
+
+// UnmarshalResult decodes the result of a succeeded job into m.
+func (j *Job) UnmarshalResult(m proto.Message) error {
+	if j.State != StateSucceeded {
+		return fmt.Errorf("job %s is %s", j.ID, j.State)
+	}
+	return proto.Unmarshal(j.Result, m)
+}
+
+func (j *Job) clone() *Job {
+	c := *j
+	c.Result = append([]byte(nil), j.Result...)
+	if j.Error != nil {
+		e := *j.Error
+		c.Error = &e
+	}
+	return &c
+}
+
+// Func is the work of a job. ctx is canceled by Manager.Cancel or when the job times out,
+// but not when the RPC that started the job returns.
+type Func func(ctx context.Context, p *Progress) (proto.Message, error)
+
+type Manager struct {
+	store      Store
+	timeout    time.Duration
+	retention  time.Duration
+	retryAfter time.Duration
+	notify     func(context.Context, *Job)
+	now        func() time.Time
+
+	mu        sync.Mutex
+	running   map[string]*run
+	lastPurge time.Time
+}
+
+type run struct {
+	job    *Job
+	cancel context.CancelFunc
+	done   chan struct{}
+}
+
+type Option func(*Manager)
+
+// WithTimeout limits how long a job runs, defaults to one hour.
+func WithTimeout(d time.Duration) Option {
+	return func(m *Manager) {
+		if d > 0 {
+			m.timeout = d
+		}
+	}
+}
+
+// WithRetention sets how long finished jobs are kept, defaults to ten minutes.
+// A request repeated after that starts a new job, and the state is deleted by Purge.
+func WithRetention(d time.Duration) Option {
+	return func(m *Manager) {
+		if d > 0 {
+			m.retention = d
+		}
+	}
+}
+
+// WithRetryAfter sets the retry delay suggested by errors.JobPending, defaults to ten seconds.
+func WithRetryAfter(d time.Duration) Option {
+	return func(m *Manager) {
+		if d > 0 {
+			m.retryAfter = d
+		}
+	}
+}
+
+// WithNotify calls f after a job has finished, for example to push the result to Sephirah
+// with Porter.ReverseCall instead of waiting for a retry.
+func WithNotify(f func(ctx context.Context, job *Job)) Option {
+	return func(m *Manager) {
+		m.notify = f
+	}
+}
+
+// New creates a Manager keeping job states in store, a MemoryStore if store is nil.
+func New(store Store, options ...Option) *Manager {
+	if store == nil {
+		store = NewMemoryStore()
+	}
+	m := &Manager{
+		store:      store,
+		timeout:    defaultTimeout,
+		retention:  defaultRetention,
+		retryAfter: defaultRetryAfter,
+		notify:     nil,
+		now:        time.Now,
+		mu:         sync.Mutex{},
+		running:    make(map[string]*run),
+		lastPurge:  time.Time{},
+	}
+	for _, o := range options {
+		o(m)
+	}
+	return m
+}
+
+// Key returns the job id of req for the RPC operation in ctx.
+func Key(ctx context.Context, req proto.Message) (string, error) {
+	data, err := proto.MarshalOptions{Deterministic: true}.Marshal(req) //nolint:exhaustruct // defaults
+	if err != nil {
+		return "", err
+	}
+	h := sha256.New()
+	if tr, ok := transport.FromServerContext(ctx); ok {
+		h.Write([]byte(tr.Operation()))
+	}
+	h.Write([]byte{0})
+	h.Write([]byte(proto.MessageName(req)))
+	h.Write([]byte{0})
+	h.Write(data)
+	return hex.EncodeToString(h.Sum(nil)), nil
+}
+
+// Start starts f as job id unless a job with id is running or has finished within the retention.
+// It returns the state of the job. Expired jobs are purged in the background at most once per retention.
+func (m *Manager) Start(ctx context.Context, id string, f Func) (*Job, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if m.now().Sub(m.lastPurge) > m.retention {
+		m.lastPurge = m.now()
+		go func() {
+			purgeCtx := context.WithoutCancel(ctx)
+			if err := m.Purge(purgeCtx); err != nil {
+				logger.FromContext(purgeCtx).Warnw("purge jobs", "error", err)
+			}
+		}()
+	}
+	if job := m.runJob(id); job != nil {
+		return job, nil
+	}
+	job, err := m.store.Load(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	// a running job not in m.running was interrupted by a restart and is started again
+	if job != nil && job.Finished() && !m.expired(job) {
+		return job, nil
+	}
+	now := m.now()
+	job = &Job{
+		ID:        id,
+		State:     StateRunning,
+		Done:      0,
+		Total:     0,
+		Message:   "",
+		Result:    nil,
+		Error:     nil,
+		CreatedAt: now,
+		UpdatedAt: now,
+	}
+	if err = m.store.Save(ctx, job); err != nil {
+		return nil, err
+	}
+	jobCtx, cancel := context.WithTimeout(context.WithoutCancel(ctx), m.timeout)
+	r := &run{job: job, cancel: cancel, done: make(chan struct{})}
+	m.running[id] = r
+	go m.run(jobCtx, r, f)
+	return job.clone(), nil
+}
+
+// runJob returns the job of a run in m.running, dropping finished runs past the retention.
+// m.mu must be held.
+func (m *Manager) runJob(id string) *Job {
+	r, ok := m.running[id]
+	if !ok {
+		return nil
+	}
+	if r.job.Finished() && m.expired(r.job) {
+		delete(m.running, id)
+		return nil
+	}
+	return r.job.clone()
+}
+
+func (m *Manager) run(ctx context.Context, r *run, f Func) {
+	defer r.cancel()
+	result, err := m.call(ctx, r, f)
+
+	m.mu.Lock()
+	job := r.job
+	job.UpdatedAt = m.now()
+	switch {
+	case err == nil:
+		job.State = StateSucceeded
+		job.Result, err = proto.Marshal(result)
+		if err != nil {
+			job.State = StateFailed
+			err = errors.Internal("marshal job result: %v", err)
+		}
+	case ctx.Err() != nil && kerrors.Is(ctx.Err(), context.Canceled):
+		job.State = StateCanceled
+	default:
+		job.State = StateFailed
+	}
+	if err != nil {
+		se := errors.FromError(err)
+		job.Error = &JobError{Code: se.Code, Reason: se.Reason, Message: se.Message}
+	}
+	snapshot := job.clone()
+	m.mu.Unlock()
+
+	saveCtx := context.WithoutCancel(ctx)
+	saveErr := m.store.Save(saveCtx, snapshot)
+	if saveErr != nil {
+		// the store still says running, keep the finished job in memory until it is forgotten or expires
+		logger.FromContext(ctx).Errorw("save job", "job_id", snapshot.ID, "error", saveErr)
+	}
+	m.mu.Lock()
+	if saveErr == nil {
+		delete(m.running, snapshot.ID)
+	}
+	m.mu.Unlock()
+	close(r.done)
+	if m.notify != nil {
+		m.notify(saveCtx, snapshot)
+	}
+}
+
+func (m *Manager) call(ctx context.Context, r *run, f Func) (result proto.Message, err error) {
+	defer func() {
+		if rerr := recover(); rerr != nil {
+			logger.FromContext(ctx).Errorw("job panic", "job_id", r.job.ID, "panic", rerr)
+			err = errors.Internal("job panic: %v", rerr)
+		}
+	}()
+	return f(ctx, &Progress{m: m, r: r})
+}
+
+// Get returns the state of job id. Expired jobs are deleted.
+func (m *Manager) Get(ctx context.Context, id string) (*Job, error) {
+	m.mu.Lock()
+	if job := m.runJob(id); job != nil {
+		m.mu.Unlock()
+		return job, nil
+	}
+	m.mu.Unlock()
+	job, err := m.store.Load(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	if job != nil && job.Finished() && m.expired(job) {
+		if err = m.store.Delete(ctx, id); err != nil {
+			return nil, err
+		}
+		job = nil
+	}
+	if job == nil {
+		return nil, errors.NotFound("job %s not found", id)
+	}
+	return job, nil
+}
+
+// Wait waits until job id has finished or ctx is done and returns its state.
+func (m *Manager) Wait(ctx context.Context, id string) (*Job, error) {
+	m.mu.Lock()
+	r, ok := m.running[id]
+	m.mu.Unlock()
+	if ok {
+		select {
+		case <-r.done:
+		case <-ctx.Done():
+			return m.Get(context.WithoutCancel(ctx), id)
+		}
+	}
+	return m.Get(ctx, id)
+}
+
+// Cancel cancels job id and waits for it to return.
+func (m *Manager) Cancel(ctx context.Context, id string) error {
+	m.mu.Lock()
+	r, ok := m.running[id]
+	m.mu.Unlock()
+	if !ok {
+		_, err := m.Get(ctx, id)
+		return err
+	}
+	r.cancel()
+	select {
+	case <-r.done:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// Forget deletes the state of a finished job so that the same request starts a new one.
+func (m *Manager) Forget(ctx context.Context, id string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if r, ok := m.running[id]; ok {
+		if !r.job.Finished() {
+			return errors.InvalidArgument("job %s is running", id)
+		}
+		delete(m.running, id)
+	}
+	return m.store.Delete(ctx, id)
+}
+
+// Purge deletes jobs that finished longer than the retention ago, and jobs interrupted by a restart
+// that would have timed out by then.
+func (m *Manager) Purge(ctx context.Context) error {
+	m.mu.Lock()
+	running := make(map[string]bool, len(m.running))
+	for id := range m.running {
+		if m.runJob(id) != nil {
+			running[id] = true
+		}
+	}
+	m.mu.Unlock()
+	return m.store.Purge(ctx, func(job *Job) bool {
+		switch {
+		case running[job.ID]:
+			return false
+		case job.Finished():
+			return m.expired(job)
+		default:
+			return m.now().Sub(job.UpdatedAt) > m.timeout+m.retention
+		}
+	})
+}
+
+func (m *Manager) expired(job *Job) bool {
+	return m.now().Sub(job.UpdatedAt) > m.retention
+}
+
+// Progress reports the progress of a running job.
+type Progress struct {
+	m *Manager
+	r *run
+}
+
+// Update sets the progress of the job, returned by Manager.Get and in JobPending errors.
+// It is saved to the store, so avoid calling it for every item of a large batch.
+func (p *Progress) Update(ctx context.Context, done, total int64, message string) {
+	p.m.mu.Lock()
+	p.r.job.Done = done
+	p.r.job.Total = total
+	p.r.job.Message = message
+	p.r.job.UpdatedAt = p.m.now()
+	snapshot := p.r.job.clone()
+	p.m.mu.Unlock()
+	if err := p.m.store.Save(ctx, snapshot); err != nil {
+		logger.FromContext(ctx).Warnw("save job progress", "job_id", snapshot.ID, "error", err)
+	}
+}
+
+// Do runs f as the job of req and returns its result if it finishes before the RPC deadline,
+// errors.JobPending otherwise. Jobs are forgotten once their result or error is returned,
+// so the next identical request starts over.
+func Do[T proto.Message](
+	ctx context.Context,
+	m *Manager,
+	req proto.Message,
+	f func(ctx context.Context, p *Progress) (T, error),
+) (T, error) {
+	var zero T
+	id, err := Key(ctx, req)
+	if err != nil {
+		return zero, errors.Internal("job key: %v", err)
+	}
+	job, err := m.Start(ctx, id, func(ctx context.Context, p *Progress) (proto.Message, error) {
+		return f(ctx, p)
+	})
+	if err != nil {
+		return zero, err
+	}
+	if !job.Finished() {
+		waitCtx, cancel := waitContext(ctx)
+		job, err = m.Wait(waitCtx, id)
+		cancel()
+		if err != nil {
+			return zero, err
+		}
+	}
+	switch job.State {
+	case StateRunning:
+		return zero, errors.JobPending(id, m.retryAfter, "job is running, %d/%d done", job.Done, job.Total)
+	case StateSucceeded:
+		result := zero.ProtoReflect().New().Interface().(T) //nolint:errcheck // same type
+		if err = job.UnmarshalResult(result); err != nil {
+			return zero, errors.Internal("unmarshal job result: %v", err)
+		}
+		_ = m.Forget(context.WithoutCancel(ctx), id)
+		return result, nil
+	case StateFailed, StateCanceled:
+	}
+	_ = m.Forget(context.WithoutCancel(ctx), id)
+	return zero, job.Err()
+}
+
+// waitContext returns a context done shortly before the deadline of ctx, leaving time to reply.
+func waitContext(ctx context.Context) (context.Context, context.CancelFunc) {
+	deadline, ok := ctx.Deadline()
+	if !ok {
+		return context.WithCancel(ctx)
+	}
+	margin := defaultWaitMargin
+	if remaining := time.Until(deadline); remaining < 2*margin {
+		margin = remaining / 2 //nolint:mnd // half of the remaining time
+	}
+	return context.WithDeadline(ctx, deadline.Add(-margin))
+}
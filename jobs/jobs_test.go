@@ -0,0 +1,193 @@
+package jobs
+
+import (
+	"context"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	porter "github.com/tuihub/protos/pkg/librarian/porter/v1"
+	librarian "github.com/tuihub/protos/pkg/librarian/v1"
+	"github.com/tuihub/tuihub-go/errors"
+
+	"google.golang.org/protobuf/proto"
+)
+
+func feedRequest(id string) *porter.PullFeedRequest {
+	return &porter.PullFeedRequest{Source: &librarian.FeatureRequest{Id: id}}
+}
+
+func TestDoPending(t *testing.T) {
+	m := New(nil)
+	release := make(chan struct{})
+	var calls atomic.Int32
+	pull := func(ctx context.Context, p *Progress) (*porter.PullFeedResponse, error) {
+		calls.Add(1)
+		p.Update(ctx, 1, 2, "halfway")
+		<-release
+		return &porter.PullFeedResponse{Data: &librarian.Feed{Title: "done"}}, nil
+	}
+
+	// the RPC deadline passes while the job is running
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	_, err := Do(ctx, m, feedRequest("rss"), pull)
+	if !errors.IsJobPending(err) {
+		t.Fatalf("err = %v, want JobPending", err)
+	}
+	id := errors.FromError(err).GetMetadata()[errors.MetadataJobID]
+	job, err := m.Get(context.Background(), id)
+	if err != nil || job.State != StateRunning || job.Done != 1 || job.Total != 2 {
+		t.Fatalf("Get = %+v, %v", job, err)
+	}
+
+	close(release)
+	resp, err := Do(context.Background(), m, feedRequest("rss"), pull)
+	if err != nil || resp.GetData().GetTitle() != "done" {
+		t.Errorf("retry = %v, %v", resp, err)
+	}
+	if calls.Load() != 1 {
+		t.Errorf("calls = %d, want 1", calls.Load())
+	}
+}
+
+func TestDoFailed(t *testing.T) {
+	m := New(nil)
+	var calls atomic.Int32
+	pull := func(context.Context, *Progress) (*porter.PullFeedResponse, error) {
+		if calls.Add(1) == 1 {
+			return nil, errors.UpstreamUnavailable("down")
+		}
+		panic("bug")
+	}
+	ctx := context.Background()
+	if _, err := Do(ctx, m, feedRequest("rss"), pull); errors.Reason(err) != errors.ReasonUpstreamUnavailable {
+		t.Errorf("err = %v", err)
+	}
+	// failed jobs are forgotten, the retry runs again
+	if _, err := Do(ctx, m, feedRequest("rss"), pull); errors.Code(err) != errors.Code(errors.Internal("")) {
+		t.Errorf("panic: err = %v", err)
+	}
+	if calls.Load() != 2 {
+		t.Errorf("calls = %d, want 2", calls.Load())
+	}
+}
+
+func TestCancel(t *testing.T) {
+	m := New(nil)
+	ctx := context.Background()
+	job, err := m.Start(ctx, "a", func(ctx context.Context, _ *Progress) (proto.Message, error) {
+		<-ctx.Done()
+		return nil, ctx.Err()
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err = m.Cancel(ctx, job.ID); err != nil {
+		t.Fatal(err)
+	}
+	job, err = m.Get(ctx, job.ID)
+	if err != nil || job.State != StateCanceled || errors.Code(job.Err()) != errors.Code(context.Canceled) {
+		t.Errorf("Get = %+v, %v", job, err)
+	}
+	if err = m.Cancel(ctx, "unknown"); !errors.IsNotFound(err) {
+		t.Errorf("unknown job: err = %v", err)
+	}
+}
+
+func TestFileStore(t *testing.T) {
+	dir := t.TempDir()
+	store, err := NewFileStore(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ctx := context.Background()
+	var notified atomic.Value
+	m := New(store, WithNotify(func(_ context.Context, job *Job) {
+		notified.Store(job.ID)
+	}))
+	id, _ := Key(ctx, feedRequest("rss"))
+	if _, err = m.Start(ctx, id, func(context.Context, *Progress) (proto.Message, error) {
+		return &porter.PullFeedResponse{Data: &librarian.Feed{Title: "stored"}}, nil
+	}); err != nil {
+		t.Fatal(err)
+	}
+	if _, err = m.Wait(ctx, id); err != nil {
+		t.Fatal(err)
+	}
+	if notified.Load() != id {
+		t.Errorf("notified = %v, want %s", notified.Load(), id)
+	}
+
+	// a new manager over the same directory returns the stored result without running the job
+	store, _ = NewFileStore(dir)
+	again := func(context.Context, *Progress) (*porter.PullFeedResponse, error) {
+		t.Error("job ran again")
+		return nil, nil
+	}
+	resp, err := Do(ctx, New(store), feedRequest("rss"), again)
+	if err != nil || resp.GetData().GetTitle() != "stored" {
+		t.Errorf("Do = %v, %v", resp, err)
+	}
+	// the returned result is deleted
+	if job, _ := store.Load(ctx, id); job != nil {
+		t.Errorf("job kept after its result was returned: %+v", job)
+	}
+}
+
+func TestPurge(t *testing.T) {
+	ctx := context.Background()
+	store, err := NewFileStore(t.TempDir())
+	if err != nil {
+		t.Fatal(err)
+	}
+	m := New(store, WithRetention(time.Minute), WithTimeout(time.Minute))
+	now := time.Now()
+	for _, job := range []*Job{
+		{ID: "recent", State: StateSucceeded, UpdatedAt: now},
+		{ID: "expired", State: StateFailed, UpdatedAt: now.Add(-2 * time.Minute)},
+		{ID: "interrupted", State: StateRunning, UpdatedAt: now.Add(-3 * time.Minute)},
+	} {
+		if err = store.Save(ctx, job); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err = m.Purge(ctx); err != nil {
+		t.Fatal(err)
+	}
+	for id, kept := range map[string]bool{"recent": true, "expired": false, "interrupted": false} {
+		if job, _ := store.Load(ctx, id); (job != nil) != kept {
+			t.Errorf("%s: kept = %v, want %v", id, job != nil, kept)
+		}
+	}
+}
+
+// failingStore fails to save finished jobs.
+type failingStore struct {
+	*MemoryStore
+}
+
+func (s failingStore) Save(ctx context.Context, job *Job) error {
+	if job.Finished() {
+		return errors.Internal("disk full")
+	}
+	return s.MemoryStore.Save(ctx, job)
+}
+
+func TestSaveFailure(t *testing.T) {
+	ctx := context.Background()
+	m := New(failingStore{NewMemoryStore()})
+	var calls atomic.Int32
+	pull := func(context.Context, *Progress) (*porter.PullFeedResponse, error) {
+		calls.Add(1)
+		return &porter.PullFeedResponse{Data: &librarian.Feed{Title: "done"}}, nil
+	}
+	// the finished job is kept in memory, so Do returns its result instead of JobPending
+	resp, err := Do(ctx, m, feedRequest("rss"), pull)
+	if err != nil || resp.GetData().GetTitle() != "done" {
+		t.Errorf("Do = %v, %v", resp, err)
+	}
+	if _, err = Do(ctx, m, feedRequest("rss"), pull); err != nil || calls.Load() != 2 {
+		t.Errorf("second Do: calls = %d, err = %v", calls.Load(), err)
+	}
+}
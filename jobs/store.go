@@ -0,0 +1,168 @@
+package jobs
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+)
+
+// staleTempAge is how old a temporary file must be before Purge removes it, so saves in progress are kept.
+const staleTempAge = time.Hour
+
+// Store persists job states. Load returns nil without error for unknown ids.
+type Store interface {
+	Load(ctx context.Context, id string) (*Job, error)
+	Save(ctx context.Context, job *Job) error
+	Delete(ctx context.Context, id string) error
+	// Purge deletes the jobs for which expired returns true.
+	Purge(ctx context.Context, expired func(job *Job) bool) error
+}
+
+// MemoryStore keeps jobs in memory, they are lost when the porter restarts.
+type MemoryStore struct {
+	mu   sync.RWMutex
+	jobs map[string]*Job
+}
+
+func NewMemoryStore() *MemoryStore {
+	return &MemoryStore{
+		mu:   sync.RWMutex{},
+		jobs: make(map[string]*Job),
+	}
+}
+
+func (s *MemoryStore) Load(_ context.Context, id string) (*Job, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	job, ok := s.jobs[id]
+	if !ok {
+		return nil, nil //nolint:nilnil // unknown id
+	}
+	return job.clone(), nil
+}
+
+func (s *MemoryStore) Save(_ context.Context, job *Job) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.jobs[job.ID] = job.clone()
+	return nil
+}
+
+func (s *MemoryStore) Delete(_ context.Context, id string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	delete(s.jobs, id)
+	return nil
+}
+
+func (s *MemoryStore) Purge(_ context.Context, expired func(job *Job) bool) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for id, job := range s.jobs {
+		if expired(job.clone()) {
+			delete(s.jobs, id)
+		}
+	}
+	return nil
+}
+
+// FileStore keeps each job as a JSON file in a directory.
+type FileStore struct {
+	dir string
+}
+
+// NewFileStore creates dir if it does not exist.
+func NewFileStore(dir string) (*FileStore, error) {
+	if err := os.MkdirAll(dir, 0o700); err != nil { //nolint:mnd // owner only
+		return nil, err
+	}
+	return &FileStore{dir: dir}, nil
+}
+
+func (s *FileStore) path(id string) string {
+	return filepath.Join(s.dir, filepath.Base(id)+".json")
+}
+
+func (s *FileStore) Load(_ context.Context, id string) (*Job, error) {
+	data, err := os.ReadFile(s.path(id))
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, nil //nolint:nilnil // unknown id
+	}
+	if err != nil {
+		return nil, err
+	}
+	job := new(Job)
+	if err = json.Unmarshal(data, job); err != nil {
+		return nil, err
+	}
+	return job, nil
+}
+
+// Save writes to a temporary file renamed into place so that Load never sees a partial job.
+func (s *FileStore) Save(_ context.Context, job *Job) error {
+	data, err := json.Marshal(job)
+	if err != nil {
+		return err
+	}
+	f, err := os.CreateTemp(s.dir, filepath.Base(job.ID)+".*.tmp")
+	if err != nil {
+		return err
+	}
+	_, err = f.Write(data)
+	if closeErr := f.Close(); err == nil {
+		err = closeErr
+	}
+	if err == nil {
+		err = os.Rename(f.Name(), s.path(job.ID))
+	}
+	if err != nil {
+		_ = os.Remove(f.Name())
+	}
+	return err
+}
+
+func (s *FileStore) Delete(_ context.Context, id string) error {
+	return s.remove(s.path(id))
+}
+
+// Purge also removes temporary files left by interrupted saves.
+func (s *FileStore) Purge(ctx context.Context, expired func(job *Job) bool) error {
+	entries, err := os.ReadDir(s.dir)
+	if err != nil {
+		return err
+	}
+	var errs []error
+	for _, e := range entries {
+		name := e.Name()
+		switch {
+		case e.IsDir():
+		case strings.HasSuffix(name, ".tmp"):
+			if info, ierr := e.Info(); ierr == nil && time.Since(info.ModTime()) > staleTempAge {
+				errs = append(errs, s.remove(filepath.Join(s.dir, name)))
+			}
+		case strings.HasSuffix(name, ".json"):
+			job, lerr := s.Load(ctx, strings.TrimSuffix(name, ".json"))
+			if lerr != nil {
+				errs = append(errs, lerr)
+				continue
+			}
+			if job != nil && expired(job) {
+				errs = append(errs, s.remove(filepath.Join(s.dir, name)))
+			}
+		}
+	}
+	return errors.Join(errs...)
+}
+
+func (s *FileStore) remove(path string) error {
+	err := os.Remove(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil
+	}
+	return err
+}
@@ -0,0 +1,155 @@
+// Package boltkv registers the bolt backend of kv.Open, which keeps keys in a bbolt file
+// named porter.db in the data directory.
+package boltkv
+
+import (
+	"bytes"
+	"context"
+	"encoding/binary"
+	"path/filepath"
+	"time"
+
+	"github.com/tuihub/tuihub-go/kv"
+
+	bolt "go.etcd.io/bbolt"
+)
+
+const expiryLen = 8
+
+var boltBucket = []byte("kv")
+
+func init() {
+	kv.Register(kv.BackendBolt, func(dir string) (kv.Store, error) {
+		return Open(filepath.Join(dir, "porter.db"))
+	})
+}
+
+// Bolt keeps keys in a bbolt file. Values are stored after their expiry in Unix nanoseconds.
+type Bolt struct {
+	db  *bolt.DB
+	now func() time.Time
+}
+
+// Open opens or creates the bbolt file at path. The file is locked while it is open.
+func Open(path string) (*Bolt, error) {
+	db, err := bolt.Open(path, 0o600, &bolt.Options{Timeout: time.Second}) //nolint:exhaustruct,mnd // defaults
+	if err != nil {
+		return nil, err
+	}
+	if err = db.Update(func(tx *bolt.Tx) error {
+		_, err = tx.CreateBucketIfNotExists(boltBucket)
+		return err
+	}); err != nil {
+		_ = db.Close()
+		return nil, err
+	}
+	return &Bolt{db: db, now: time.Now}, nil
+}
+
+// get returns the unexpired value of key in b.
+func (s *Bolt) get(b *bolt.Bucket, key string) ([]byte, bool) {
+	v := b.Get([]byte(key))
+	if v == nil {
+		return nil, false
+	}
+	value, expiry := decodeBolt(v)
+	if expired(s.now(), expiry) {
+		return nil, false
+	}
+	return value, true
+}
+
+func (s *Bolt) Get(_ context.Context, key string) ([]byte, error) {
+	var value []byte
+	err := s.db.View(func(tx *bolt.Tx) error {
+		v, ok := s.get(tx.Bucket(boltBucket), key)
+		if !ok {
+			return kv.ErrNotFound
+		}
+		value = bytes.Clone(v)
+		return nil
+	})
+	return value, err
+}
+
+func (s *Bolt) Put(_ context.Context, key string, value []byte, ttl time.Duration) error {
+	return s.db.Update(func(tx *bolt.Tx) error {
+		return tx.Bucket(boltBucket).Put([]byte(key), encodeBolt(value, expiresAt(s.now(), ttl)))
+	})
+}
+
+func (s *Bolt) Delete(_ context.Context, key string) error {
+	return s.db.Update(func(tx *bolt.Tx) error {
+		return tx.Bucket(boltBucket).Delete([]byte(key))
+	})
+}
+
+func (s *Bolt) List(_ context.Context, prefix string) ([]kv.Entry, error) {
+	var entries []kv.Entry
+	err := s.db.View(func(tx *bolt.Tx) error {
+		c := tx.Bucket(boltBucket).Cursor()
+		p := []byte(prefix)
+		for k, v := c.Seek(p); k != nil && bytes.HasPrefix(k, p); k, v = c.Next() {
+			value, expiry := decodeBolt(v)
+			if expired(s.now(), expiry) {
+				continue
+			}
+			entries = append(entries, kv.Entry{Key: string(k), Value: bytes.Clone(value), ExpiresAt: expiry})
+		}
+		return nil
+	})
+	return entries, err
+}
+
+func (s *Bolt) CompareAndSwap(_ context.Context, key string, old, value []byte, ttl time.Duration) (bool, error) {
+	swapped := false
+	err := s.db.Update(func(tx *bolt.Tx) error {
+		b := tx.Bucket(boltBucket)
+		current, ok := s.get(b, key)
+		if ok != (old != nil) || (ok && !bytes.Equal(current, old)) {
+			return nil
+		}
+		swapped = true
+		if value == nil {
+			return b.Delete([]byte(key))
+		}
+		return b.Put([]byte(key), encodeBolt(value, expiresAt(s.now(), ttl)))
+	})
+	return swapped, err
+}
+
+func (s *Bolt) Close() error {
+	return s.db.Close()
+}
+
+func encodeBolt(value []byte, expiry time.Time) []byte {
+	v := make([]byte, expiryLen+len(value))
+	if !expiry.IsZero() {
+		binary.BigEndian.PutUint64(v, uint64(expiry.UnixNano()))
+	}
+	copy(v[expiryLen:], value)
+	return v
+}
+
+func decodeBolt(v []byte) ([]byte, time.Time) {
+	if len(v) < expiryLen {
+		return v, time.Time{}
+	}
+	var expiry time.Time
+	if n := binary.BigEndian.Uint64(v); n != 0 {
+		expiry = time.Unix(0, int64(n))
+	}
+	return v[expiryLen:], expiry
+}
+
+// expiresAt returns the expiry of a key put at now with ttl, zero for no ttl.
+func expiresAt(now time.Time, ttl time.Duration) time.Time {
+	if ttl <= 0 {
+		return time.Time{}
+	}
+	return now.Add(ttl)
+}
+
+func expired(now, expiresAt time.Time) bool {
+	return !expiresAt.IsZero() && !now.Before(expiresAt)
+}
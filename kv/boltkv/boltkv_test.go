@@ -0,0 +1,46 @@
+package boltkv
+
+import (
+	"context"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/tuihub/tuihub-go/kv"
+	"github.com/tuihub/tuihub-go/kv/internal/kvtest"
+)
+
+func TestBolt(t *testing.T) {
+	kvtest.Run(t, func(t *testing.T, now func() time.Time) kv.Store {
+		s, err := Open(filepath.Join(t.TempDir(), "porter.db"))
+		if err != nil {
+			t.Fatal(err)
+		}
+		s.now = now
+		return s
+	})
+}
+
+func TestOpen(t *testing.T) {
+	dir := filepath.Join(t.TempDir(), "data")
+	t.Setenv("PORTER_DATA_DIR", dir)
+	t.Setenv("PORTER_KV_BACKEND", "")
+	c := kv.ConfigFromEnv()
+	if c.Backend != kv.BackendBolt {
+		t.Fatalf("default backend = %s", c.Backend)
+	}
+	s, err := kv.Open(c)
+	if err != nil {
+		t.Fatal(err)
+	}
+	_ = s.Put(context.Background(), "k", []byte("v"), 0)
+	_ = s.Close()
+	s, err = kv.Open(c)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer s.Close()
+	if v, _ := s.Get(context.Background(), "k"); string(v) != "v" {
+		t.Errorf("reopened value = %q", v)
+	}
+}
@@ -0,0 +1,9 @@
+package kv
+
+import "time"
+
+func NewMemoryAt(now func() time.Time) *Memory {
+	m := NewMemory()
+	m.now = now
+	return m
+}
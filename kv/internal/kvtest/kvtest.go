@@ -0,0 +1,135 @@
+// Package kvtest checks that kv backends behave alike.
+package kvtest
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/tuihub/tuihub-go/kv"
+)
+
+// OpenFunc opens an empty store reading the time from now. The store is closed by the test.
+type OpenFunc func(t *testing.T, now func() time.Time) kv.Store
+
+type clock struct {
+	mu  sync.Mutex
+	now time.Time
+}
+
+func (c *clock) Now() time.Time {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.now
+}
+
+func (c *clock) Add(d time.Duration) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.now = c.now.Add(d)
+}
+
+// Run checks the store returned by open.
+func Run(t *testing.T, open OpenFunc) {
+	t.Helper()
+	for name, test := range map[string]func(*testing.T, kv.Store, *clock){
+		"Store":          testStore,
+		"TTL":            testTTL,
+		"CompareAndSwap": testCompareAndSwap,
+	} {
+		t.Run(name, func(t *testing.T) {
+			c := &clock{mu: sync.Mutex{}, now: time.Now()}
+			s := open(t, c.Now)
+			t.Cleanup(func() {
+				_ = s.Close()
+			})
+			test(t, s, c)
+		})
+	}
+}
+
+func testStore(t *testing.T, s kv.Store, _ *clock) {
+	ctx := context.Background()
+	if _, err := s.Get(ctx, "a"); !errors.Is(err, kv.ErrNotFound) {
+		t.Errorf("Get missing: err = %v", err)
+	}
+	for _, key := range []string{"cursor/2", "cursor/1", "token", "cursor0"} {
+		if err := s.Put(ctx, key, []byte("v-"+key), 0); err != nil {
+			t.Fatalf("Put: %v", err)
+		}
+	}
+	if v, err := s.Get(ctx, "token"); err != nil || string(v) != "v-token" {
+		t.Errorf("Get = %q, %v", v, err)
+	}
+	entries, err := s.List(ctx, "cursor/")
+	if err != nil || len(entries) != 2 || entries[0].Key != "cursor/1" || string(entries[1].Value) != "v-cursor/2" {
+		t.Errorf("List = %+v, %v", entries, err)
+	}
+	if err = s.Delete(ctx, "token"); err != nil {
+		t.Errorf("Delete: %v", err)
+	}
+	if err = s.Delete(ctx, "token"); err != nil {
+		t.Errorf("Delete missing: %v", err)
+	}
+	if _, err = s.Get(ctx, "token"); !errors.Is(err, kv.ErrNotFound) {
+		t.Errorf("Get deleted: err = %v", err)
+	}
+}
+
+func testTTL(t *testing.T, s kv.Store, c *clock) {
+	ctx := context.Background()
+	_ = s.Put(ctx, "session", []byte("x"), time.Minute)
+	_ = s.Put(ctx, "sticky", []byte("y"), 0)
+	entries, _ := s.List(ctx, "s")
+	if len(entries) != 2 || entries[0].ExpiresAt.IsZero() || !entries[1].ExpiresAt.IsZero() {
+		t.Errorf("List = %+v", entries)
+	}
+	c.Add(time.Minute)
+	if _, err := s.Get(ctx, "session"); !errors.Is(err, kv.ErrNotFound) {
+		t.Errorf("expired key: err = %v", err)
+	}
+	if entries, _ = s.List(ctx, "s"); len(entries) != 1 {
+		t.Errorf("List after expiry = %+v", entries)
+	}
+	// an expired key counts as missing
+	if ok, err := s.CompareAndSwap(ctx, "session", nil, []byte("z"), 0); !ok || err != nil {
+		t.Errorf("CompareAndSwap expired = %v, %v", ok, err)
+	}
+}
+
+func testCompareAndSwap(t *testing.T, s kv.Store, _ *clock) {
+	ctx := context.Background()
+	var wg sync.WaitGroup
+	var mu sync.Mutex
+	wins := 0
+	for i := 0; i < 8; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			ok, err := s.CompareAndSwap(ctx, "lock", nil, []byte("held"), 0)
+			if err != nil {
+				t.Errorf("CompareAndSwap: %v", err)
+			}
+			if ok {
+				mu.Lock()
+				wins++
+				mu.Unlock()
+			}
+		}()
+	}
+	wg.Wait()
+	if wins != 1 {
+		t.Errorf("%d goroutines acquired the lock", wins)
+	}
+	if ok, _ := s.CompareAndSwap(ctx, "lock", []byte("other"), nil, 0); ok {
+		t.Error("swapped with a wrong old value")
+	}
+	if ok, _ := s.CompareAndSwap(ctx, "lock", []byte("held"), nil, 0); !ok {
+		t.Error("release failed")
+	}
+	if _, err := s.Get(ctx, "lock"); !errors.Is(err, kv.ErrNotFound) {
+		t.Errorf("released lock: err = %v", err)
+	}
+}
@@ -0,0 +1,200 @@
+// Package kv is a small persistent key-value store for porter state such as upstream cursors,
+// seen-item sets and OAuth credentials.
+//
+// Keys are strings, values are bytes. Porter.KV returns a Store namespaced by the Sephirah that
+// enabled the porter, so that data of different Sephirah instances never mixes.
+//
+// Only the memory backend is linked by default. File backends register with Open when their
+// package is imported:
+//
+//	import _ "github.com/tuihub/tuihub-go/kv/boltkv"
+package kv
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	envDataDir   = "PORTER_DATA_DIR"
+	envKVBackend = "PORTER_KV_BACKEND"
+)
+
+// ErrNotFound is returned by Get for missing and expired keys.
+var ErrNotFound = errors.New("kv: key not found")
+
+// Entry is a key listed by Store.List.
+type Entry struct {
+	Key   string
+	Value []byte
+	// ExpiresAt is zero for keys without TTL.
+	ExpiresAt time.Time
+}
+
+// Store is safe for concurrent use. A ttl of zero keeps a key until it is deleted.
+type Store interface {
+	Get(ctx context.Context, key string) ([]byte, error)
+	Put(ctx context.Context, key string, value []byte, ttl time.Duration) error
+	// Delete succeeds for missing keys.
+	Delete(ctx context.Context, key string) error
+	// List returns the unexpired keys starting with prefix, sorted by key.
+	List(ctx context.Context, prefix string) ([]Entry, error)
+	// CompareAndSwap sets key to value if its current value equals old and reports whether it did.
+	// A nil old requires the key to be missing or expired, a nil value deletes the key.
+	CompareAndSwap(ctx context.Context, key string, old, value []byte, ttl time.Duration) (bool, error)
+	Close() error
+}
+
+type Backend string
+
+const (
+	BackendMemory Backend = "memory"
+	// BackendBolt is registered by github.com/tuihub/tuihub-go/kv/boltkv.
+	BackendBolt Backend = "bolt"
+	// BackendSQLite is registered by github.com/tuihub/tuihub-go/kv/sqlitekv.
+	BackendSQLite Backend = "sqlite"
+)
+
+// OpenFunc opens a file backend in dir, which exists when it is called.
+type OpenFunc func(dir string) (Store, error)
+
+var (
+	backendsMu sync.RWMutex
+	backends   = make(map[Backend]OpenFunc)
+)
+
+// Register makes a file backend available to Open. It is called from the init function of
+// the backend package and panics if backend is registered twice.
+func Register(backend Backend, open OpenFunc) {
+	backendsMu.Lock()
+	defer backendsMu.Unlock()
+	if backend == BackendMemory || backend == "" || open == nil {
+		panic(fmt.Sprintf("kv: invalid backend %q", backend))
+	}
+	if _, exist := backends[backend]; exist {
+		panic(fmt.Sprintf("kv: backend %q registered twice", backend))
+	}
+	backends[backend] = open
+}
+
+// Backends returns the registered file backends, sorted by name.
+func Backends() []Backend {
+	backendsMu.RLock()
+	defer backendsMu.RUnlock()
+	res := make([]Backend, 0, len(backends))
+	for backend := range backends {
+		res = append(res, backend)
+	}
+	sort.Slice(res, func(i, j int) bool { return res[i] < res[j] })
+	return res
+}
+
+func lookupBackend(backend Backend) (OpenFunc, bool) {
+	backendsMu.RLock()
+	defer backendsMu.RUnlock()
+	open, ok := backends[backend]
+	return open, ok
+}
+
+// Config selects the backend of Open.
+type Config struct {
+	Backend Backend
+	// Dir is the data directory of file backends.
+	Dir string
+}
+
+// ConfigFromEnv reads the data directory from PORTER_DATA_DIR and the backend from PORTER_KV_BACKEND.
+// The backend defaults to bolt when a data directory is set and boltkv is imported, and to memory otherwise.
+func ConfigFromEnv() Config {
+	c := Config{
+		Backend: BackendMemory,
+		Dir:     os.Getenv(envDataDir),
+	}
+	if _, linked := lookupBackend(BackendBolt); linked && c.Dir != "" {
+		c.Backend = BackendBolt
+	}
+	if backend, exist := os.LookupEnv(envKVBackend); exist && backend != "" {
+		c.Backend = Backend(strings.ToLower(backend))
+	}
+	return c
+}
+
+// Open opens the store selected by c, creating its data directory if needed.
+func Open(c Config) (Store, error) {
+	if c.Backend == BackendMemory || c.Backend == "" {
+		return NewMemory(), nil
+	}
+	open, ok := lookupBackend(c.Backend)
+	if !ok {
+		return nil, fmt.Errorf("unknown kv backend %q, import its package to register it", c.Backend)
+	}
+	if c.Dir == "" {
+		return nil, fmt.Errorf("kv backend %s requires a data directory", c.Backend)
+	}
+	if err := os.MkdirAll(c.Dir, 0o700); err != nil { //nolint:mnd // owner only
+		return nil, err
+	}
+	return open(c.Dir)
+}
+
+type prefixed struct {
+	Store
+	prefix string
+}
+
+// WithPrefix returns a view of s where every key is prefixed by prefix.
+// Closing the view does not close s.
+func WithPrefix(s Store, prefix string) Store {
+	if p, ok := s.(*prefixed); ok {
+		return &prefixed{Store: p.Store, prefix: p.prefix + prefix}
+	}
+	return &prefixed{Store: s, prefix: prefix}
+}
+
+func (p *prefixed) Get(ctx context.Context, key string) ([]byte, error) {
+	return p.Store.Get(ctx, p.prefix+key)
+}
+
+func (p *prefixed) Put(ctx context.Context, key string, value []byte, ttl time.Duration) error {
+	return p.Store.Put(ctx, p.prefix+key, value, ttl)
+}
+
+func (p *prefixed) Delete(ctx context.Context, key string) error {
+	return p.Store.Delete(ctx, p.prefix+key)
+}
+
+func (p *prefixed) List(ctx context.Context, prefix string) ([]Entry, error) {
+	entries, err := p.Store.List(ctx, p.prefix+prefix)
+	for i := range entries {
+		entries[i].Key = strings.TrimPrefix(entries[i].Key, p.prefix)
+	}
+	return entries, err
+}
+
+func (p *prefixed) CompareAndSwap(
+	ctx context.Context, key string, old, value []byte, ttl time.Duration,
+) (bool, error) {
+	return p.Store.CompareAndSwap(ctx, p.prefix+key, old, value, ttl)
+}
+
+func (p *prefixed) Close() error {
+	return nil
+}
+
+// expiresAt returns the expiry of a key put at now with ttl, zero for no ttl.
+func expiresAt(now time.Time, ttl time.Duration) time.Time {
+	if ttl <= 0 {
+		return time.Time{}
+	}
+	return now.Add(ttl)
+}
+
+func expired(now, expiresAt time.Time) bool {
+	return !expiresAt.IsZero() && !now.Before(expiresAt)
+}
@@ -0,0 +1,64 @@
+package kv_test
+
+import (
+	"context"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/tuihub/tuihub-go/kv"
+	"github.com/tuihub/tuihub-go/kv/internal/kvtest"
+)
+
+func TestMemory(t *testing.T) {
+	kvtest.Run(t, func(_ *testing.T, now func() time.Time) kv.Store {
+		return kv.NewMemoryAt(now)
+	})
+}
+
+func TestPrefix(t *testing.T) {
+	kvtest.Run(t, func(_ *testing.T, now func() time.Time) kv.Store {
+		return kv.WithPrefix(kv.WithPrefix(kv.NewMemoryAt(now), "sephirah/1/"), "steam/")
+	})
+}
+
+func TestOpen(t *testing.T) {
+	dir := filepath.Join(t.TempDir(), "data")
+	var opened string
+	kv.Register("test", func(dir string) (kv.Store, error) {
+		opened = dir
+		return kv.NewMemory(), nil
+	})
+	s, err := kv.Open(kv.Config{Backend: "test", Dir: dir})
+	if err != nil {
+		t.Fatal(err)
+	}
+	_ = s.Close()
+	if opened != dir {
+		t.Errorf("opened in %q", opened)
+	}
+	if s, err = kv.Open(kv.Config{Backend: "", Dir: ""}); err != nil {
+		t.Errorf("memory: err = %v", err)
+	} else if err = s.Put(context.Background(), "k", []byte("v"), 0); err != nil {
+		t.Error(err)
+	}
+	if _, err = kv.Open(kv.Config{Backend: "test", Dir: ""}); err == nil {
+		t.Error("file backend without a data directory accepted")
+	}
+	// bolt is not linked unless boltkv is imported
+	if _, err = kv.Open(kv.Config{Backend: kv.BackendBolt, Dir: dir}); err == nil {
+		t.Error("unregistered backend accepted")
+	}
+}
+
+func TestConfigFromEnv(t *testing.T) {
+	t.Setenv("PORTER_DATA_DIR", t.TempDir())
+	t.Setenv("PORTER_KV_BACKEND", "")
+	if c := kv.ConfigFromEnv(); c.Backend != kv.BackendMemory {
+		t.Errorf("backend without boltkv = %s", c.Backend)
+	}
+	t.Setenv("PORTER_KV_BACKEND", "SQLite")
+	if c := kv.ConfigFromEnv(); c.Backend != kv.BackendSQLite {
+		t.Errorf("backend = %s", c.Backend)
+	}
+}
@@ -0,0 +1,97 @@
+package kv
+
+import (
+	"bytes"
+	"context"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Memory keeps keys in memory, they are lost when the porter restarts.
+type Memory struct {
+	mu      sync.Mutex
+	entries map[string]Entry
+	now     func() time.Time
+}
+
+func NewMemory() *Memory {
+	return &Memory{
+		mu:      sync.Mutex{},
+		entries: make(map[string]Entry),
+		now:     time.Now,
+	}
+}
+
+// get returns the unexpired entry of key, removing it if it has expired. It requires m.mu.
+func (m *Memory) get(key string) (Entry, bool) {
+	e, ok := m.entries[key]
+	if ok && expired(m.now(), e.ExpiresAt) {
+		delete(m.entries, key)
+		return Entry{}, false //nolint:exhaustruct // missing
+	}
+	return e, ok
+}
+
+func (m *Memory) Get(_ context.Context, key string) ([]byte, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	e, ok := m.get(key)
+	if !ok {
+		return nil, ErrNotFound
+	}
+	return bytes.Clone(e.Value), nil
+}
+
+func (m *Memory) Put(_ context.Context, key string, value []byte, ttl time.Duration) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.entries[key] = Entry{Key: key, Value: bytes.Clone(value), ExpiresAt: expiresAt(m.now(), ttl)}
+	return nil
+}
+
+func (m *Memory) Delete(_ context.Context, key string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	delete(m.entries, key)
+	return nil
+}
+
+func (m *Memory) List(_ context.Context, prefix string) ([]Entry, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	var entries []Entry
+	for key := range m.entries {
+		if !strings.HasPrefix(key, prefix) {
+			continue
+		}
+		if e, ok := m.get(key); ok {
+			e.Value = bytes.Clone(e.Value)
+			entries = append(entries, e)
+		}
+	}
+	sort.Slice(entries, func(i, j int) bool {
+		return entries[i].Key < entries[j].Key
+	})
+	return entries, nil
+}
+
+func (m *Memory) CompareAndSwap(_ context.Context, key string, old, value []byte, ttl time.Duration) (bool, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	e, ok := m.get(key)
+	if ok != (old != nil) || (ok && !bytes.Equal(e.Value, old)) {
+		return false, nil
+	}
+	if value == nil {
+		delete(m.entries, key)
+	} else {
+		m.entries[key] = Entry{Key: key, Value: bytes.Clone(value), ExpiresAt: expiresAt(m.now(), ttl)}
+	}
+	return true, nil
+}
+
+func (m *Memory) Close() error {
+	return nil
+}
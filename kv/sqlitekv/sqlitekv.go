@@ -0,0 +1,179 @@
+// Package sqlitekv registers the sqlite backend of kv.Open, which keeps keys in a SQLite
+// database named porter.sqlite in the data directory.
+package sqlitekv
+
+import (
+	"bytes"
+	"context"
+	"database/sql"
+	"errors"
+	"path/filepath"
+	"time"
+
+	"github.com/tuihub/tuihub-go/kv"
+
+	_ "modernc.org/sqlite" // database/sql driver
+)
+
+const sqliteSchema = `CREATE TABLE IF NOT EXISTS kv (
+	key        TEXT PRIMARY KEY,
+	value      BLOB NOT NULL,
+	expires_at INTEGER NOT NULL DEFAULT 0
+)`
+
+func init() {
+	kv.Register(kv.BackendSQLite, func(dir string) (kv.Store, error) {
+		return Open(filepath.Join(dir, "porter.sqlite"))
+	})
+}
+
+// SQLite keeps keys in a SQLite database. It uses a single connection, so writes never conflict.
+type SQLite struct {
+	db  *sql.DB
+	now func() time.Time
+}
+
+// Open opens or creates the SQLite database at path and removes expired keys.
+func Open(path string) (*SQLite, error) {
+	db, err := sql.Open("sqlite", path)
+	if err != nil {
+		return nil, err
+	}
+	db.SetMaxOpenConns(1)
+	s := &SQLite{db: db, now: time.Now}
+	if _, err = db.Exec(sqliteSchema); err == nil {
+		_, err = db.Exec(`DELETE FROM kv WHERE expires_at != 0 AND expires_at <= ?`, s.now().UnixNano())
+	}
+	if err != nil {
+		_ = db.Close()
+		return nil, err
+	}
+	return s, nil
+}
+
+type querier interface {
+	QueryRowContext(ctx context.Context, query string, args ...any) *sql.Row
+}
+
+// get returns the unexpired value of key.
+func (s *SQLite) get(ctx context.Context, q querier, key string) ([]byte, bool, error) {
+	var value []byte
+	err := q.QueryRowContext(ctx,
+		`SELECT value FROM kv WHERE key = ? AND (expires_at = 0 OR expires_at > ?)`,
+		key, s.now().UnixNano(),
+	).Scan(&value)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, false, nil
+	}
+	return value, err == nil, err
+}
+
+func (s *SQLite) Get(ctx context.Context, key string) ([]byte, error) {
+	value, ok, err := s.get(ctx, s.db, key)
+	if err != nil {
+		return nil, err
+	}
+	if !ok {
+		return nil, kv.ErrNotFound
+	}
+	return value, nil
+}
+
+func (s *SQLite) Put(ctx context.Context, key string, value []byte, ttl time.Duration) error {
+	_, err := s.db.ExecContext(ctx,
+		`INSERT INTO kv (key, value, expires_at) VALUES (?, ?, ?)
+		ON CONFLICT (key) DO UPDATE SET value = excluded.value, expires_at = excluded.expires_at`,
+		key, nonNil(value), unixNano(expiresAt(s.now(), ttl)),
+	)
+	return err
+}
+
+func (s *SQLite) Delete(ctx context.Context, key string) error {
+	_, err := s.db.ExecContext(ctx, `DELETE FROM kv WHERE key = ?`, key)
+	return err
+}
+
+func (s *SQLite) List(ctx context.Context, prefix string) ([]kv.Entry, error) {
+	rows, err := s.db.QueryContext(ctx,
+		`SELECT key, value, expires_at FROM kv
+		WHERE substr(key, 1, length(?1)) = ?1 AND (expires_at = 0 OR expires_at > ?2)
+		ORDER BY key`,
+		prefix, s.now().UnixNano(),
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var entries []kv.Entry
+	for rows.Next() {
+		var e kv.Entry
+		var expiry int64
+		if err = rows.Scan(&e.Key, &e.Value, &expiry); err != nil {
+			return nil, err
+		}
+		if expiry != 0 {
+			e.ExpiresAt = time.Unix(0, expiry)
+		}
+		entries = append(entries, e)
+	}
+	return entries, rows.Err()
+}
+
+func (s *SQLite) CompareAndSwap(
+	ctx context.Context, key string, old, value []byte, ttl time.Duration,
+) (bool, error) {
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return false, err
+	}
+	defer func() {
+		_ = tx.Rollback()
+	}()
+	current, ok, err := s.get(ctx, tx, key)
+	if err != nil {
+		return false, err
+	}
+	if ok != (old != nil) || (ok && !bytes.Equal(current, old)) {
+		return false, nil
+	}
+	if value == nil {
+		_, err = tx.ExecContext(ctx, `DELETE FROM kv WHERE key = ?`, key)
+	} else {
+		_, err = tx.ExecContext(ctx,
+			`INSERT INTO kv (key, value, expires_at) VALUES (?, ?, ?)
+			ON CONFLICT (key) DO UPDATE SET value = excluded.value, expires_at = excluded.expires_at`,
+			key, nonNil(value), unixNano(expiresAt(s.now(), ttl)),
+		)
+	}
+	if err != nil {
+		return false, err
+	}
+	return true, tx.Commit()
+}
+
+func (s *SQLite) Close() error {
+	return s.db.Close()
+}
+
+// expiresAt returns the expiry of a key put at now with ttl, zero for no ttl.
+func expiresAt(now time.Time, ttl time.Duration) time.Time {
+	if ttl <= 0 {
+		return time.Time{}
+	}
+	return now.Add(ttl)
+}
+
+func unixNano(t time.Time) int64 {
+	if t.IsZero() {
+		return 0
+	}
+	return t.UnixNano()
+}
+
+// nonNil stores empty values as empty blobs rather than NULL.
+func nonNil(value []byte) []byte {
+	if value == nil {
+		return []byte{}
+	}
+	return value
+}
@@ -0,0 +1,40 @@
+package sqlitekv
+
+import (
+	"context"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/tuihub/tuihub-go/kv"
+	"github.com/tuihub/tuihub-go/kv/internal/kvtest"
+)
+
+func TestSQLite(t *testing.T) {
+	kvtest.Run(t, func(t *testing.T, now func() time.Time) kv.Store {
+		s, err := Open(filepath.Join(t.TempDir(), "porter.sqlite"))
+		if err != nil {
+			t.Fatal(err)
+		}
+		s.now = now
+		return s
+	})
+}
+
+func TestOpen(t *testing.T) {
+	dir := filepath.Join(t.TempDir(), "data")
+	s, err := kv.Open(kv.Config{Backend: kv.BackendSQLite, Dir: dir})
+	if err != nil {
+		t.Fatal(err)
+	}
+	_ = s.Put(context.Background(), "k", []byte("v"), 0)
+	_ = s.Close()
+	s, err = kv.Open(kv.Config{Backend: kv.BackendSQLite, Dir: dir})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer s.Close()
+	if v, _ := s.Get(context.Background(), "k"); string(v) != "v" {
+		t.Errorf("reopened value = %q", v)
+	}
+}
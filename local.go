@@ -0,0 +1,45 @@
+package tuihub
+
+import (
+	"net"
+	"sync"
+	"time"
+
+	porter "github.com/tuihub/protos/pkg/librarian/porter/v1"
+
+	"github.com/go-kratos/kratos/v2/log"
+	"github.com/go-kratos/kratos/v2/transport/grpc"
+)
+
+// ServeLocal serves service on lis through the same wrapper and middlewares as NewPorter,
+// but without service registration or a Sephirah client, so reverse calls are unavailable.
+// It is meant for tests and local debugging. Stop the returned server with GracefulStop.
+func ServeLocal(
+	info *porter.GetPorterInformationResponse,
+	service porter.LibrarianPorterServiceServer,
+	lis net.Listener,
+	logger log.Logger,
+) (*grpc.Server, error) {
+	if err := checkPorter(info, service); err != nil {
+		return nil, err
+	}
+	if logger == nil {
+		logger = log.DefaultLogger
+	}
+	c := &serviceWrapper{
+		LibrarianPorterServiceServer: service,
+		Info:                         info,
+		Logger:                       logger,
+		Client:                       nil,
+		RequireToken:                 false,
+		Token:                        nil,
+		tokenMu:                      sync.Mutex{},
+		lastHeartbeat:                time.Time{},
+		lastRefreshToken:             time.Time{},
+	}
+	srv := NewServer(defaultServerConfig(), NewService(c), logger)
+	go func() {
+		_ = srv.Server.Serve(lis)
+	}()
+	return srv, nil
+}
@@ -0,0 +1,199 @@
+package logger
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"github.com/go-kratos/kratos/v2/log"
+	"github.com/go-kratos/kratos/v2/middleware"
+	"github.com/go-kratos/kratos/v2/transport"
+	"google.golang.org/grpc/metadata"
+)
+
+const (
+	OperationKey = "operation"
+	TraceIDKey   = "trace_id"
+	RequestIDKey = "request_id"
+)
+
+// Logger is a child logger carrying fixed key-value fields.
+type Logger struct {
+	logger log.Logger
+}
+
+type fieldsKey struct{}
+
+// With returns a child logger of the global logger with the given fields.
+func With(keyvals ...interface{}) *Logger {
+	return &Logger{logger: log.With(log.GetLogger(), keyvals...)}
+}
+
+// FromContext returns a child logger with fields attached by NewContext and request information from ctx.
+func FromContext(ctx context.Context) *Logger {
+	return With(ContextFields(ctx)...)
+}
+
+// NewContext returns a copy of ctx carrying additional fields for FromContext.
+func NewContext(ctx context.Context, keyvals ...interface{}) context.Context {
+	prev, _ := ctx.Value(fieldsKey{}).([]interface{})
+	fields := make([]interface{}, 0, len(prev)+len(keyvals))
+	fields = append(fields, prev...)
+	fields = append(fields, keyvals...)
+	return context.WithValue(ctx, fieldsKey{}, fields)
+}
+
+// ContextFields returns fields attached by NewContext, followed by the operation,
+// trace id and request id found in ctx if they are not attached yet.
+func ContextFields(ctx context.Context) []interface{} {
+	fields, _ := ctx.Value(fieldsKey{}).([]interface{})
+	kvs := make([]interface{}, 0, len(fields)+6) //nolint:mnd // three optional pairs
+	kvs = append(kvs, fields...)
+	if HasKey(fields, OperationKey) {
+		return kvs
+	}
+	return append(kvs, requestFields(ctx)...)
+}
+
+// Server is a middleware that attaches the given fields and request information to the request context.
+func Server(keyvals ...interface{}) middleware.Middleware {
+	return func(handler middleware.Handler) middleware.Handler {
+		return func(ctx context.Context, req interface{}) (interface{}, error) {
+			fields, _ := ctx.Value(fieldsKey{}).([]interface{})
+			ctx = NewContext(ctx, keyvals...)
+			if !HasKey(fields, OperationKey) {
+				ctx = NewContext(ctx, requestFields(ctx)...)
+			}
+			return handler(ctx, req)
+		}
+	}
+}
+
+// requestFields returns the operation, trace id and request id found in ctx.
+func requestFields(ctx context.Context) []interface{} {
+	var kvs []interface{}
+	if tr, ok := transport.FromServerContext(ctx); ok {
+		kvs = append(kvs, OperationKey, tr.Operation())
+	}
+	if md, ok := metadata.FromIncomingContext(ctx); ok {
+		if id := traceID(md); id != "" {
+			kvs = append(kvs, TraceIDKey, id)
+		}
+		if ids := md.Get("x-request-id"); len(ids) > 0 {
+			kvs = append(kvs, RequestIDKey, ids[0])
+		}
+	}
+	return kvs
+}
+
+// traceID reads the W3C traceparent header, falling back to x-trace-id.
+func traceID(md metadata.MD) string {
+	if v := md.Get("traceparent"); len(v) > 0 {
+		// version-traceid-parentid-flags
+		if parts := strings.Split(v[0], "-"); len(parts) == 4 { //nolint:mnd // traceparent format
+			return parts[1]
+		}
+	}
+	if v := md.Get("x-trace-id"); len(v) > 0 {
+		return v[0]
+	}
+	return ""
+}
+
+// HasKey reports whether key is one of the keys of keyvals.
+func HasKey(keyvals []interface{}, key string) bool {
+	for i := 0; i < len(keyvals); i += 2 {
+		if keyvals[i] == key {
+			return true
+		}
+	}
+	return false
+}
+
+// With returns a child logger with additional fields.
+func (l *Logger) With(keyvals ...interface{}) *Logger {
+	return &Logger{logger: log.With(l.logger, keyvals...)}
+}
+
+// Log implements log.Logger.
+func (l *Logger) Log(level log.Level, keyvals ...interface{}) error {
+	return l.logger.Log(level, keyvals...)
+}
+
+// Debug logs a message at debug level.
+func (l *Logger) Debug(a ...interface{}) {
+	output(l.logger, log.LevelDebug, fmt.Sprint(a...), nil)
+}
+
+// Debugf logs a message at debug level.
+func (l *Logger) Debugf(format string, a ...interface{}) {
+	output(l.logger, log.LevelDebug, fmt.Sprintf(format, a...), nil)
+}
+
+// Debugw logs a message with key-value pairs at debug level.
+func (l *Logger) Debugw(msg string, keyvals ...interface{}) {
+	output(l.logger, log.LevelDebug, msg, keyvals)
+}
+
+// Info logs a message at info level.
+func (l *Logger) Info(a ...interface{}) {
+	output(l.logger, log.LevelInfo, fmt.Sprint(a...), nil)
+}
+
+// Infof logs a message at info level.
+func (l *Logger) Infof(format string, a ...interface{}) {
+	output(l.logger, log.LevelInfo, fmt.Sprintf(format, a...), nil)
+}
+
+// Infow logs a message with key-value pairs at info level.
+func (l *Logger) Infow(msg string, keyvals ...interface{}) {
+	output(l.logger, log.LevelInfo, msg, keyvals)
+}
+
+// Warn logs a message at warn level.
+func (l *Logger) Warn(a ...interface{}) {
+	output(l.logger, log.LevelWarn, fmt.Sprint(a...), nil)
+}
+
+// Warnf logs a message at warn level.
+func (l *Logger) Warnf(format string, a ...interface{}) {
+	output(l.logger, log.LevelWarn, fmt.Sprintf(format, a...), nil)
+}
+
+// Warnw logs a message with key-value pairs at warn level.
+func (l *Logger) Warnw(msg string, keyvals ...interface{}) {
+	output(l.logger, log.LevelWarn, msg, keyvals)
+}
+
+// Error logs a message at error level.
+func (l *Logger) Error(a ...interface{}) {
+	output(l.logger, log.LevelError, fmt.Sprint(a...), nil)
+}
+
+// Errorf logs a message at error level.
+func (l *Logger) Errorf(format string, a ...interface{}) {
+	output(l.logger, log.LevelError, fmt.Sprintf(format, a...), nil)
+}
+
+// Errorw logs a message with key-value pairs at error level.
+func (l *Logger) Errorw(msg string, keyvals ...interface{}) {
+	output(l.logger, log.LevelError, msg, keyvals)
+}
+
+// Fatal logs a message at fatal level, runs exit handlers and exits.
+func (l *Logger) Fatal(a ...interface{}) {
+	output(l.logger, log.LevelFatal, fmt.Sprint(a...), nil)
+	exit()
+}
+
+// Fatalf logs a message at fatal level, runs exit handlers and exits.
+func (l *Logger) Fatalf(format string, a ...interface{}) {
+	output(l.logger, log.LevelFatal, fmt.Sprintf(format, a...), nil)
+	exit()
+}
+
+// Fatalw logs a message with key-value pairs at fatal level, runs exit handlers and exits.
+func (l *Logger) Fatalw(msg string, keyvals ...interface{}) {
+	output(l.logger, log.LevelFatal, msg, keyvals)
+	exit()
+}
@@ -0,0 +1,178 @@
+package logger
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"strings"
+	"sync"
+	"sync/atomic"
+
+	"github.com/go-kratos/kratos/v2/log"
+)
+
+const (
+	logLevel  = "LOG_LEVEL"
+	logFormat = "LOG_FORMAT"
+)
+
+type Format string
+
+const (
+	FormatConsole Format = "console"
+	FormatJSON    Format = "json"
+)
+
+// Config selects the initial level and output format of loggers created by New.
+type Config struct {
+	Level  log.Level
+	Format Format
+}
+
+// ConfigFromEnv reads LOG_LEVEL and LOG_FORMAT, defaulting to debug level console output.
+func ConfigFromEnv() Config {
+	config := Config{
+		Level:  log.LevelDebug,
+		Format: FormatConsole,
+	}
+	if level, exist := os.LookupEnv(logLevel); exist {
+		if l, err := ParseLevel(level); err == nil {
+			config.Level = l
+		}
+	}
+	if format, exist := os.LookupEnv(logFormat); exist {
+		config.Format = Format(strings.ToLower(format))
+	}
+	return config
+}
+
+var level atomic.Int32
+
+func init() {
+	level.Store(int32(log.LevelDebug))
+}
+
+// SetLevel changes the level of all loggers created by New at runtime.
+func SetLevel(l log.Level) {
+	level.Store(int32(l))
+}
+
+// GetLevel returns the current level of loggers created by New.
+func GetLevel() log.Level {
+	return log.Level(level.Load())
+}
+
+// ParseLevel parses a level name, unlike log.ParseLevel it rejects unknown names.
+func ParseLevel(s string) (log.Level, error) {
+	l := log.ParseLevel(s)
+	if !strings.EqualFold(l.String(), s) {
+		return l, fmt.Errorf("unknown log level %q", s)
+	}
+	return l, nil
+}
+
+// New creates a logger writing to w in the configured format and sets the global level.
+// Records below the level set by SetLevel are dropped.
+func New(w io.Writer, config Config) log.Logger {
+	SetLevel(config.Level)
+	var l log.Logger
+	if config.Format == FormatJSON {
+		l = newJSONLogger(w)
+	} else {
+		l = log.NewStdLogger(w)
+	}
+	return NewLevelFilter(log.With(l, "ts", log.DefaultTimestamp))
+}
+
+// NewLevelFilter wraps l so records below the level set by SetLevel are dropped.
+func NewLevelFilter(l log.Logger) log.Logger {
+	if _, ok := l.(*levelLogger); ok {
+		return l
+	}
+	return &levelLogger{
+		logger: l,
+	}
+}
+
+type levelLogger struct {
+	logger log.Logger
+}
+
+func (l *levelLogger) Log(lv log.Level, keyvals ...interface{}) error {
+	if lv < GetLevel() {
+		return nil
+	}
+	return l.logger.Log(lv, keyvals...)
+}
+
+type jsonLogger struct {
+	mu sync.Mutex
+	w  io.Writer
+}
+
+func newJSONLogger(w io.Writer) log.Logger {
+	return &jsonLogger{
+		mu: sync.Mutex{},
+		w:  w,
+	}
+}
+
+func (l *jsonLogger) Log(lv log.Level, keyvals ...interface{}) error {
+	if len(keyvals)%2 == 1 {
+		keyvals = append(keyvals, "KEYVALS UNPAIRED")
+	}
+	m := make(map[string]interface{}, len(keyvals)/2+1) //nolint:mnd // level field
+	m[log.LevelKey] = lv.String()
+	for i := 0; i < len(keyvals); i += 2 {
+		v := keyvals[i+1]
+		switch val := v.(type) {
+		case error:
+			v = val.Error()
+		case fmt.Stringer:
+			v = val.String()
+		}
+		m[fmt.Sprint(keyvals[i])] = v
+	}
+	b, err := json.Marshal(m)
+	if err != nil {
+		return err
+	}
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	_, err = l.w.Write(append(b, '\n'))
+	return err
+}
+
+type levelBody struct {
+	Level string `json:"level"`
+}
+
+// LevelHandler serves the current level as JSON on GET and changes it on PUT or POST
+// with a body like {"level":"warn"}.
+func LevelHandler() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.Method {
+		case http.MethodGet:
+		case http.MethodPut, http.MethodPost:
+			var body levelBody
+			if err := json.NewDecoder(r.Body).Decode(&body); err != nil {
+				http.Error(w, err.Error(), http.StatusBadRequest)
+				return
+			}
+			l, err := ParseLevel(body.Level)
+			if err != nil {
+				http.Error(w, err.Error(), http.StatusBadRequest)
+				return
+			}
+			SetLevel(l)
+		default:
+			w.Header().Set("Allow", "GET, PUT, POST")
+			http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		_ = json.NewEncoder(w).Encode(levelBody{Level: strings.ToLower(GetLevel().String())})
+	})
+}
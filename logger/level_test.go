@@ -0,0 +1,128 @@
+package logger
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/go-kratos/kratos/v2/log"
+)
+
+func restoreLevel(t *testing.T) {
+	t.Helper()
+	prev := GetLevel()
+	t.Cleanup(func() { SetLevel(prev) })
+}
+
+func TestParseLevel(t *testing.T) {
+	tests := []struct {
+		in      string
+		want    log.Level
+		wantErr bool
+	}{
+		{"debug", log.LevelDebug, false},
+		{"INFO", log.LevelInfo, false},
+		{"Warn", log.LevelWarn, false},
+		{"error", log.LevelError, false},
+		{"fatal", log.LevelFatal, false},
+		{"verbose", log.LevelInfo, true},
+		{"", log.LevelInfo, true},
+	}
+	for _, tt := range tests {
+		got, err := ParseLevel(tt.in)
+		if (err != nil) != tt.wantErr {
+			t.Errorf("ParseLevel(%q) error = %v, wantErr %v", tt.in, err, tt.wantErr)
+			continue
+		}
+		if !tt.wantErr && got != tt.want {
+			t.Errorf("ParseLevel(%q) = %v, want %v", tt.in, got, tt.want)
+		}
+	}
+}
+
+func TestLevelHandler(t *testing.T) {
+	restoreLevel(t)
+	SetLevel(log.LevelInfo)
+	tests := []struct {
+		name      string
+		method    string
+		body      string
+		wantCode  int
+		wantLevel log.Level
+	}{
+		{"get", http.MethodGet, "", http.StatusOK, log.LevelInfo},
+		{"put", http.MethodPut, `{"level":"warn"}`, http.StatusOK, log.LevelWarn},
+		{"post", http.MethodPost, `{"level":"debug"}`, http.StatusOK, log.LevelDebug},
+		{"bad body", http.MethodPut, `{`, http.StatusBadRequest, log.LevelDebug},
+		{"unknown level", http.MethodPut, `{"level":"loud"}`, http.StatusBadRequest, log.LevelDebug},
+		{"method not allowed", http.MethodDelete, "", http.StatusMethodNotAllowed, log.LevelDebug},
+	}
+	h := LevelHandler()
+	for _, tt := range tests {
+		w := httptest.NewRecorder()
+		h.ServeHTTP(w, httptest.NewRequest(tt.method, "/", strings.NewReader(tt.body)))
+		if w.Code != tt.wantCode {
+			t.Errorf("%s: code = %d, want %d", tt.name, w.Code, tt.wantCode)
+		}
+		if GetLevel() != tt.wantLevel {
+			t.Errorf("%s: level = %v, want %v", tt.name, GetLevel(), tt.wantLevel)
+		}
+		if w.Code == http.StatusOK {
+			var body levelBody
+			if err := json.Unmarshal(w.Body.Bytes(), &body); err != nil {
+				t.Errorf("%s: %v", tt.name, err)
+			} else if body.Level != strings.ToLower(tt.wantLevel.String()) {
+				t.Errorf("%s: body level = %q", tt.name, body.Level)
+			}
+		}
+	}
+}
+
+func TestNewJSON(t *testing.T) {
+	restoreLevel(t)
+	var buf bytes.Buffer
+	l := New(&buf, Config{Level: log.LevelInfo, Format: FormatJSON})
+	_ = l.Log(log.LevelDebug, "msg", "dropped")
+	_ = l.Log(log.LevelWarn, "msg", "kept", "err", errors.New("boom"), "n", 1)
+	lines := strings.Split(strings.TrimSpace(buf.String()), "\n")
+	if len(lines) != 1 {
+		t.Fatalf("got %d lines, want 1: %q", len(lines), buf.String())
+	}
+	var m map[string]interface{}
+	if err := json.Unmarshal([]byte(lines[0]), &m); err != nil {
+		t.Fatal(err)
+	}
+	for key, want := range map[string]interface{}{
+		"level": "WARN",
+		"msg":   "kept",
+		"err":   "boom",
+		"n":     float64(1),
+	} {
+		if m[key] != want {
+			t.Errorf("%s = %v, want %v", key, m[key], want)
+		}
+	}
+	if _, ok := m["ts"]; !ok {
+		t.Error("missing ts")
+	}
+}
+
+func TestNewLevelFilter(t *testing.T) {
+	restoreLevel(t)
+	m := new(memLogger)
+	l := NewLevelFilter(m)
+	if NewLevelFilter(l) != l {
+		t.Error("filter wrapped twice")
+	}
+	SetLevel(log.LevelError)
+	_ = l.Log(log.LevelWarn, "msg", "dropped")
+	SetLevel(log.LevelDebug)
+	_ = l.Log(log.LevelDebug, "msg", "kept")
+	if len(m.records) != 1 {
+		t.Fatalf("got %d records, want 1", len(m.records))
+	}
+}
@@ -5,15 +5,68 @@
 	"io"
 	"os"
 	"runtime"
+	"sync"
 
 	"github.com/go-kratos/kratos/v2/log"
 )
 
 const DefaultCallerKey = "logger"
 
+// callerDepth skips runtime.Callers, getCaller, output and the exported logging function.
+const callerDepth = 4
+
+var callerCache sync.Map
+
 func getCaller() string {
-	pc, _, _, _ := runtime.Caller(2) //nolint:mnd //get external caller
-	return runtime.FuncForPC(pc).Name()
+	var pcs [1]uintptr
+	if runtime.Callers(callerDepth, pcs[:]) == 0 {
+		return ""
+	}
+	if name, ok := callerCache.Load(pcs[0]); ok {
+		return name.(string) //nolint:errcheck // only strings are stored
+	}
+	frame, _ := runtime.CallersFrames(pcs[:]).Next()
+	callerCache.Store(pcs[0], frame.Function)
+	return frame.Function
+}
+
+var (
+	exitMu       sync.Mutex
+	exitFunc     = os.Exit
+	exitHandlers []func()
+)
+
+// RegisterExitHandler adds a handler that runs before Fatal exits the process.
+// Use it to flush buffered writers or stop background workers.
+func RegisterExitHandler(handler func()) {
+	exitMu.Lock()
+	defer exitMu.Unlock()
+	exitHandlers = append(exitHandlers, handler)
+}
+
+// SetExitFunc replaces the function Fatal calls after running exit handlers. Defaults to os.Exit.
+func SetExitFunc(f func(code int)) {
+	exitMu.Lock()
+	defer exitMu.Unlock()
+	exitFunc = f
+}
+
+func exit() {
+	exitMu.Lock()
+	handlers := exitHandlers
+	f := exitFunc
+	exitMu.Unlock()
+	for _, h := range handlers {
+		h()
+	}
+	f(1)
+}
+
+func output(logger log.Logger, level log.Level, msg string, keyvals []interface{}) {
+	kvs := make([]interface{}, 0, len(keyvals)+4) //nolint:mnd // caller and message
+	kvs = append(kvs, DefaultCallerKey, getCaller(), log.DefaultMessageKey, msg)
+	kvs = append(kvs, keyvals...)
+	_ = logger.Log(level, kvs...)
 }
 
 func NewWriter(level log.Level) io.Writer {
@@ -22,52 +75,78 @@ func NewWriter(level log.Level) io.Writer {
 
 // Debug logs a message at debug level.
 func Debug(a ...interface{}) {
-	log.Log(log.LevelDebug, DefaultCallerKey, getCaller(), log.DefaultMessageKey, fmt.Sprint(a...))
+	output(log.GetLogger(), log.LevelDebug, fmt.Sprint(a...), nil)
 }
 
 // Debugf logs a message at debug level.
 func Debugf(format string, a ...interface{}) {
-	log.Log(log.LevelDebug, DefaultCallerKey, getCaller(), log.DefaultMessageKey, fmt.Sprintf(format, a...))
+	output(log.GetLogger(), log.LevelDebug, fmt.Sprintf(format, a...), nil)
+}
+
+// Debugw logs a message with key-value pairs at debug level.
+func Debugw(msg string, keyvals ...interface{}) {
+	output(log.GetLogger(), log.LevelDebug, msg, keyvals)
 }
 
 // Info logs a message at info level.
 func Info(a ...interface{}) {
-	log.Log(log.LevelInfo, DefaultCallerKey, getCaller(), log.DefaultMessageKey, fmt.Sprint(a...))
+	output(log.GetLogger(), log.LevelInfo, fmt.Sprint(a...), nil)
 }
 
 // Infof logs a message at info level.
 func Infof(format string, a ...interface{}) {
-	log.Log(log.LevelInfo, DefaultCallerKey, getCaller(), log.DefaultMessageKey, fmt.Sprintf(format, a...))
+	output(log.GetLogger(), log.LevelInfo, fmt.Sprintf(format, a...), nil)
+}
+
+// Infow logs a message with key-value pairs at info level.
+func Infow(msg string, keyvals ...interface{}) {
+	output(log.GetLogger(), log.LevelInfo, msg, keyvals)
 }
 
 // Warn logs a message at warn level.
 func Warn(a ...interface{}) {
-	log.Log(log.LevelWarn, DefaultCallerKey, getCaller(), log.DefaultMessageKey, fmt.Sprint(a...))
+	output(log.GetLogger(), log.LevelWarn, fmt.Sprint(a...), nil)
 }
 
 // Warnf logs a message at warnf level.
 func Warnf(format string, a ...interface{}) {
-	log.Log(log.LevelWarn, DefaultCallerKey, getCaller(), log.DefaultMessageKey, fmt.Sprintf(format, a...))
+	output(log.GetLogger(), log.LevelWarn, fmt.Sprintf(format, a...), nil)
+}
+
+// Warnw logs a message with key-value pairs at warn level.
+func Warnw(msg string, keyvals ...interface{}) {
+	output(log.GetLogger(), log.LevelWarn, msg, keyvals)
 }
 
 // Error logs a message at error level.
 func Error(a ...interface{}) {
-	log.Log(log.LevelError, DefaultCallerKey, getCaller(), log.DefaultMessageKey, fmt.Sprint(a...))
+	output(log.GetLogger(), log.LevelError, fmt.Sprint(a...), nil)
 }
 
 // Errorf logs a message at error level.
 func Errorf(format string, a ...interface{}) {
-	log.Log(log.LevelError, DefaultCallerKey, getCaller(), log.DefaultMessageKey, fmt.Sprintf(format, a...))
+	output(log.GetLogger(), log.LevelError, fmt.Sprintf(format, a...), nil)
+}
+
+// Errorw logs a message with key-value pairs at error level.
+func Errorw(msg string, keyvals ...interface{}) {
+	output(log.GetLogger(), log.LevelError, msg, keyvals)
 }
 
-// Fatal logs a message at fatal level.
+// Fatal logs a message at fatal level, runs exit handlers and exits.
 func Fatal(a ...interface{}) {
-	log.Log(log.LevelFatal, DefaultCallerKey, getCaller(), log.DefaultMessageKey, fmt.Sprint(a...))
-	os.Exit(1)
+	output(log.GetLogger(), log.LevelFatal, fmt.Sprint(a...), nil)
+	exit()
 }
 
-// Fatalf logs a message at fatal level.
+// Fatalf logs a message at fatal level, runs exit handlers and exits.
 func Fatalf(format string, a ...interface{}) {
-	log.Log(log.LevelFatal, DefaultCallerKey, getCaller(), log.DefaultMessageKey, fmt.Sprintf(format, a...))
-	os.Exit(1)
+	output(log.GetLogger(), log.LevelFatal, fmt.Sprintf(format, a...), nil)
+	exit()
+}
+
+// Fatalw logs a message with key-value pairs at fatal level, runs exit handlers and exits.
+func Fatalw(msg string, keyvals ...interface{}) {
+	output(log.GetLogger(), log.LevelFatal, msg, keyvals)
+	exit()
 }
@@ -0,0 +1,183 @@
+package logger
+
+import (
+	"context"
+	"os"
+	"sync"
+	"testing"
+
+	"github.com/go-kratos/kratos/v2/log"
+	"github.com/go-kratos/kratos/v2/transport"
+	"google.golang.org/grpc/metadata"
+)
+
+type record struct {
+	level   log.Level
+	keyvals []interface{}
+}
+
+type memLogger struct {
+	mu      sync.Mutex
+	records []record
+}
+
+func (m *memLogger) Log(level log.Level, keyvals ...interface{}) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.records = append(m.records, record{level: level, keyvals: keyvals})
+	return nil
+}
+
+func (m *memLogger) last(t *testing.T) record {
+	t.Helper()
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if len(m.records) == 0 {
+		t.Fatal("no record logged")
+	}
+	return m.records[len(m.records)-1]
+}
+
+func (r record) value(key string) (interface{}, bool) {
+	for i := 0; i+1 < len(r.keyvals); i += 2 {
+		if r.keyvals[i] == key {
+			return r.keyvals[i+1], true
+		}
+	}
+	return nil, false
+}
+
+func (r record) count(key string) int {
+	n := 0
+	for i := 0; i+1 < len(r.keyvals); i += 2 {
+		if r.keyvals[i] == key {
+			n++
+		}
+	}
+	return n
+}
+
+func useMemLogger(t *testing.T) *memLogger {
+	t.Helper()
+	m := new(memLogger)
+	log.SetLogger(m)
+	t.Cleanup(func() { log.SetLogger(log.DefaultLogger) })
+	return m
+}
+
+type testTransport struct {
+	transport.Transporter
+	operation string
+}
+
+func (t testTransport) Operation() string { return t.operation }
+
+func TestCaller(t *testing.T) {
+	m := useMemLogger(t)
+	const want = "github.com/tuihub/tuihub-go/logger.TestCaller"
+	tests := []struct {
+		name string
+		log  func()
+	}{
+		{"Info", func() { Info("a") }},
+		{"Infof", func() { Infof("%s", "a") }},
+		{"Infow", func() { Infow("a", "k", "v") }},
+		{"Logger.Warn", func() { With("k", "v").Warn("a") }},
+		{"Logger.Errorw", func() { With().Errorw("a") }},
+	}
+	for _, tt := range tests {
+		tt.log()
+		caller, _ := m.last(t).value(DefaultCallerKey)
+		// the closures are named TestCaller.funcN
+		if s, _ := caller.(string); len(s) < len(want) || s[:len(want)] != want {
+			t.Errorf("%s: caller = %v, want prefix %s", tt.name, caller, want)
+		}
+	}
+}
+
+func TestWithFields(t *testing.T) {
+	m := useMemLogger(t)
+	With("a", 1).With("b", 2).Infow("msg", "c", 3)
+	r := m.last(t)
+	if r.level != log.LevelInfo {
+		t.Errorf("level = %v, want INFO", r.level)
+	}
+	for key, want := range map[string]interface{}{"a": 1, "b": 2, "c": 3, log.DefaultMessageKey: "msg"} {
+		if v, ok := r.value(key); !ok || v != want {
+			t.Errorf("%s = %v, want %v", key, v, want)
+		}
+	}
+}
+
+func TestNewContext(t *testing.T) {
+	m := useMemLogger(t)
+	ctx := NewContext(context.Background(), "a", 1)
+	ctx = NewContext(ctx, "b", 2)
+	FromContext(ctx).Info("msg")
+	r := m.last(t)
+	if v, _ := r.value("a"); v != 1 {
+		t.Errorf("a = %v, want 1", v)
+	}
+	if v, _ := r.value("b"); v != 2 {
+		t.Errorf("b = %v, want 2", v)
+	}
+}
+
+func TestServer(t *testing.T) {
+	ctx := transport.NewServerContext(context.Background(), testTransport{operation: "/svc/Method"})
+	ctx = metadata.NewIncomingContext(ctx, metadata.Pairs(
+		"traceparent", "00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01",
+		"x-request-id", "req-1",
+	))
+	ctx = NewContext(ctx, "upstream", "u")
+	var got []interface{}
+	h := Server("porter", "p")(func(ctx context.Context, _ interface{}) (interface{}, error) {
+		got = ContextFields(ctx)
+		return nil, nil
+	})
+	if _, err := h(ctx, nil); err != nil {
+		t.Fatal(err)
+	}
+	r := record{keyvals: got}
+	for key, want := range map[string]interface{}{
+		"upstream":   "u",
+		"porter":     "p",
+		OperationKey: "/svc/Method",
+		TraceIDKey:   "4bf92f3577b34da6a3ce929d0e0e4736",
+		RequestIDKey: "req-1",
+	} {
+		if v, _ := r.value(key); v != want {
+			t.Errorf("%s = %v, want %v", key, v, want)
+		}
+		if n := r.count(key); n != 1 {
+			t.Errorf("%s attached %d times", key, n)
+		}
+	}
+}
+
+func TestFatal(t *testing.T) {
+	m := useMemLogger(t)
+	var (
+		code    = -1
+		flushed bool
+	)
+	SetExitFunc(func(c int) { code = c })
+	RegisterExitHandler(func() { flushed = true })
+	t.Cleanup(func() {
+		exitMu.Lock()
+		defer exitMu.Unlock()
+		exitHandlers = nil
+	})
+	t.Cleanup(func() { SetExitFunc(os.Exit) })
+
+	Fatalw("bye", "k", "v")
+	if r := m.last(t); r.level != log.LevelFatal {
+		t.Errorf("level = %v, want FATAL", r.level)
+	}
+	if !flushed {
+		t.Error("exit handler not called")
+	}
+	if code != 1 {
+		t.Errorf("exit code = %d, want 1", code)
+	}
+}
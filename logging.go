@@ -0,0 +1,69 @@
+package tuihub
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	tuihublogger "github.com/tuihub/tuihub-go/logger"
+	"github.com/tuihub/tuihub-go/redact"
+
+	"github.com/go-kratos/kratos/v2/errors"
+	"github.com/go-kratos/kratos/v2/log"
+	"github.com/go-kratos/kratos/v2/middleware"
+	"github.com/go-kratos/kratos/v2/transport"
+)
+
+// serverLogging logs every request like logging.Server, with payloads logged according to config.
+func serverLogging(logger log.Logger, c *ServerConfig) middleware.Middleware {
+	r := redact.New(redact.WithConfigKeys(c.RedactConfigKeys...))
+	return func(handler middleware.Handler) middleware.Handler {
+		return func(ctx context.Context, req interface{}) (interface{}, error) {
+			var (
+				code      int32
+				reason    string
+				kind      string
+				operation string
+			)
+			startTime := time.Now()
+			if info, ok := transport.FromServerContext(ctx); ok {
+				kind = info.Kind().String()
+				operation = info.Operation()
+			}
+			reply, err := handler(ctx, req)
+			if se := errors.FromError(err); se != nil {
+				code = se.Code
+				reason = se.Reason
+			}
+			level := log.LevelInfo
+			stack := ""
+			if err != nil {
+				level = log.LevelError
+				stack = fmt.Sprintf("%+v", err)
+			}
+			keyvals := []interface{}{
+				"kind", "server",
+				"component", kind,
+			}
+			fields := tuihublogger.ContextFields(ctx)
+			if !tuihublogger.HasKey(fields, tuihublogger.OperationKey) {
+				keyvals = append(keyvals, tuihublogger.OperationKey, operation)
+			}
+			keyvals = append(keyvals, fields...)
+			if c.LogRequest {
+				keyvals = append(keyvals, "args", r.String(req))
+			}
+			if c.LogResponse && err == nil {
+				keyvals = append(keyvals, "reply", r.String(reply))
+			}
+			keyvals = append(keyvals,
+				"code", code,
+				"reason", reason,
+				"stack", stack,
+				"latency", time.Since(startTime).Seconds(),
+			)
+			log.NewHelper(log.WithContext(ctx, logger)).Log(level, keyvals...)
+			return reply, err
+		}
+	}
+}
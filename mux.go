@@ -0,0 +1,370 @@
+package tuihub
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"sync"
+
+	pb "github.com/tuihub/protos/pkg/librarian/porter/v1"
+	librarian "github.com/tuihub/protos/pkg/librarian/v1"
+	"github.com/tuihub/tuihub-go/errors"
+
+	"google.golang.org/protobuf/proto"
+)
+
+// Mount is a service serving the features in Features.
+type Mount struct {
+	Service  pb.LibrarianPorterServiceServer
+	Features *librarian.FeatureSummary
+}
+
+// Mux serves several services as one porter, routing each RPC by the account platform,
+// app info source, feed source, notify destination, feed item action, feed setter or
+// feed getter id of the request:
+//
+//	mux, err := tuihub.NewMux(info,
+//		tuihub.Mount{Service: steam, Features: steamFeatures},
+//		tuihub.Mount{Service: rss, Features: rssFeatures},
+//	)
+//	p, err := tuihub.NewPorter(ctx, mux.Info(), mux)
+//
+// EnablePorter, EnableContext and DisableContext are sent to every service.
+// SearchAppInfo is sent to every service with app info sources and returns the results of
+// those that succeed.
+type Mux struct {
+	pb.UnimplementedLibrarianPorterServiceServer
+	info   *pb.GetPorterInformationResponse
+	mounts []Mount
+
+	accountPlatforms   map[string]pb.LibrarianPorterServiceServer
+	appInfoSources     map[string]pb.LibrarianPorterServiceServer
+	feedSources        map[string]pb.LibrarianPorterServiceServer
+	notifyDestinations map[string]pb.LibrarianPorterServiceServer
+	feedItemActions    map[string]pb.LibrarianPorterServiceServer
+	feedSetters        map[string]pb.LibrarianPorterServiceServer
+	feedGetters        map[string]pb.LibrarianPorterServiceServer
+
+	// enabled setters and getters by id, to route Disable calls
+	enabledMu      sync.Mutex
+	enabledSetters map[int64]pb.LibrarianPorterServiceServer
+	enabledGetters map[int64]pb.LibrarianPorterServiceServer
+}
+
+// NewMux combines mounts into one service. The FeatureSummary of info is replaced by the
+// features of all mounts. A feature id served by more than one mount is an error.
+func NewMux(info *pb.GetPorterInformationResponse, mounts ...Mount) (*Mux, error) {
+	if info == nil {
+		return nil, fmt.Errorf("porter information is nil")
+	}
+	if len(mounts) == 0 {
+		return nil, fmt.Errorf("no mounts")
+	}
+	m := &Mux{
+		UnimplementedLibrarianPorterServiceServer: pb.UnimplementedLibrarianPorterServiceServer{},
+		info:               proto.Clone(info).(*pb.GetPorterInformationResponse), //nolint:errcheck // same type
+		mounts:             mounts,
+		accountPlatforms:   make(map[string]pb.LibrarianPorterServiceServer),
+		appInfoSources:     make(map[string]pb.LibrarianPorterServiceServer),
+		feedSources:        make(map[string]pb.LibrarianPorterServiceServer),
+		notifyDestinations: make(map[string]pb.LibrarianPorterServiceServer),
+		feedItemActions:    make(map[string]pb.LibrarianPorterServiceServer),
+		feedSetters:        make(map[string]pb.LibrarianPorterServiceServer),
+		feedGetters:        make(map[string]pb.LibrarianPorterServiceServer),
+		enabledMu:          sync.Mutex{},
+		enabledSetters:     make(map[int64]pb.LibrarianPorterServiceServer),
+		enabledGetters:     make(map[int64]pb.LibrarianPorterServiceServer),
+	}
+	summary := new(librarian.FeatureSummary)
+	for i, mount := range mounts {
+		if mount.Service == nil {
+			return nil, fmt.Errorf("mount %d: service is nil", i)
+		}
+		f := mount.Features
+		for _, kind := range []struct {
+			name   string
+			flags  []*librarian.FeatureFlag
+			routes map[string]pb.LibrarianPorterServiceServer
+			merged *[]*librarian.FeatureFlag
+		}{
+			{"account platform", f.GetAccountPlatforms(), m.accountPlatforms, &summary.AccountPlatforms},
+			{"app info source", f.GetAppInfoSources(), m.appInfoSources, &summary.AppInfoSources},
+			{"feed source", f.GetFeedSources(), m.feedSources, &summary.FeedSources},
+			{"notify destination", f.GetNotifyDestinations(), m.notifyDestinations, &summary.NotifyDestinations},
+			{"feed item action", f.GetFeedItemActions(), m.feedItemActions, &summary.FeedItemActions},
+			{"feed setter", f.GetFeedSetters(), m.feedSetters, &summary.FeedSetters},
+			{"feed getter", f.GetFeedGetters(), m.feedGetters, &summary.FeedGetters},
+		} {
+			for _, flag := range kind.flags {
+				if flag.GetId() == "" {
+					return nil, fmt.Errorf("mount %d: %s id is empty", i, kind.name)
+				}
+				if _, exist := kind.routes[flag.GetId()]; exist {
+					return nil, fmt.Errorf("mount %d: %s %q is served by another mount", i, kind.name, flag.GetId())
+				}
+				kind.routes[flag.GetId()] = mount.Service
+				*kind.merged = append(*kind.merged, proto.Clone(flag).(*librarian.FeatureFlag)) //nolint:errcheck // same type
+			}
+		}
+	}
+	m.info.FeatureSummary = summary
+	return m, nil
+}
+
+// Info returns the porter information with the merged feature summary, pass it to NewPorter.
+func (m *Mux) Info() *pb.GetPorterInformationResponse {
+	return m.info
+}
+
+func (m *Mux) GetPorterInformation(context.Context, *pb.GetPorterInformationRequest) (
+	*pb.GetPorterInformationResponse, error) {
+	return m.info, nil
+}
+
+func (m *Mux) EnablePorter(ctx context.Context, req *pb.EnablePorterRequest) (*pb.EnablePorterResponse, error) {
+	resp := &pb.EnablePorterResponse{
+		StatusMessage:    "",
+		NeedRefreshToken: false,
+		EnablesSummary:   nil,
+	}
+	var messages []string
+	implemented, err := m.broadcast(m.services(nil), func(s pb.LibrarianPorterServiceServer) error {
+		r, err := s.EnablePorter(ctx, req)
+		if err != nil {
+			return err
+		}
+		if r.GetStatusMessage() != "" {
+			messages = append(messages, r.GetStatusMessage())
+		}
+		resp.NeedRefreshToken = resp.NeedRefreshToken || r.GetNeedRefreshToken()
+		if e := r.GetEnablesSummary(); e != nil {
+			if resp.EnablesSummary == nil {
+				resp.EnablesSummary = new(pb.PorterEnablesSummary)
+			}
+			resp.EnablesSummary.ContextIds = append(resp.EnablesSummary.ContextIds, e.GetContextIds()...)
+			resp.EnablesSummary.FeedSetterIds = append(resp.EnablesSummary.FeedSetterIds, e.GetFeedSetterIds()...)
+			resp.EnablesSummary.FeedGetterIds = append(resp.EnablesSummary.FeedGetterIds, e.GetFeedGetterIds()...)
+		}
+		return nil
+	})
+	if !implemented {
+		return m.UnimplementedLibrarianPorterServiceServer.EnablePorter(ctx, req)
+	}
+	if err != nil {
+		return nil, err
+	}
+	resp.StatusMessage = strings.Join(messages, "; ")
+	return resp, nil
+}
+
+func (m *Mux) EnableContext(ctx context.Context, req *pb.EnableContextRequest) (*pb.EnableContextResponse, error) {
+	implemented, err := m.broadcast(m.services(nil), func(s pb.LibrarianPorterServiceServer) error {
+		_, err := s.EnableContext(ctx, req)
+		return err
+	})
+	if !implemented {
+		return m.UnimplementedLibrarianPorterServiceServer.EnableContext(ctx, req)
+	}
+	if err != nil {
+		return nil, err
+	}
+	return new(pb.EnableContextResponse), nil
+}
+
+func (m *Mux) DisableContext(ctx context.Context, req *pb.DisableContextRequest) (*pb.DisableContextResponse, error) {
+	implemented, err := m.broadcast(m.services(nil), func(s pb.LibrarianPorterServiceServer) error {
+		_, err := s.DisableContext(ctx, req)
+		return err
+	})
+	if !implemented {
+		return m.UnimplementedLibrarianPorterServiceServer.DisableContext(ctx, req)
+	}
+	if err != nil {
+		return nil, err
+	}
+	return new(pb.DisableContextResponse), nil
+}
+
+func (m *Mux) PullAccount(ctx context.Context, req *pb.PullAccountRequest) (*pb.PullAccountResponse, error) {
+	s, ok := m.accountPlatforms[req.GetAccountId().GetPlatform()]
+	if !ok {
+		return nil, errors.Unsupported("Unsupported account platform")
+	}
+	return s.PullAccount(ctx, req)
+}
+
+func (m *Mux) PullAppInfo(ctx context.Context, req *pb.PullAppInfoRequest) (*pb.PullAppInfoResponse, error) {
+	s, ok := m.appInfoSources[req.GetAppInfoId().GetSource()]
+	if !ok {
+		return nil, errors.Unsupported("Unsupported app source")
+	}
+	return s.PullAppInfo(ctx, req)
+}
+
+func (m *Mux) PullAccountAppInfoRelation(ctx context.Context, req *pb.PullAccountAppInfoRelationRequest) (
+	*pb.PullAccountAppInfoRelationResponse, error) {
+	s, ok := m.accountPlatforms[req.GetAccountId().GetPlatform()]
+	if !ok {
+		return nil, errors.Unsupported("Unsupported account")
+	}
+	return s.PullAccountAppInfoRelation(ctx, req)
+}
+
+func (m *Mux) SearchAppInfo(ctx context.Context, req *pb.SearchAppInfoRequest) (*pb.SearchAppInfoResponse, error) {
+	resp := new(pb.SearchAppInfoResponse)
+	succeeded := false
+	implemented, err := m.broadcast(m.services(func(f *librarian.FeatureSummary) bool {
+		return len(f.GetAppInfoSources()) > 0
+	}), func(s pb.LibrarianPorterServiceServer) error {
+		r, err := s.SearchAppInfo(ctx, req)
+		if err != nil {
+			return err
+		}
+		succeeded = true
+		resp.AppInfos = append(resp.AppInfos, r.GetAppInfos()...)
+		return nil
+	})
+	if !implemented {
+		return m.UnimplementedLibrarianPorterServiceServer.SearchAppInfo(ctx, req)
+	}
+	if !succeeded {
+		return nil, err
+	}
+	return resp, nil
+}
+
+func (m *Mux) PullFeed(ctx context.Context, req *pb.PullFeedRequest) (*pb.PullFeedResponse, error) {
+	s, ok := m.feedSources[req.GetSource().GetId()]
+	if !ok {
+		return nil, errors.Unsupported("Unsupported feed source")
+	}
+	return s.PullFeed(ctx, req)
+}
+
+func (m *Mux) ExecFeedItemAction(ctx context.Context, req *pb.ExecFeedItemActionRequest) (
+	*pb.ExecFeedItemActionResponse, error) {
+	s, ok := m.feedItemActions[req.GetAction().GetId()]
+	if !ok {
+		return nil, errors.Unsupported("Unsupported feed item action")
+	}
+	return s.ExecFeedItemAction(ctx, req)
+}
+
+func (m *Mux) EnableFeedSetter(ctx context.Context, req *pb.EnableFeedSetterRequest) (
+	*pb.EnableFeedSetterResponse, error) {
+	s, ok := m.feedSetters[req.GetSetter().GetId()]
+	if !ok {
+		return nil, errors.Unsupported("Unsupported feed setter")
+	}
+	resp, err := s.EnableFeedSetter(ctx, req)
+	if err == nil {
+		m.enabledMu.Lock()
+		m.enabledSetters[req.GetSetterId().GetId()] = s
+		m.enabledMu.Unlock()
+	}
+	return resp, err
+}
+
+func (m *Mux) DisableFeedSetter(ctx context.Context, req *pb.DisableFeedSetterRequest) (
+	*pb.DisableFeedSetterResponse, error) {
+	m.enabledMu.Lock()
+	s, ok := m.enabledSetters[req.GetSetterId().GetId()]
+	delete(m.enabledSetters, req.GetSetterId().GetId())
+	m.enabledMu.Unlock()
+	if ok {
+		return s.DisableFeedSetter(ctx, req)
+	}
+	// enabled before a restart, let every setter service check the id
+	implemented, err := m.broadcast(m.services(func(f *librarian.FeatureSummary) bool {
+		return len(f.GetFeedSetters()) > 0
+	}), func(s pb.LibrarianPorterServiceServer) error {
+		_, err := s.DisableFeedSetter(ctx, req)
+		return err
+	})
+	if !implemented {
+		return m.UnimplementedLibrarianPorterServiceServer.DisableFeedSetter(ctx, req)
+	}
+	if err != nil {
+		return nil, err
+	}
+	return new(pb.DisableFeedSetterResponse), nil
+}
+
+func (m *Mux) EnableFeedGetter(ctx context.Context, req *pb.EnableFeedGetterRequest) (
+	*pb.EnableFeedGetterResponse, error) {
+	s, ok := m.feedGetters[req.GetGetter().GetId()]
+	if !ok {
+		return nil, errors.Unsupported("Unsupported feed getter")
+	}
+	resp, err := s.EnableFeedGetter(ctx, req)
+	if err == nil {
+		m.enabledMu.Lock()
+		m.enabledGetters[req.GetGetterId().GetId()] = s
+		m.enabledMu.Unlock()
+	}
+	return resp, err
+}
+
+func (m *Mux) DisableFeedGetter(ctx context.Context, req *pb.DisableFeedGetterRequest) (
+	*pb.DisableFeedGetterResponse, error) {
+	m.enabledMu.Lock()
+	s, ok := m.enabledGetters[req.GetGetterId().GetId()]
+	delete(m.enabledGetters, req.GetGetterId().GetId())
+	m.enabledMu.Unlock()
+	if ok {
+		return s.DisableFeedGetter(ctx, req)
+	}
+	implemented, err := m.broadcast(m.services(func(f *librarian.FeatureSummary) bool {
+		return len(f.GetFeedGetters()) > 0
+	}), func(s pb.LibrarianPorterServiceServer) error {
+		_, err := s.DisableFeedGetter(ctx, req)
+		return err
+	})
+	if !implemented {
+		return m.UnimplementedLibrarianPorterServiceServer.DisableFeedGetter(ctx, req)
+	}
+	if err != nil {
+		return nil, err
+	}
+	return new(pb.DisableFeedGetterResponse), nil
+}
+
+func (m *Mux) PushFeedItems(ctx context.Context, req *pb.PushFeedItemsRequest) (*pb.PushFeedItemsResponse, error) {
+	s, ok := m.notifyDestinations[req.GetDestination().GetId()]
+	if !ok {
+		return nil, errors.Unsupported("Unsupported notify destination")
+	}
+	return s.PushFeedItems(ctx, req)
+}
+
+// services returns the services of mounts whose features match filter, all if filter is nil.
+func (m *Mux) services(filter func(*librarian.FeatureSummary) bool) []pb.LibrarianPorterServiceServer {
+	var services []pb.LibrarianPorterServiceServer
+	for _, mount := range m.mounts {
+		if filter == nil || filter(mount.Features) {
+			services = append(services, mount.Service)
+		}
+	}
+	return services
+}
+
+// broadcast calls every service, skipping those that do not implement the method.
+// It reports whether any service implements it and returns the first error.
+func (m *Mux) broadcast(
+	services []pb.LibrarianPorterServiceServer,
+	call func(pb.LibrarianPorterServiceServer) error,
+) (bool, error) {
+	implemented := false
+	var first error
+	for _, s := range services {
+		err := call(s)
+		if errors.IsUnimplemented(err) {
+			continue
+		}
+		implemented = true
+		if err != nil && first == nil {
+			first = err
+		}
+	}
+	return implemented, first
+}
@@ -0,0 +1,126 @@
+package tuihub
+
+import (
+	"context"
+	"strings"
+	"testing"
+
+	pb "github.com/tuihub/protos/pkg/librarian/porter/v1"
+	librarian "github.com/tuihub/protos/pkg/librarian/v1"
+	"github.com/tuihub/tuihub-go/errors"
+)
+
+type accountHandler struct {
+	pb.UnimplementedLibrarianPorterServiceServer
+}
+
+func (accountHandler) PullAccount(_ context.Context, req *pb.PullAccountRequest) (*pb.PullAccountResponse, error) {
+	return &pb.PullAccountResponse{Account: &librarian.Account{Name: req.GetAccountId().GetPlatformAccountId()}}, nil
+}
+
+func (accountHandler) SearchAppInfo(_ context.Context, req *pb.SearchAppInfoRequest) (
+	*pb.SearchAppInfoResponse, error) {
+	return &pb.SearchAppInfoResponse{AppInfos: []*librarian.AppInfo{{Name: req.GetName()}}}, nil
+}
+
+func (accountHandler) EnablePorter(context.Context, *pb.EnablePorterRequest) (*pb.EnablePorterResponse, error) {
+	return &pb.EnablePorterResponse{StatusMessage: "steam ok"}, nil
+}
+
+func muxInfo() *pb.GetPorterInformationResponse {
+	return &pb.GetPorterInformationResponse{
+		BinarySummary: &librarian.PorterBinarySummary{Name: "test"},
+		GlobalName:    "test",
+	}
+}
+
+func newTestMux(t *testing.T) *Mux {
+	t.Helper()
+	m, err := NewMux(muxInfo(),
+		Mount{Service: accountHandler{}, Features: &librarian.FeatureSummary{
+			AccountPlatforms: []*librarian.FeatureFlag{{Id: "steam"}},
+			AppInfoSources:   []*librarian.FeatureFlag{{Id: "steam"}},
+		}},
+		Mount{Service: testHandler{}, Features: &librarian.FeatureSummary{
+			FeedSources:        []*librarian.FeatureFlag{{Id: "rss"}},
+			NotifyDestinations: []*librarian.FeatureFlag{{Id: "telegram"}},
+		}},
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return m
+}
+
+func TestMuxRoutes(t *testing.T) {
+	m := newTestMux(t)
+	ctx := context.Background()
+
+	summary := m.Info().GetFeatureSummary()
+	if len(summary.GetAccountPlatforms()) != 1 || len(summary.GetFeedSources()) != 1 ||
+		len(summary.GetNotifyDestinations()) != 1 || len(summary.GetAppInfoSources()) != 1 {
+		t.Errorf("merged summary = %v", summary)
+	}
+	if err := checkPorter(m.Info(), m); err != nil {
+		t.Error(err)
+	}
+
+	account, err := m.PullAccount(ctx, &pb.PullAccountRequest{
+		AccountId: &librarian.AccountID{Platform: "steam", PlatformAccountId: "42"},
+	})
+	if err != nil || account.GetAccount().GetName() != "42" {
+		t.Errorf("PullAccount = %v, %v", account, err)
+	}
+	if _, err = m.PullFeed(ctx, &pb.PullFeedRequest{Source: &librarian.FeatureRequest{Id: "rss"}}); err != nil {
+		t.Errorf("PullFeed: %v", err)
+	}
+	_, err = m.PullFeed(ctx, &pb.PullFeedRequest{Source: &librarian.FeatureRequest{Id: "atom"}})
+	if errors.Reason(err) != errors.ReasonUnsupported {
+		t.Errorf("PullFeed unknown source: err = %v", err)
+	}
+	if _, err = m.PullAppInfo(ctx, &pb.PullAppInfoRequest{
+		AppInfoId: &librarian.AppInfoID{Source: "steam"},
+	}); !errors.IsUnimplemented(err) {
+		t.Errorf("PullAppInfo: err = %v, want Unimplemented from the mounted service", err)
+	}
+
+	search, err := m.SearchAppInfo(ctx, &pb.SearchAppInfoRequest{Name: "portal"})
+	if err != nil || len(search.GetAppInfos()) != 1 {
+		t.Errorf("SearchAppInfo = %v, %v", search, err)
+	}
+
+	// testHandler does not implement EnablePorter and is skipped
+	enable, err := m.EnablePorter(ctx, &pb.EnablePorterRequest{SephirahId: 1})
+	if err != nil || enable.GetStatusMessage() != "steam ok" {
+		t.Errorf("EnablePorter = %v, %v", enable, err)
+	}
+	if _, err = m.EnableContext(ctx, new(pb.EnableContextRequest)); !errors.IsUnimplemented(err) {
+		t.Errorf("EnableContext: err = %v, want Unimplemented", err)
+	}
+}
+
+func TestMuxConflict(t *testing.T) {
+	_, err := NewMux(muxInfo(),
+		Mount{Service: testHandler{}, Features: &librarian.FeatureSummary{
+			FeedSources: []*librarian.FeatureFlag{{Id: "rss"}},
+		}},
+		Mount{Service: testHandler{}, Features: &librarian.FeatureSummary{
+			FeedSources: []*librarian.FeatureFlag{{Id: "atom"}, {Id: "rss"}},
+		}},
+	)
+	if err == nil || !strings.Contains(err.Error(), `feed source "rss"`) {
+		t.Errorf("err = %v", err)
+	}
+
+	// the same id in different kinds does not conflict
+	if _, err = NewMux(muxInfo(),
+		Mount{Service: testHandler{}, Features: &librarian.FeatureSummary{
+			FeedSources: []*librarian.FeatureFlag{{Id: "telegram"}},
+		}},
+		Mount{Service: testHandler{}, Features: &librarian.FeatureSummary{
+			NotifyDestinations: []*librarian.FeatureFlag{{Id: "telegram"}},
+		}},
+	); err != nil {
+		t.Error(err)
+	}
+}
@@ -0,0 +1,101 @@
+// Package notify helps PushFeedItems handlers render feed items for notify destinations,
+// batch them within the message size limits of the destination and report per-item delivery results.
+package notify
+
+import (
+	"html"
+	"strings"
+)
+
+// Format is the markup language a destination parses messages in.
+type Format string
+
+const (
+	FormatPlain Format = "plain"
+	// FormatMarkdown is CommonMark as used by Discord and most chat webhooks.
+	FormatMarkdown Format = "markdown"
+	// FormatMarkdownV2 is the Telegram Bot API MarkdownV2 parse mode.
+	FormatMarkdownV2 Format = "markdownv2"
+	FormatHTML       Format = "html"
+)
+
+// LengthUnit is what a destination counts in its message length limit.
+type LengthUnit string
+
+const (
+	// UnitRunes counts Unicode code points. An empty unit counts runes too.
+	UnitRunes LengthUnit = "runes"
+	// UnitUTF16 counts UTF-16 code units, as the Telegram Bot API does.
+	UnitUTF16 LengthUnit = "utf16"
+)
+
+// Target describes the message limits of a destination.
+type Target struct {
+	Format Format
+	// MaxLen is the largest message in Unit, 0 for unlimited.
+	MaxLen int
+	Unit   LengthUnit
+	// MaxItems is the largest number of items in one message, 0 for unlimited.
+	MaxItems int
+	// Separator joins items batched into one message.
+	Separator string
+}
+
+// Presets for common destinations.
+var ( //nolint:gochecknoglobals,mnd // presets
+	Telegram = Target{Format: FormatMarkdownV2, MaxLen: 4096, Unit: UnitUTF16, MaxItems: 0, Separator: "\n\n"}
+	Discord  = Target{Format: FormatMarkdown, MaxLen: 2000, Unit: UnitRunes, MaxItems: 0, Separator: "\n\n"}
+	Email    = Target{Format: FormatHTML, MaxLen: 0, Unit: UnitRunes, MaxItems: 50, Separator: "<hr>"}
+	Webhook  = Target{Format: FormatPlain, MaxLen: 0, Unit: UnitRunes, MaxItems: 1, Separator: "\n"}
+)
+
+// Len returns the length of s counted in the unit of the target.
+func (t Target) Len(s string) int {
+	n := 0
+	for _, r := range s {
+		n++
+		if t.Unit == UnitUTF16 && r > 0xFFFF { //nolint:mnd // outside the basic multilingual plane
+			// encoded as a surrogate pair
+			n++
+		}
+	}
+	return n
+}
+
+// fits reports whether s is within the length limit of the target.
+func (t Target) fits(s string) bool {
+	return t.MaxLen <= 0 || t.Len(s) <= t.MaxLen
+}
+
+const (
+	markdownSpecial   = "\\`*_{}[]()#+-.!|~>"
+	markdownV2Special = "\\_*[]()~`>#+-=|{}.!"
+)
+
+// Escape escapes s so it is shown literally in the format.
+func Escape(format Format, s string) string {
+	switch format {
+	case FormatMarkdown:
+		return escapeChars(s, markdownSpecial)
+	case FormatMarkdownV2:
+		return escapeChars(s, markdownV2Special)
+	case FormatHTML:
+		return html.EscapeString(s)
+	case FormatPlain:
+		return s
+	default:
+		return s
+	}
+}
+
+func escapeChars(s, special string) string {
+	var b strings.Builder
+	b.Grow(len(s))
+	for _, r := range s {
+		if strings.ContainsRune(special, r) {
+			b.WriteByte('\\')
+		}
+		b.WriteRune(r)
+	}
+	return b.String()
+}
@@ -0,0 +1,247 @@
+package notify
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+
+	librarian "github.com/tuihub/protos/pkg/librarian/v1"
+	"google.golang.org/protobuf/proto"
+)
+
+// Message is one message to send, made of the rendered items at Items.
+type Message struct {
+	Text string
+	// Items are indexes into the pushed items.
+	Items []int
+}
+
+// Result is the delivery result of one pushed item.
+type Result struct {
+	Item *librarian.FeedItem
+	Err  error
+}
+
+// SendFunc delivers one message to the destination.
+type SendFunc func(ctx context.Context, text string) error
+
+// Notifier renders, batches and sends feed items to one destination.
+type Notifier struct {
+	target   Target
+	renderer *Renderer
+	send     SendFunc
+}
+
+// New creates a Notifier rendering items with tmpl in the format of target.
+func New(target Target, tmpl string, send SendFunc) (*Notifier, error) {
+	r, err := NewRenderer(target.Format, tmpl)
+	if err != nil {
+		return nil, err
+	}
+	return &Notifier{
+		target:   target,
+		renderer: r,
+		send:     send,
+	}, nil
+}
+
+// Push sends items in as few messages as the target allows and returns one result per item, in order.
+// An item fails if it can not be rendered or any message containing it fails to send.
+// Items too long for one message are sent alone, split over several messages.
+func (n *Notifier) Push(ctx context.Context, items []*librarian.FeedItem) []Result {
+	results := make([]Result, len(items))
+	texts := make([]string, len(items))
+	var (
+		messages []Message
+		batch    []int
+	)
+	for i, item := range items {
+		results[i].Item = item
+		parts, err := n.render(item)
+		if err != nil {
+			results[i].Err = fmt.Errorf("render: %w", err)
+			continue
+		}
+		if len(parts) == 1 {
+			texts[i] = parts[0]
+			batch = append(batch, i)
+			continue
+		}
+		messages = append(messages, Batch(n.target, texts, batch)...)
+		batch = nil
+		for _, part := range parts {
+			messages = append(messages, Message{Text: part, Items: []int{i}})
+		}
+	}
+	messages = append(messages, Batch(n.target, texts, batch)...)
+	for _, m := range messages {
+		if err := ctx.Err(); err != nil {
+			markFailed(results, m.Items, err)
+			continue
+		}
+		if err := n.send(ctx, m.Text); err != nil {
+			markFailed(results, m.Items, err)
+		}
+	}
+	return results
+}
+
+// render renders item in as many messages as needed to fit the target. The content, or the description
+// of items without content, is split on line or word boundaries before rendering, so the markup of
+// every message stays valid. Other fields are repeated in every message.
+func (n *Notifier) render(item *librarian.FeedItem) ([]string, error) {
+	text, err := n.renderer.Render(item)
+	if err != nil {
+		return nil, err
+	}
+	if n.target.fits(text) {
+		return []string{text}, nil
+	}
+	part := proto.Clone(item).(*librarian.FeedItem) //nolint:errcheck // clone of a feed item
+	long, set := item.GetContent(), func(s string) { part.Content = s }
+	if long == "" {
+		long, set = item.GetDescription(), func(s string) { part.Description = s }
+	}
+	for size := len([]rune(long)) / 2; size > 0; size /= 2 {
+		parts := make([]string, 0, len(long)/size+1)
+		for _, chunk := range split(long, size) {
+			set(chunk)
+			if text, err = n.renderer.Render(part); err != nil {
+				return nil, err
+			}
+			if !n.target.fits(text) {
+				parts = nil
+				break
+			}
+			parts = append(parts, text)
+		}
+		if parts != nil {
+			return parts, nil
+		}
+	}
+	return nil, fmt.Errorf("item does not fit in the message length limit of %d", n.target.MaxLen)
+}
+
+func markFailed(results []Result, items []int, err error) {
+	for _, i := range items {
+		if results[i].Err == nil {
+			results[i].Err = err
+		}
+	}
+}
+
+// Err joins the errors of failed results, nil if every item was delivered.
+func Err(results []Result) error {
+	var errs []error
+	for i, r := range results {
+		if r.Err != nil {
+			errs = append(errs, fmt.Errorf("item %d: %w", i, r.Err))
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// Batch joins texts[i] for i in indexes into messages that fit target, in order.
+// A text longer than target.MaxLen is sent alone, Push splits long items before rendering them.
+func Batch(target Target, texts []string, indexes []int) []Message {
+	var (
+		res []Message
+		cur *Message
+	)
+	flush := func() {
+		if cur != nil {
+			res = append(res, *cur)
+			cur = nil
+		}
+	}
+	for _, i := range indexes {
+		text := texts[i]
+		if !target.fits(text) {
+			flush()
+			res = append(res, Message{Text: text, Items: []int{i}})
+			continue
+		}
+		if cur != nil {
+			joined := cur.Text + target.Separator + text
+			full := target.MaxItems > 0 && len(cur.Items) >= target.MaxItems
+			if !full && target.fits(joined) {
+				cur.Text = joined
+				cur.Items = append(cur.Items, i)
+				continue
+			}
+			flush()
+		}
+		cur = &Message{Text: text, Items: []int{i}}
+	}
+	flush()
+	return res
+}
+
+// split cuts s into chunks of at most n runes, preferring to cut after a newline, then after a space.
+func split(s string, n int) []string {
+	var res []string
+	runes := []rune(s)
+	for len(runes) > n {
+		cut := n
+		if i := lastIndex(runes[:n], '\n'); i > 0 {
+			cut = i + 1
+		} else if i = lastIndex(runes[:n], ' '); i > 0 {
+			cut = i + 1
+		}
+		res = append(res, string(runes[:cut]))
+		runes = runes[cut:]
+	}
+	if len(runes) > 0 {
+		res = append(res, string(runes))
+	}
+	return res
+}
+
+func lastIndex(runes []rune, r rune) int {
+	for i := len(runes) - 1; i >= 0; i-- {
+		if runes[i] == r {
+			return i
+		}
+	}
+	return -1
+}
+
+// PostJSON returns a SendFunc posting body(text) as JSON to url, e.g. for Discord webhooks
+//
+//	notify.PostJSON(nil, webhookURL, func(text string) interface{} {
+//		return map[string]string{"content": text}
+//	})
+//
+// Responses other than 2xx are errors. A nil client uses http.DefaultClient.
+func PostJSON(client *http.Client, url string, body func(text string) interface{}) SendFunc {
+	if client == nil {
+		client = http.DefaultClient
+	}
+	return func(ctx context.Context, text string) error {
+		b, err := json.Marshal(body(text))
+		if err != nil {
+			return err
+		}
+		req, err := http.NewRequestWithContext(ctx, http.MethodPost, url, bytes.NewReader(b))
+		if err != nil {
+			return err
+		}
+		req.Header.Set("Content-Type", "application/json")
+		resp, err := client.Do(req)
+		if err != nil {
+			return err
+		}
+		defer resp.Body.Close()
+		if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+			msg, _ := io.ReadAll(io.LimitReader(resp.Body, 512)) //nolint:mnd // enough for an error message
+			// url is left out, it may carry a bot token
+			return fmt.Errorf("post: %s: %s", resp.Status, strings.TrimSpace(string(msg)))
+		}
+		return nil
+	}
+}
@@ -0,0 +1,222 @@
+package notify
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+
+	librarian "github.com/tuihub/protos/pkg/librarian/v1"
+)
+
+func TestEscape(t *testing.T) {
+	tests := []struct {
+		format Format
+		in     string
+		want   string
+	}{
+		{FormatPlain, "a_b <c>", "a_b <c>"},
+		{FormatMarkdown, "1. *a* [b](c)", `1\. \*a\* \[b\]\(c\)`},
+		{FormatMarkdownV2, "v1.0 = done!", `v1\.0 \= done\!`},
+		{FormatHTML, `<a href="x">&</a>`, "&lt;a href=&#34;x&#34;&gt;&amp;&lt;/a&gt;"},
+	}
+	for _, tt := range tests {
+		if got := Escape(tt.format, tt.in); got != tt.want {
+			t.Errorf("Escape(%s, %q) = %q, want %q", tt.format, tt.in, got, tt.want)
+		}
+	}
+}
+
+func TestRender(t *testing.T) {
+	item := &librarian.FeedItem{
+		Title:   "Release v1.0_beta",
+		Link:    "https://example.com/a_(b)",
+		Authors: []*librarian.FeedPerson{{Name: "Alice"}, {Name: "Bob"}},
+		Content: "<script>x</script>",
+	}
+	tests := []struct {
+		format Format
+		tmpl   string
+		want   string
+	}{
+		{FormatMarkdownV2, "[{{.Title}}]({{.Link}}) by {{join .Authors \", \"}}",
+			`[Release v1\.0\_beta](https://example.com/a_(b\)) by Alice, Bob`},
+		{FormatHTML, `<a href="{{.Link}}">{{.Title}}</a>{{.Content}}`,
+			`<a href="https://example.com/a_%28b%29">Release v1.0_beta</a>&lt;script&gt;x&lt;/script&gt;`},
+		{FormatPlain, "{{truncate 8 .Title}}", "Release…"},
+		{FormatMarkdownV2, "{{truncate 10 .Title}}", `Release v…`},
+		{FormatPlain, "{{.Raw.GetLink}}", "https://example.com/a_(b)"},
+	}
+	for _, tt := range tests {
+		r, err := NewRenderer(tt.format, tt.tmpl)
+		if err != nil {
+			t.Fatal(err)
+		}
+		got, err := r.Render(item)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got != tt.want {
+			t.Errorf("%s %q = %q, want %q", tt.format, tt.tmpl, got, tt.want)
+		}
+	}
+	if _, err := NewRenderer(FormatPlain, "{{.Title"); err == nil {
+		t.Error("NewRenderer accepted a broken template")
+	}
+}
+
+func TestBatch(t *testing.T) {
+	texts := []string{"aaaa", "bbbb", "cccc", "dddd eeee ffff", "gg"}
+	all := []int{0, 1, 2, 3, 4}
+	tests := []struct {
+		name   string
+		target Target
+		want   []Message
+	}{
+		{"unlimited", Target{Format: FormatPlain, MaxLen: 0, Unit: "", MaxItems: 0, Separator: "|"}, []Message{
+			{Text: "aaaa|bbbb|cccc|dddd eeee ffff|gg", Items: all},
+		}},
+		{"max items", Target{Format: FormatPlain, MaxLen: 0, Unit: "", MaxItems: 2, Separator: "|"}, []Message{
+			{Text: "aaaa|bbbb", Items: []int{0, 1}},
+			{Text: "cccc|dddd eeee ffff", Items: []int{2, 3}},
+			{Text: "gg", Items: []int{4}},
+		}},
+		{"max len", Target{Format: FormatPlain, MaxLen: 10, Unit: "", MaxItems: 0, Separator: "|"}, []Message{
+			{Text: "aaaa|bbbb", Items: []int{0, 1}},
+			{Text: "cccc", Items: []int{2}},
+			{Text: "dddd eeee ffff", Items: []int{3}},
+			{Text: "gg", Items: []int{4}},
+		}},
+	}
+	for _, tt := range tests {
+		got := Batch(tt.target, texts, all)
+		if len(got) != len(tt.want) {
+			t.Errorf("%s: got %d messages %q, want %d", tt.name, len(got), got, len(tt.want))
+			continue
+		}
+		for i := range got {
+			if got[i].Text != tt.want[i].Text || len(got[i].Items) != len(tt.want[i].Items) {
+				t.Errorf("%s: message %d = %+v, want %+v", tt.name, i, got[i], tt.want[i])
+			}
+		}
+	}
+	if n := Telegram.Len("a😀"); n != 3 {
+		t.Errorf("Telegram.Len = %d, want 3", n)
+	}
+	if n := Discord.Len("a😀"); n != 2 {
+		t.Errorf("Discord.Len = %d, want 2", n)
+	}
+}
+
+// telegramStandIn accepts sendMessage calls and rejects messages containing "fail".
+func telegramStandIn(t *testing.T) (*httptest.Server, *[]string) {
+	t.Helper()
+	var (
+		mu       sync.Mutex
+		received []string
+	)
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var body struct {
+			ChatID    string `json:"chat_id"`
+			Text      string `json:"text"`
+			ParseMode string `json:"parse_mode"`
+		}
+		if err := json.NewDecoder(r.Body).Decode(&body); err != nil || body.ParseMode != "MarkdownV2" {
+			http.Error(w, "bad request", http.StatusBadRequest)
+			return
+		}
+		if strings.Contains(body.Text, "fail") {
+			http.Error(w, `{"ok":false}`, http.StatusBadRequest)
+			return
+		}
+		mu.Lock()
+		received = append(received, body.Text)
+		mu.Unlock()
+		_, _ = w.Write([]byte(`{"ok":true}`))
+	}))
+	t.Cleanup(srv.Close)
+	return srv, &received
+}
+
+func TestPush(t *testing.T) {
+	srv, received := telegramStandIn(t)
+	send := PostJSON(srv.Client(), srv.URL+"/bottoken/sendMessage", func(text string) interface{} {
+		return map[string]string{"chat_id": "1", "text": text, "parse_mode": "MarkdownV2"}
+	})
+	target := Telegram
+	target.MaxItems = 2
+	n, err := New(target, "{{.Title}}{{if .Raw.GetLink}} {{.Link}}{{else}}{{index .Authors 5}}{{end}}", send)
+	if err != nil {
+		t.Fatal(err)
+	}
+	items := []*librarian.FeedItem{
+		{Title: "one.", Link: "https://a/1"},
+		{Title: "two", Link: "https://a/2"},
+		{Title: "three fail", Link: "https://a/3"},
+		{Title: "four", Link: "https://a/4"},
+		{Title: "no link"},
+	}
+	results := n.Push(context.Background(), items)
+	if len(results) != len(items) {
+		t.Fatalf("results = %d", len(results))
+	}
+	for i, wantErr := range []bool{false, false, true, true, true} {
+		if (results[i].Err != nil) != wantErr {
+			t.Errorf("item %d: err = %v, want error %v", i, results[i].Err, wantErr)
+		}
+		if results[i].Item != items[i] {
+			t.Errorf("item %d: result for another item", i)
+		}
+	}
+	if len(*received) != 1 || (*received)[0] != "one\\. https://a/1\n\ntwo https://a/2" {
+		t.Errorf("received = %q", *received)
+	}
+	if err = Err(results); err == nil || strings.Contains(err.Error(), "bottoken") {
+		t.Errorf("Err = %v", err)
+	}
+}
+
+func TestPushSplit(t *testing.T) {
+	var received []string
+	send := func(_ context.Context, text string) error {
+		received = append(received, text)
+		return nil
+	}
+	target := Telegram
+	target.MaxLen = 40
+	n, err := New(target, "*{{.Title}}*\n{{.Content}}", send)
+	if err != nil {
+		t.Fatal(err)
+	}
+	content := "a.b 😀😀 c*d e_f g.h i.j k.l m.n o.p q.r s.t u.v w.x y.z"
+	results := n.Push(context.Background(), []*librarian.FeedItem{{Title: "t.", Content: content}})
+	if err = Err(results); err != nil {
+		t.Fatal(err)
+	}
+	if len(received) < 2 {
+		t.Fatalf("received = %q, want several messages", received)
+	}
+	var joined string
+	for _, text := range received {
+		if target.Len(text) > target.MaxLen {
+			t.Errorf("message %q longer than %d", text, target.MaxLen)
+		}
+		body, ok := strings.CutPrefix(text, "*t\\.*\n")
+		if !ok {
+			t.Errorf("message %q does not start with the title", text)
+		}
+		joined += body
+	}
+	if joined != Escape(FormatMarkdownV2, content) {
+		t.Errorf("content = %q", joined)
+	}
+
+	n, _ = New(target, "{{.Title}}", send)
+	results = n.Push(context.Background(), []*librarian.FeedItem{{Title: strings.Repeat("x", 41)}})
+	if results[0].Err == nil {
+		t.Error("item without content to split succeeded")
+	}
+}
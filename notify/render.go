@@ -0,0 +1,123 @@
+package notify
+
+import (
+	htmltemplate "html/template"
+	"strings"
+	"text/template"
+	"time"
+
+	librarian "github.com/tuihub/protos/pkg/librarian/v1"
+)
+
+// Item is the data a template is executed with. For markdown and plain formats every string
+// field is already escaped for the format, use .Raw for the original feed item.
+// HTML templates escape contextually and get unescaped fields.
+type Item struct {
+	Title       string
+	Link        string
+	Description string
+	Content     string
+	Authors     []string
+	Published   time.Time
+	Image       string
+	Raw         *librarian.FeedItem
+}
+
+// Renderer renders feed items with a user supplied Go template.
+type Renderer struct {
+	format Format
+	text   *template.Template
+	html   *htmltemplate.Template
+}
+
+// funcs are available in templates in addition to the builtin functions.
+func funcs() map[string]interface{} {
+	return map[string]interface{}{
+		"truncate": truncate,
+		"join":     strings.Join,
+	}
+}
+
+// NewRenderer parses tmpl, using html/template for FormatHTML and text/template otherwise.
+func NewRenderer(format Format, tmpl string) (*Renderer, error) {
+	r := &Renderer{format: format, text: nil, html: nil}
+	var err error
+	if format == FormatHTML {
+		r.html, err = htmltemplate.New("item").Funcs(funcs()).Parse(tmpl)
+	} else {
+		r.text, err = template.New("item").Funcs(funcs()).Parse(tmpl)
+	}
+	if err != nil {
+		return nil, err
+	}
+	return r, nil
+}
+
+// Render renders one item.
+func (r *Renderer) Render(item *librarian.FeedItem) (string, error) {
+	var b strings.Builder
+	var err error
+	if r.html != nil {
+		err = r.html.Execute(&b, newItem(FormatPlain, item))
+	} else {
+		err = r.text.Execute(&b, newItem(r.format, item))
+	}
+	if err != nil {
+		return "", err
+	}
+	return b.String(), nil
+}
+
+func newItem(format Format, item *librarian.FeedItem) *Item {
+	authors := make([]string, 0, len(item.GetAuthors()))
+	for _, a := range item.GetAuthors() {
+		authors = append(authors, Escape(format, a.GetName()))
+	}
+	var published time.Time
+	if item.GetPublishedParsed() != nil {
+		published = item.GetPublishedParsed().AsTime()
+	}
+	return &Item{
+		Title:       Escape(format, item.GetTitle()),
+		Link:        escapeLink(format, item.GetLink()),
+		Description: Escape(format, item.GetDescription()),
+		Content:     Escape(format, item.GetContent()),
+		Authors:     authors,
+		Published:   published,
+		Image:       escapeLink(format, item.GetImage().GetUrl()),
+		Raw:         item,
+	}
+}
+
+// escapeLink escapes a URL for use inside a markdown link target, where only the closing
+// parenthesis and backslash need escaping.
+func escapeLink(format Format, s string) string {
+	switch format { //nolint:exhaustive // other formats need no link escaping
+	case FormatMarkdown, FormatMarkdownV2:
+		return escapeChars(s, `\)`)
+	default:
+		return s
+	}
+}
+
+// truncate shortens s to at most n characters, ending with an ellipsis when cut.
+// A cut escape sequence is dropped, so escaped fields stay valid.
+func truncate(n int, s string) string {
+	runes := []rune(s)
+	if n <= 0 || len(runes) <= n {
+		return s
+	}
+	cut := runes[:n-1]
+	if endsWithEscape(cut) {
+		cut = cut[:len(cut)-1]
+	}
+	return string(cut) + "…"
+}
+
+func endsWithEscape(runes []rune) bool {
+	n := 0
+	for i := len(runes) - 1; i >= 0 && runes[i] == '\\'; i-- {
+		n++
+	}
+	return n%2 == 1
+}
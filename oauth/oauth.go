@@ -0,0 +1,333 @@
+// Package oauth links upstream accounts that require OAuth2 user authorization, such as Discord
+// or GitHub, and supplies PullAccount handlers with authorized clients.
+//
+// A Manager builds authorization URLs, serves the redirect callback on the porter HTTP server,
+// and keeps tokens per (platform, platform_account_id) in a kv.Store, refreshing them as needed:
+//
+//	m := oauth.New("github", &oauth2.Config{
+//		ClientID:     id,
+//		ClientSecret: secret,
+//		Endpoint:     github.Endpoint,
+//		RedirectURL:  "https://porter.example.com" + oauth.CallbackPath("github"),
+//	}, p.KV, oauth.WithIdentify(githubLogin))
+//	err = p.HandleOAuth(m)
+//
+//	// in PullAccount
+//	client, err := m.Client(ctx, req.GetAccountId().GetPlatformAccountId())
+package oauth
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/base64"
+	"encoding/json"
+	stderrors "errors"
+	"fmt"
+	"html"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/tuihub/tuihub-go/errors"
+	"github.com/tuihub/tuihub-go/kv"
+
+	"golang.org/x/oauth2"
+)
+
+const (
+	defaultStateTTL = 10 * time.Minute
+	stateLen        = 24
+)
+
+// StoreFunc returns the store keeping states and tokens, usually Porter.KV.
+type StoreFunc func() (kv.Store, error)
+
+// IdentifyFunc returns the platform account id of the user who authorized client.
+type IdentifyFunc func(ctx context.Context, client *http.Client) (string, error)
+
+type Manager struct {
+	platform string
+	config   *oauth2.Config
+	store    StoreFunc
+	identify IdentifyFunc
+	client   *http.Client
+	stateTTL time.Duration
+
+	refreshing sync.Map // account -> *sync.Mutex
+}
+
+type Option func(*Manager)
+
+// WithIdentify looks up the account of a new token in the callback. Without it the account
+// passed to AuthCodeURL is trusted, with it a different account is rejected.
+func WithIdentify(f IdentifyFunc) Option {
+	return func(m *Manager) {
+		m.identify = f
+	}
+}
+
+// WithHTTPClient sets the client used for token requests and as the base of Client,
+// defaults to http.DefaultClient.
+func WithHTTPClient(c *http.Client) Option {
+	return func(m *Manager) {
+		m.client = c
+	}
+}
+
+// WithStateTTL sets how long an authorization URL can be used, defaults to ten minutes.
+func WithStateTTL(d time.Duration) Option {
+	return func(m *Manager) {
+		if d > 0 {
+			m.stateTTL = d
+		}
+	}
+}
+
+// New creates a Manager for the account platform with id platform.
+func New(platform string, config *oauth2.Config, store StoreFunc, options ...Option) *Manager {
+	m := &Manager{
+		platform: platform,
+		config:   config,
+		store:    store,
+		identify: nil,
+		client:   http.DefaultClient,
+		stateTTL: defaultStateTTL,
+
+		refreshing: sync.Map{},
+	}
+	for _, o := range options {
+		o(m)
+	}
+	return m
+}
+
+// Static returns a StoreFunc always returning s.
+func Static(s kv.Store) StoreFunc {
+	return func() (kv.Store, error) {
+		return s, nil
+	}
+}
+
+// CallbackPath is the path of the redirect callback of platform on the porter HTTP server.
+func CallbackPath(platform string) string {
+	return "/oauth/" + platform + "/callback"
+}
+
+// CallbackPath is the path the Manager serves, RedirectURL of the config must point to it.
+func (m *Manager) CallbackPath() string {
+	return CallbackPath(m.platform)
+}
+
+type pendingState struct {
+	Account  string `json:"account,omitempty"`
+	Verifier string `json:"verifier"`
+}
+
+// AuthCodeURL returns the URL the user opens to authorize the porter. platformAccountID may be
+// empty when the Manager identifies accounts itself, see WithIdentify.
+func (m *Manager) AuthCodeURL(ctx context.Context, platformAccountID string) (string, error) {
+	store, err := m.store()
+	if err != nil {
+		return "", err
+	}
+	b := make([]byte, stateLen)
+	if _, err = rand.Read(b); err != nil {
+		return "", err
+	}
+	state := base64.RawURLEncoding.EncodeToString(b)
+	pending := pendingState{Account: platformAccountID, Verifier: oauth2.GenerateVerifier()}
+	data, err := json.Marshal(pending)
+	if err != nil {
+		return "", err
+	}
+	if err = store.Put(ctx, stateKey(state), data, m.stateTTL); err != nil {
+		return "", err
+	}
+	return m.config.AuthCodeURL(state,
+		oauth2.AccessTypeOffline, oauth2.S256ChallengeOption(pending.Verifier)), nil
+}
+
+// ServeHTTP handles the redirect of the platform after the user has authorized the porter.
+func (m *Manager) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	q := r.URL.Query()
+	if e := q.Get("error"); e != "" {
+		http.Error(w, fmt.Sprintf("authorization failed: %s %s", e, q.Get("error_description")),
+			http.StatusBadRequest)
+		return
+	}
+	account, err := m.exchange(ctx, q.Get("state"), q.Get("code"))
+	if err != nil {
+		se := errors.FromError(err)
+		status := http.StatusBadGateway
+		if se.Reason == errors.ReasonInvalidArgument {
+			status = http.StatusBadRequest
+		}
+		http.Error(w, se.Message, status)
+		return
+	}
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	_, _ = fmt.Fprintf(w, "<p>Authorized %s account %s, you can close this page.</p>\n",
+		html.EscapeString(m.platform), html.EscapeString(account))
+}
+
+// exchange consumes state, exchanges code for a token and stores it. It returns the account id.
+func (m *Manager) exchange(ctx context.Context, state, code string) (string, error) {
+	if state == "" || code == "" {
+		return "", errors.InvalidArgument("missing state or code")
+	}
+	store, err := m.store()
+	if err != nil {
+		return "", errors.UpstreamUnavailable("%v", err)
+	}
+	data, err := store.Get(ctx, stateKey(state))
+	if err != nil {
+		return "", errors.InvalidArgument("unknown or expired state")
+	}
+	// a state is used once even if the callback is replayed
+	if ok, casErr := store.CompareAndSwap(ctx, stateKey(state), data, nil, 0); casErr != nil || !ok {
+		return "", errors.InvalidArgument("unknown or expired state")
+	}
+	var pending pendingState
+	if err = json.Unmarshal(data, &pending); err != nil {
+		return "", errors.Internal("decode state: %v", err)
+	}
+	ctx = context.WithValue(ctx, oauth2.HTTPClient, m.client)
+	token, err := m.config.Exchange(ctx, code, oauth2.VerifierOption(pending.Verifier))
+	if err != nil {
+		return "", errors.UpstreamUnavailable("exchange code: %v", err)
+	}
+	account := pending.Account
+	if m.identify != nil {
+		identified, identifyErr := m.identify(ctx, oauth2.NewClient(ctx, oauth2.StaticTokenSource(token)))
+		if identifyErr != nil {
+			return "", errors.UpstreamUnavailable("identify account: %v", identifyErr)
+		}
+		if account != "" && identified != account {
+			return "", errors.InvalidArgument("authorized account %s, expected %s", identified, account)
+		}
+		account = identified
+	}
+	if account == "" {
+		return "", errors.InvalidArgument("unknown account")
+	}
+	if err = m.save(ctx, store, account, token); err != nil {
+		return "", errors.Internal("save token: %v", err)
+	}
+	return account, nil
+}
+
+// Token returns the stored token of an account, which may have expired.
+func (m *Manager) Token(ctx context.Context, platformAccountID string) (*oauth2.Token, error) {
+	store, err := m.store()
+	if err != nil {
+		return nil, err
+	}
+	data, err := store.Get(ctx, tokenKey(m.platform, platformAccountID))
+	if stderrors.Is(err, kv.ErrNotFound) {
+		return nil, errors.NotFound("%s account %s is not authorized", m.platform, platformAccountID)
+	}
+	if err != nil {
+		return nil, err
+	}
+	token := new(oauth2.Token)
+	if err = json.Unmarshal(data, token); err != nil {
+		return nil, err
+	}
+	return token, nil
+}
+
+// Client returns a client authorized as an account. Its token is refreshed when it expires
+// and the refreshed token is stored. Refreshes of an account are serialized, so that providers
+// rotating refresh tokens see a single refresh.
+func (m *Manager) Client(ctx context.Context, platformAccountID string) (*http.Client, error) {
+	token, err := m.Token(ctx, platformAccountID)
+	if err != nil {
+		return nil, err
+	}
+	store, err := m.store()
+	if err != nil {
+		return nil, err
+	}
+	ctx = context.WithValue(ctx, oauth2.HTTPClient, m.client)
+	return oauth2.NewClient(ctx, oauth2.ReuseTokenSource(token, &refreshingTokenSource{
+		// the client outlives the request it was created for
+		ctx:     context.WithoutCancel(ctx),
+		m:       m,
+		store:   store,
+		account: platformAccountID,
+	})), nil
+}
+
+// Forget deletes the token of an account.
+func (m *Manager) Forget(ctx context.Context, platformAccountID string) error {
+	store, err := m.store()
+	if err != nil {
+		return err
+	}
+	return store.Delete(ctx, tokenKey(m.platform, platformAccountID))
+}
+
+func (m *Manager) save(ctx context.Context, store kv.Store, account string, token *oauth2.Token) error {
+	data, err := json.Marshal(token)
+	if err != nil {
+		return err
+	}
+	return store.Put(ctx, tokenKey(m.platform, account), data, 0)
+}
+
+func (m *Manager) refreshLock(account string) *sync.Mutex {
+	mu, _ := m.refreshing.LoadOrStore(account, new(sync.Mutex))
+	return mu.(*sync.Mutex) //nolint:errcheck // only *sync.Mutex is stored
+}
+
+type refreshingTokenSource struct {
+	ctx     context.Context //nolint:containedctx // used for refreshes after the request is done
+	m       *Manager
+	store   kv.Store
+	account string
+}
+
+// Token refreshes the stored token of the account unless another client already did.
+func (s *refreshingTokenSource) Token() (*oauth2.Token, error) {
+	mu := s.m.refreshLock(s.account)
+	mu.Lock()
+	defer mu.Unlock()
+
+	key := tokenKey(s.m.platform, s.account)
+	data, err := s.store.Get(s.ctx, key)
+	if stderrors.Is(err, kv.ErrNotFound) {
+		return nil, errors.NotFound("%s account %s is not authorized", s.m.platform, s.account)
+	}
+	if err != nil {
+		return nil, err
+	}
+	token := new(oauth2.Token)
+	if err = json.Unmarshal(data, token); err != nil {
+		return nil, err
+	}
+	if token.Valid() {
+		return token, nil
+	}
+	refreshed, err := s.m.config.TokenSource(s.ctx, token).Token()
+	if err != nil {
+		return nil, errors.UpstreamUnavailable("refresh %s token: %v", s.m.platform, err)
+	}
+	saved, err := json.Marshal(refreshed)
+	if err != nil {
+		return nil, err
+	}
+	// another replica refreshing or Forget wins, the refreshed token is still good for this client
+	if _, err = s.store.CompareAndSwap(s.ctx, key, data, saved, 0); err != nil {
+		return nil, err
+	}
+	return refreshed, nil
+}
+
+func stateKey(state string) string {
+	return "oauth/state/" + state
+}
+
+func tokenKey(platform, account string) string {
+	return "oauth/token/" + platform + "/" + account
+}
@@ -0,0 +1,241 @@
+package oauth_test
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/tuihub/tuihub-go/errors"
+	"github.com/tuihub/tuihub-go/kv"
+	"github.com/tuihub/tuihub-go/oauth"
+
+	"golang.org/x/oauth2"
+)
+
+// fakeProvider is an OAuth2 server for the account octocat. Access tokens of authorization codes
+// are short-lived, refresh tokens are rotated like Discord does.
+type fakeProvider struct {
+	mu         sync.Mutex
+	challenges map[string]string // code -> PKCE challenge
+	refresh    string            // the only valid refresh token
+	issued     atomic.Int32
+	refreshes  atomic.Int32
+}
+
+func (p *fakeProvider) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	switch r.URL.Path {
+	case "/token":
+		_ = r.ParseForm()
+		switch r.PostForm.Get("grant_type") {
+		case "authorization_code":
+			p.mu.Lock()
+			challenge, ok := p.challenges[r.PostForm.Get("code")]
+			delete(p.challenges, r.PostForm.Get("code"))
+			p.mu.Unlock()
+			sum := sha256.Sum256([]byte(r.PostForm.Get("code_verifier")))
+			if !ok || base64.RawURLEncoding.EncodeToString(sum[:]) != challenge {
+				http.Error(w, `{"error":"invalid_grant"}`, http.StatusBadRequest)
+				return
+			}
+		case "refresh_token":
+			p.mu.Lock()
+			valid := p.refresh != "" && r.PostForm.Get("refresh_token") == p.refresh
+			p.mu.Unlock()
+			if !valid {
+				http.Error(w, `{"error":"invalid_grant"}`, http.StatusBadRequest)
+				return
+			}
+			p.refreshes.Add(1)
+		}
+		// within the expiry delta of oauth2, the token of a code is refreshed on first use
+		expiresIn := 1
+		if r.PostForm.Get("grant_type") == "refresh_token" {
+			expiresIn = 3600
+		}
+		p.mu.Lock()
+		p.refresh = fmt.Sprintf("refresh-%d", p.issued.Add(1))
+		refresh := p.refresh
+		p.mu.Unlock()
+		w.Header().Set("Content-Type", "application/json")
+		_ = json.NewEncoder(w).Encode(map[string]interface{}{
+			"access_token":  strings.Replace(refresh, "refresh", "access", 1),
+			"token_type":    "Bearer",
+			"refresh_token": refresh,
+			"expires_in":    expiresIn,
+		})
+	case "/user":
+		if !strings.HasPrefix(r.Header.Get("Authorization"), "Bearer access-") {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		_, _ = io.WriteString(w, "octocat")
+	}
+}
+
+// authorize plays the user approving the authorization URL, returning the callback URL.
+func (p *fakeProvider) authorize(t *testing.T, authURL string) string {
+	t.Helper()
+	u, err := url.Parse(authURL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	q := u.Query()
+	if q.Get("code_challenge_method") != "S256" {
+		t.Fatalf("auth URL without PKCE: %s", authURL)
+	}
+	p.mu.Lock()
+	p.challenges["code-1"] = q.Get("code_challenge")
+	p.mu.Unlock()
+	return q.Get("redirect_uri") + "?" + url.Values{"state": {q.Get("state")}, "code": {"code-1"}}.Encode()
+}
+
+func identify(ctx context.Context, client *http.Client) (string, error) {
+	req, _ := http.NewRequestWithContext(ctx, http.MethodGet, "http://"+ctx.Value(hostKey{}).(string)+"/user", nil)
+	resp, err := client.Do(req)
+	if err != nil {
+		return "", err
+	}
+	defer resp.Body.Close()
+	b, err := io.ReadAll(resp.Body)
+	return string(b), err
+}
+
+type hostKey struct{}
+
+func TestManager(t *testing.T) {
+	p := &fakeProvider{mu: sync.Mutex{}, challenges: make(map[string]string)}
+	srv := httptest.NewServer(p)
+	defer srv.Close()
+	host := strings.TrimPrefix(srv.URL, "http://")
+
+	store := kv.NewMemory()
+	m := oauth.New("github", &oauth2.Config{
+		ClientID:     "id",
+		ClientSecret: "secret",
+		Endpoint:     oauth2.Endpoint{AuthURL: srv.URL + "/authorize", TokenURL: srv.URL + "/token"},
+		RedirectURL:  "http://porter.test" + oauth.CallbackPath("github"),
+	}, oauth.Static(store), oauth.WithIdentify(identify), oauth.WithHTTPClient(srv.Client()))
+	ctx := context.WithValue(context.Background(), hostKey{}, host)
+
+	authURL, err := m.AuthCodeURL(ctx, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	callback := p.authorize(t, authURL)
+	for i, want := range []int{http.StatusOK, http.StatusBadRequest} {
+		rec := httptest.NewRecorder()
+		m.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, callback, nil).WithContext(ctx))
+		if rec.Code != want {
+			t.Fatalf("callback %d: status = %d, body = %s", i, rec.Code, rec.Body)
+		}
+		if i == 0 && !strings.Contains(rec.Body.String(), "octocat") {
+			t.Errorf("callback body = %s", rec.Body)
+		}
+	}
+
+	client, err := m.Client(ctx, "octocat")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if account, err := identify(ctx, client); err != nil || account != "octocat" {
+		t.Errorf("authorized request = %q, %v", account, err)
+	}
+	// the expired token was refreshed and stored
+	token, err := m.Token(ctx, "octocat")
+	if err != nil || token.AccessToken != "access-2" {
+		t.Errorf("stored token = %+v, %v", token, err)
+	}
+
+	if _, err = m.Client(ctx, "someone"); !errors.IsNotFound(err) {
+		t.Errorf("unknown account: err = %v", err)
+	}
+	if err = m.Forget(ctx, "octocat"); err != nil {
+		t.Fatal(err)
+	}
+	if _, err = m.Token(ctx, "octocat"); !errors.IsNotFound(err) {
+		t.Errorf("forgotten account: err = %v", err)
+	}
+}
+
+func TestManagerRejectsOtherAccount(t *testing.T) {
+	p := &fakeProvider{mu: sync.Mutex{}, challenges: make(map[string]string)}
+	srv := httptest.NewServer(p)
+	defer srv.Close()
+
+	m := oauth.New("github", &oauth2.Config{
+		ClientID:    "id",
+		Endpoint:    oauth2.Endpoint{AuthURL: srv.URL + "/authorize", TokenURL: srv.URL + "/token"},
+		RedirectURL: "http://porter.test" + oauth.CallbackPath("github"),
+	}, oauth.Static(kv.NewMemory()), oauth.WithIdentify(identify))
+	ctx := context.WithValue(context.Background(), hostKey{}, strings.TrimPrefix(srv.URL, "http://"))
+
+	authURL, err := m.AuthCodeURL(ctx, "hubot")
+	if err != nil {
+		t.Fatal(err)
+	}
+	rec := httptest.NewRecorder()
+	m.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, p.authorize(t, authURL), nil).WithContext(ctx))
+	if rec.Code != http.StatusBadRequest || !strings.Contains(rec.Body.String(), "expected hubot") {
+		t.Errorf("status = %d, body = %s", rec.Code, rec.Body)
+	}
+}
+
+func TestClientConcurrentRefresh(t *testing.T) {
+	p := &fakeProvider{mu: sync.Mutex{}, challenges: make(map[string]string), refresh: "refresh-0"}
+	srv := httptest.NewServer(p)
+	defer srv.Close()
+
+	store := kv.NewMemory()
+	m := oauth.New("discord", &oauth2.Config{
+		ClientID: "id",
+		Endpoint: oauth2.Endpoint{AuthURL: srv.URL + "/authorize", TokenURL: srv.URL + "/token"},
+	}, oauth.Static(store), oauth.WithHTTPClient(srv.Client()))
+	expired, _ := json.Marshal(&oauth2.Token{
+		AccessToken:  "access-0",
+		TokenType:    "Bearer",
+		RefreshToken: "refresh-0",
+		Expiry:       time.Now().Add(-time.Hour),
+	})
+	if err := store.Put(context.Background(), "oauth/token/discord/octocat", expired, 0); err != nil {
+		t.Fatal(err)
+	}
+	reqCtx := context.WithValue(context.Background(), hostKey{}, strings.TrimPrefix(srv.URL, "http://"))
+
+	var wg sync.WaitGroup
+	for i := 0; i < 8; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			ctx, cancel := context.WithCancel(context.Background())
+			client, err := m.Client(ctx, "octocat")
+			// the request context of Client is done before the token is refreshed
+			cancel()
+			if err != nil {
+				t.Error(err)
+				return
+			}
+			if account, err := identify(reqCtx, client); err != nil || account != "octocat" {
+				t.Errorf("authorized request = %q, %v", account, err)
+			}
+		}()
+	}
+	wg.Wait()
+	if n := p.refreshes.Load(); n != 1 {
+		t.Errorf("refreshed %d times", n)
+	}
+	token, err := m.Token(context.Background(), "octocat")
+	if err != nil || token.RefreshToken != "refresh-1" {
+		t.Errorf("stored token = %+v, %v", token, err)
+	}
+}
@@ -4,21 +4,30 @@
 	"context"
 	"errors"
 	"fmt"
+	nethttp "net/http"
 	"os"
+	"strconv"
+	"strings"
 	"sync"
 	"time"
 
 	porter "github.com/tuihub/protos/pkg/librarian/porter/v1"
 	sephirah "github.com/tuihub/protos/pkg/librarian/sephirah/v1"
 	librarian "github.com/tuihub/protos/pkg/librarian/v1"
+	"github.com/tuihub/tuihub-go/httpclient"
 	"github.com/tuihub/tuihub-go/internal"
+	"github.com/tuihub/tuihub-go/kv"
+	"github.com/tuihub/tuihub-go/logger"
+	"github.com/tuihub/tuihub-go/oauth"
+	"github.com/tuihub/tuihub-go/secrets"
 
 	"github.com/go-kratos/kratos/v2"
 	"github.com/go-kratos/kratos/v2/log"
+	"github.com/go-kratos/kratos/v2/middleware"
+	"github.com/go-kratos/kratos/v2/transport"
 	"github.com/go-kratos/kratos/v2/transport/grpc"
+	"github.com/go-kratos/kratos/v2/transport/http"
 	capi "github.com/hashicorp/consul/api"
-	"google.golang.org/protobuf/proto"
-	"google.golang.org/protobuf/reflect/protoreflect"
 )
 
 const (
@@ -26,6 +35,13 @@
 	serverNetwork       = "SERVER_NETWORK"
 	serverAddr          = "SERVER_ADDRESS"
 	serverTimeout       = "SERVER_TIMEOUT"
+	serverHTTPAddr      = "SERVER_HTTP_ADDRESS"
+	serverLogRequest    = "SERVER_LOG_REQUEST"
+	serverLogResponse   = "SERVER_LOG_RESPONSE"
+	serverLogRedactKeys = "SERVER_LOG_REDACT_KEYS"
+	serverAdminToken    = "SERVER_ADMIN_TOKEN"
+	serverHTTPGateway   = "SERVER_HTTP_GATEWAY"
+	serverReplyPolicy   = "SERVER_REPLY_POLICY"
 	consulAddr          = "CONSUL_ADDRESS"
 	consulToken         = "CONSUL_TOKEN"
 	sephirahServiceName = "SEPHIRAH_SERVICE_NAME"
@@ -33,25 +49,164 @@
 
 type Porter struct {
 	server        *grpc.Server
+	httpServer    *http.Server
 	requireAsUser bool
 	wrapper       *serviceWrapper
 	logger        log.Logger
+	globalLogger  bool
 	app           *kratos.App
 	consulConfig  *capi.Config
 	serverConfig  *ServerConfig
+	errorReporter ErrorReporter
+	middlewares   []middleware.Middleware
+	grpcOptions   []grpc.ServerOption
+	httpConfig    *httpclient.Config
+	httpClient    *nethttp.Client
+	scheduler     *scheduler
+	kvConfig      *kv.Config
+	kvStore       kv.Store
+	kvOwned       bool
+	secrets       []secrets.Provider
+	secretOptions []secrets.Option
+	consulSecrets *string
 }
 
 type ServerConfig struct {
 	Network string
 	Addr    string
 	Timeout *time.Duration
+	// HTTPAddr enables the HTTP server for admin endpoints when not empty.
+	HTTPAddr string
+	// AdminToken is the bearer token required by admin endpoints such as the log level endpoint.
+	// Admin endpoints are not served when it is empty.
+	AdminToken string
+	// HTTPGateway serves porter RPCs as JSON endpoints on the HTTP server, see RegisterHTTPGateway.
+	HTTPGateway bool
+	// LogRequest logs request payloads with secrets redacted.
+	LogRequest bool
+	// LogResponse logs response payloads with secrets redacted.
+	LogResponse bool
+	// RedactConfigKeys are extra key names masked in logged `config_json` and `context_json`,
+	// in addition to redact.DefaultFieldNames.
+	RedactConfigKeys []string
+	// ReplyPolicy enables outbound checks of PullAppInfo, PullAccount and PullFeed replies. Defaults to off.
+	ReplyPolicy ReplyPolicy
+	// ErrorReporter is called with handler errors that map to Internal or Unknown.
+	ErrorReporter ErrorReporter
+	// Secrets resolves `secret://<name>` references in config_json and context_json of requests,
+	// for the names allowed by secrets.WithRefs.
+	Secrets *secrets.Store
+	// Middlewares run after the built-in logging, error and recovery middlewares.
+	// Use selector.Server to limit a middleware to some methods.
+	Middlewares []middleware.Middleware
+	// GRPCOptions are appended to the options of the gRPC server.
+	// Do not pass grpc.Middleware, it replaces the built-in middlewares.
+	GRPCOptions []grpc.ServerOption
 }
 
 type PorterOption func(*Porter)
 
-func WithLogger(logger log.Logger) PorterOption {
+// WithLogger sets the porter logger. Records below the level set by logger.SetLevel are dropped.
+func WithLogger(l log.Logger) PorterOption {
 	return func(p *Porter) {
-		p.logger = logger
+		p.logger = logger.NewLevelFilter(l)
+	}
+}
+
+// WithLogConfig creates the porter logger with the given level and format instead of reading them from env.
+func WithLogConfig(config logger.Config) PorterOption {
+	return func(p *Porter) {
+		p.logger = logger.New(os.Stderr, config)
+	}
+}
+
+// WithGlobalLogger also installs the porter logger as the global logger of log.GetLogger.
+func WithGlobalLogger() PorterOption {
+	return func(p *Porter) {
+		p.globalLogger = true
+	}
+}
+
+// WithErrorReporter sets ServerConfig.ErrorReporter.
+func WithErrorReporter(r ErrorReporter) PorterOption {
+	return func(p *Porter) {
+		p.errorReporter = r
+	}
+}
+
+// WithServerConfig uses c instead of reading the server config from env.
+func WithServerConfig(c *ServerConfig) PorterOption {
+	return func(p *Porter) {
+		config := *c
+		config.Middlewares = append([]middleware.Middleware(nil), c.Middlewares...)
+		config.GRPCOptions = append([]grpc.ServerOption(nil), c.GRPCOptions...)
+		p.serverConfig = &config
+	}
+}
+
+// WithMiddleware appends to ServerConfig.Middlewares. To audit only PushFeedItems:
+//
+//	tuihub.WithMiddleware(selector.Server(audit).
+//		Path(porter.LibrarianPorterService_PushFeedItems_FullMethodName).Build())
+func WithMiddleware(m ...middleware.Middleware) PorterOption {
+	return func(p *Porter) {
+		p.middlewares = append(p.middlewares, m...)
+	}
+}
+
+// WithGRPCServerOption appends to ServerConfig.GRPCOptions.
+func WithGRPCServerOption(opts ...grpc.ServerOption) PorterOption {
+	return func(p *Porter) {
+		p.grpcOptions = append(p.grpcOptions, opts...)
+	}
+}
+
+// WithHTTPClientConfig configures the client returned by Porter.HTTPClient instead of
+// httpclient.ConfigFromEnv. An empty UserAgent is derived from the binary summary.
+func WithHTTPClientConfig(c httpclient.Config) PorterOption {
+	return func(p *Porter) {
+		p.httpConfig = &c
+	}
+}
+
+// WithKVConfig opens the store returned by Porter.KV with c instead of kv.ConfigFromEnv.
+// File backends are opt-in, import kv/boltkv or kv/sqlitekv to link them.
+func WithKVConfig(c kv.Config) PorterOption {
+	return func(p *Porter) {
+		p.kvConfig = &c
+	}
+}
+
+// WithKVStore uses s as the store returned by Porter.KV. The caller closes s after Run returns.
+func WithKVStore(s kv.Store) PorterOption {
+	return func(p *Porter) {
+		p.kvStore = s
+	}
+}
+
+// WithSecrets reads secrets from provider, see Porter.Secrets. Use secrets.Chain to combine providers.
+// Secret values are masked in logs. Feature configs can reference the secrets of WithSecretRefs
+// as `secret://<name>`.
+func WithSecrets(provider secrets.Provider, options ...secrets.Option) PorterOption {
+	return func(p *Porter) {
+		p.secrets = append(p.secrets, provider)
+		p.secretOptions = append(p.secretOptions, options...)
+	}
+}
+
+// WithSecretRefs allows feature configs of requests to reference the secrets named names, references to
+// other secrets are rejected with ConfigInvalid.
+func WithSecretRefs(names ...string) PorterOption {
+	return func(p *Porter) {
+		p.secretOptions = append(p.secretOptions, secrets.WithRefs(names...))
+	}
+}
+
+// WithConsulSecrets reads secrets from Consul KV keys under prefix, after providers of WithSecrets,
+// with the client config of the porter.
+func WithConsulSecrets(prefix string) PorterOption {
+	return func(p *Porter) {
+		p.consulSecrets = &prefix
 	}
 }
 
@@ -67,8 +222,60 @@ func WithAsUser() PorterOption {
 	}
 }
 
+// HTTPClient returns the client handlers should use for upstream requests, see package httpclient.
+func (p *Porter) HTTPClient() *nethttp.Client {
+	return p.httpClient
+}
+
+// Schedule runs f on schedule from Run until Stop, only while the porter is enabled.
+// A run is skipped while the previous one has not returned.
+//
+//	schedule, err := tuihub.Cron("*/30 * * * *")
+//	err = p.Schedule("refresh-token", schedule, refresh, tuihub.WithJitter(time.Minute))
+func (p *Porter) Schedule(name string, schedule Schedule, f TaskFunc, options ...TaskOption) error {
+	return p.scheduler.add(name, schedule, f, options...)
+}
+
+// HandleOAuth serves the redirect callbacks of m on the HTTP server, which requires ServerConfig.HTTPAddr.
+func (p *Porter) HandleOAuth(m ...*oauth.Manager) error {
+	if p.httpServer == nil {
+		return errors.New("oauth callbacks require the HTTP server, set ServerConfig.HTTPAddr")
+	}
+	for _, manager := range m {
+		p.httpServer.Handle(manager.CallbackPath(), manager)
+	}
+	return nil
+}
+
+// Secrets returns the secrets of WithSecrets and WithConsulSecrets, nil without them.
+func (p *Porter) Secrets() *secrets.Store {
+	return p.serverConfig.Secrets
+}
+
+// Tasks returns the status of scheduled tasks.
+func (p *Porter) Tasks() []TaskStatus {
+	return p.scheduler.status()
+}
+
+// KV returns the store of the Sephirah that enabled the porter, keys of other Sephirah
+// instances are not visible. It fails while the porter is not enabled.
+func (p *Porter) KV() (kv.Store, error) {
+	p.wrapper.tokenMu.Lock()
+	defer p.wrapper.tokenMu.Unlock()
+	if p.wrapper.Token == nil {
+		return nil, errors.New("porter not enabled")
+	}
+	return kv.WithPrefix(p.kvStore, fmt.Sprintf("sephirah/%d/", p.wrapper.Token.enabler)), nil
+}
+
 func (p *Porter) Run() error {
-	return p.app.Run()
+	err := p.app.Run()
+	if p.kvOwned {
+		if closeErr := p.kvStore.Close(); err == nil {
+			err = closeErr
+		}
+	}
+	return err
 }
 
 func (p *Porter) Stop() error {
@@ -81,29 +288,51 @@ func NewPorter(
 	service porter.LibrarianPorterServiceServer,
 	options ...PorterOption,
 ) (*Porter, error) {
-	if service == nil {
-		return nil, errors.New("serviceServer is nil")
-	}
-	if info.GetBinarySummary() == nil {
-		return nil, errors.New("binary summary is nil")
-	}
-	if info.GetGlobalName() == "" {
-		return nil, errors.New("global name is empty")
-	}
-	if info.GetFeatureSummary() == nil {
-		return nil, errors.New("feature summary is nil")
+	if err := checkPorter(info, service); err != nil {
+		return nil, err
 	}
 	p := new(Porter)
-	p.logger = log.DefaultLogger
 	for _, o := range options {
 		o(p)
 	}
+	if p.logger == nil {
+		p.logger = logger.New(os.Stderr, logger.ConfigFromEnv())
+	}
 	if p.serverConfig == nil {
 		p.serverConfig = defaultServerConfig()
 	}
+	if p.errorReporter != nil {
+		p.serverConfig.ErrorReporter = p.errorReporter
+	}
+	p.serverConfig.Middlewares = append(p.serverConfig.Middlewares, p.middlewares...)
+	p.serverConfig.GRPCOptions = append(p.serverConfig.GRPCOptions, p.grpcOptions...)
+	if p.httpConfig == nil {
+		config := httpclient.ConfigFromEnv()
+		p.httpConfig = &config
+	}
+	if p.httpConfig.UserAgent == "" {
+		p.httpConfig.UserAgent = httpclient.UserAgent(info.GetBinarySummary())
+	}
+	p.httpClient = httpclient.New(*p.httpConfig)
 	if p.consulConfig == nil {
 		p.consulConfig = defaultConsulConfig()
 	}
+	if p.consulSecrets != nil {
+		consul, err := capi.NewClient(p.consulConfig)
+		if err != nil {
+			return nil, err
+		}
+		p.secrets = append(p.secrets, secrets.Consul(consul, *p.consulSecrets))
+	}
+	if len(p.secrets) > 0 {
+		p.serverConfig.Secrets = secrets.New(secrets.Chain(p.secrets...), p.secretOptions...)
+	}
+	if p.serverConfig.Secrets != nil {
+		p.logger = p.serverConfig.Secrets.RedactLogger(p.logger)
+	}
+	if p.globalLogger {
+		log.SetLogger(p.logger)
+	}
 	client, err := internal.NewSephirahClient(ctx, p.consulConfig, os.Getenv(sephirahServiceName))
 	if err != nil {
 		return nil, err
@@ -112,6 +341,18 @@ func NewPorter(
 	if err != nil {
 		return nil, err
 	}
+	if p.kvStore == nil {
+		if p.kvConfig == nil {
+			config := kv.ConfigFromEnv()
+			p.kvConfig = &config
+		}
+		store, err := kv.Open(*p.kvConfig)
+		if err != nil {
+			return nil, err
+		}
+		p.kvStore = store
+		p.kvOwned = true
+	}
 	c := &serviceWrapper{
 		LibrarianPorterServiceServer: service,
 		Info:                         info,
@@ -124,11 +365,27 @@ func NewPorter(
 		lastRefreshToken:             time.Time{},
 	}
 	p.wrapper = c
+	wrapped := NewService(c)
 	p.server = NewServer(
 		p.serverConfig,
-		NewService(c),
+		wrapped,
 		p.logger,
 	)
+	p.scheduler = newScheduler(p.logger, c.Enabled, p.AsUser)
+	servers := []transport.Server{p.server, p.scheduler}
+	if p.serverConfig.Secrets != nil {
+		servers = append(servers, p.serverConfig.Secrets)
+	}
+	if p.serverConfig.HTTPAddr != "" {
+		p.httpServer = NewHTTPServer(p.serverConfig)
+		if p.serverConfig.AdminToken != "" {
+			p.httpServer.Handle(adminTasksPath, requireBearerToken(p.serverConfig.AdminToken, p.scheduler))
+		}
+		if p.serverConfig.HTTPGateway {
+			RegisterHTTPGateway(p.httpServer, p.serverConfig, wrapped, p.logger)
+		}
+		servers = append(servers, p.httpServer)
+	}
 	id, _ := os.Hostname()
 	name := "porter"
 	id = fmt.Sprintf("%s-%s-%s", id, name, info.GetBinarySummary().GetName())
@@ -142,18 +399,45 @@ func NewPorter(
 		kratos.Metadata(map[string]string{
 			"PorterName": p.wrapper.Info.GetGlobalName(),
 		}),
-		kratos.Server(p.server),
+		kratos.Server(servers...),
 		kratos.Registrar(r),
 	)
 	p.app = app
 	return p, nil
 }
 
+func checkPorter(info *porter.GetPorterInformationResponse, service porter.LibrarianPorterServiceServer) error {
+	if service == nil {
+		return errors.New("serviceServer is nil")
+	}
+	if info.GetBinarySummary() == nil {
+		return errors.New("binary summary is nil")
+	}
+	if info.GetGlobalName() == "" {
+		return errors.New("global name is empty")
+	}
+	if info.GetFeatureSummary() == nil {
+		return errors.New("feature summary is nil")
+	}
+	return nil
+}
+
 func defaultServerConfig() *ServerConfig {
 	config := ServerConfig{
-		Network: "",
-		Addr:    "",
-		Timeout: nil,
+		Network:          "",
+		Addr:             "",
+		Timeout:          nil,
+		HTTPAddr:         "",
+		AdminToken:       "",
+		HTTPGateway:      false,
+		LogRequest:       true,
+		LogResponse:      false,
+		RedactConfigKeys: nil,
+		ReplyPolicy:      ReplyPolicyOff,
+		ErrorReporter:    nil,
+		Secrets:          nil,
+		Middlewares:      nil,
+		GRPCOptions:      nil,
 	}
 	if network, exist := os.LookupEnv(serverNetwork); exist {
 		config.Network = network
@@ -167,6 +451,36 @@ func defaultServerConfig() *ServerConfig {
 			config.Timeout = &d
 		}
 	}
+	if addr, exist := os.LookupEnv(serverHTTPAddr); exist {
+		config.HTTPAddr = addr
+	}
+	if token, exist := os.LookupEnv(serverAdminToken); exist {
+		config.AdminToken = token
+	}
+	if v, exist := os.LookupEnv(serverHTTPGateway); exist {
+		if b, err := strconv.ParseBool(v); err == nil {
+			config.HTTPGateway = b
+		}
+	}
+	if v, exist := os.LookupEnv(serverLogRequest); exist {
+		if b, err := strconv.ParseBool(v); err == nil {
+			config.LogRequest = b
+		}
+	}
+	if v, exist := os.LookupEnv(serverLogResponse); exist {
+		if b, err := strconv.ParseBool(v); err == nil {
+			config.LogResponse = b
+		}
+	}
+	if v, exist := os.LookupEnv(serverReplyPolicy); exist {
+		switch policy := ReplyPolicy(strings.ToLower(v)); policy {
+		case ReplyPolicyOff, ReplyPolicyLog, ReplyPolicyReject:
+			config.ReplyPolicy = policy
+		}
+	}
+	if keys, exist := os.LookupEnv(serverLogRedactKeys); exist && keys != "" {
+		config.RedactConfigKeys = strings.Split(keys, ",")
+	}
 	return &config
 }
 
@@ -181,25 +495,12 @@ func defaultConsulConfig() *capi.Config {
 	return config
 }
 
-func WellKnownToString(e protoreflect.Enum) string {
-	return fmt.Sprint(proto.GetExtension(
-		e.
-			Descriptor().
-			Values().
-			ByNumber(
-				e.
-					Number(),
-			).
-			Options(),
-		librarian.E_ToString,
-	))
-}
-
 func (p *Porter) ReverseCall(ctx context.Context) (*LibrarianClient, error) {
 	if !p.requireAsUser {
 		return nil, errors.New("init porter with `WithAsUser` option to use this method")
 	}
-	if p.wrapper.Token == nil {
+	accessToken, enabled := p.wrapper.accessToken()
+	if !enabled {
 		return nil, errors.New("porter not enabled")
 	}
 	client, err := internal.NewSephirahClient(ctx, p.consulConfig, os.Getenv(sephirahServiceName))
@@ -208,7 +509,7 @@ func (p *Porter) ReverseCall(ctx context.Context) (*LibrarianClient, error) {
 	}
 	return &LibrarianClient{
 		LibrarianSephirahServiceClient: client,
-		accessToken:                    p.wrapper.Token.AccessToken,
+		accessToken:                    accessToken,
 		refreshToken:                   "",
 		muToken:                        sync.RWMutex{},
 		backgroundRefresh:              false,
@@ -220,7 +521,8 @@ func (p *Porter) AsUser(ctx context.Context, userID int64) (*LibrarianClient, er
 	if !p.requireAsUser {
 		return nil, errors.New("init porter with `WithAsUser` option to use this method")
 	}
-	if p.wrapper.Token == nil {
+	accessToken, enabled := p.wrapper.accessToken()
+	if !enabled {
 		return nil, errors.New("porter not enabled")
 	}
 	client, err := internal.NewSephirahClient(ctx, p.consulConfig, os.Getenv(sephirahServiceName))
@@ -228,7 +530,7 @@ func (p *Porter) AsUser(ctx context.Context, userID int64) (*LibrarianClient, er
 		return nil, err
 	}
 	resp, err := client.AcquireUserToken(
-		WithToken(ctx, p.wrapper.Token.AccessToken),
+		WithToken(ctx, accessToken),
 		&sephirah.AcquireUserTokenRequest{
 			UserId: &librarian.InternalID{Id: userID},
 		},
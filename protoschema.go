@@ -0,0 +1,276 @@
+package tuihub
+
+import (
+	"encoding/json"
+
+	"github.com/invopop/jsonschema"
+	"google.golang.org/protobuf/encoding/protojson"
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/reflect/protoreflect"
+)
+
+const (
+	int64Pattern    = `^-?[0-9]+$`
+	uint64Pattern   = `^[0-9]+$`
+	durationPattern = `^-?[0-9]+(\.[0-9]+)?s$`
+)
+
+// ReflectProtoJSONSchema builds a JSON schema for the protojson form of msg, to be used as
+// `FeatureFlag.config_json_schema` of porters whose configs are protobuf messages.
+// Properties use the lowerCamelCase JSON names of fields, and enums are rendered with their
+// `to_string` names when the enum declares them. Decode such configs with DecodeProtoConfig.
+func ReflectProtoJSONSchema(msg proto.Message) (string, error) {
+	s := newProtoSchemaBuilder().message(msg.ProtoReflect().Descriptor())
+	s.Version = jsonschema.Version
+	j, err := s.MarshalJSON()
+	if err != nil {
+		return "", err
+	}
+	return string(j), nil
+}
+
+func MustReflectProtoJSONSchema(msg proto.Message) string {
+	j, err := ReflectProtoJSONSchema(msg)
+	if err != nil {
+		panic(err)
+	}
+	return j
+}
+
+// DecodeProtoConfig validates a JSON config against ReflectProtoJSONSchema of msg and decodes it into msg.
+// An empty config is treated as `{}`.
+func DecodeProtoConfig(config string, msg proto.Message) error {
+	schema, err := ReflectProtoJSONSchema(msg)
+	if err != nil {
+		return err
+	}
+	if config == "" {
+		config = "{}"
+	}
+	if err = ValidateAgainstSchema(schema, config); err != nil {
+		return err
+	}
+	v, err := unmarshalJSON([]byte(config))
+	if err != nil {
+		return err
+	}
+	b, err := json.Marshal(toProtoEnumNames(msg.ProtoReflect().Descriptor(), v))
+	if err != nil {
+		return err
+	}
+	return protojson.Unmarshal(b, msg)
+}
+
+type protoSchemaBuilder struct {
+	visiting map[protoreflect.FullName]bool
+}
+
+func newProtoSchemaBuilder() *protoSchemaBuilder {
+	return &protoSchemaBuilder{visiting: make(map[protoreflect.FullName]bool)}
+}
+
+func (b *protoSchemaBuilder) message(md protoreflect.MessageDescriptor) *jsonschema.Schema {
+	if s := wellKnownTypeSchema(md); s != nil {
+		return s
+	}
+	if b.visiting[md.FullName()] {
+		// recursive message, leave the nested level open
+		return &jsonschema.Schema{Type: "object"}
+	}
+	b.visiting[md.FullName()] = true
+	defer delete(b.visiting, md.FullName())
+
+	s := &jsonschema.Schema{
+		Type:                 "object",
+		Properties:           jsonschema.NewProperties(),
+		AdditionalProperties: jsonschema.FalseSchema,
+	}
+	fields := md.Fields()
+	for i := 0; i < fields.Len(); i++ {
+		fd := fields.Get(i)
+		s.Properties.Set(fd.JSONName(), b.field(fd))
+	}
+	oneofs := md.Oneofs()
+	for i := 0; i < oneofs.Len(); i++ {
+		if od := oneofs.Get(i); !od.IsSynthetic() {
+			s.AllOf = append(s.AllOf, oneofSchema(od))
+		}
+	}
+	return s
+}
+
+// oneofSchema allows at most one field of the oneof to be set.
+func oneofSchema(od protoreflect.OneofDescriptor) *jsonschema.Schema {
+	fields := od.Fields()
+	each := make([]*jsonschema.Schema, 0, fields.Len())
+	for i := 0; i < fields.Len(); i++ {
+		each = append(each, &jsonschema.Schema{Required: []string{fields.Get(i).JSONName()}})
+	}
+	return &jsonschema.Schema{
+		OneOf: append([]*jsonschema.Schema{{Not: &jsonschema.Schema{AnyOf: each}}}, each...),
+	}
+}
+
+func (b *protoSchemaBuilder) field(fd protoreflect.FieldDescriptor) *jsonschema.Schema {
+	switch {
+	case fd.IsMap():
+		return &jsonschema.Schema{
+			Type:                 "object",
+			AdditionalProperties: b.singular(fd.MapValue()),
+		}
+	case fd.IsList():
+		return &jsonschema.Schema{
+			Type:  "array",
+			Items: b.singular(fd),
+		}
+	default:
+		return b.singular(fd)
+	}
+}
+
+func (b *protoSchemaBuilder) singular(fd protoreflect.FieldDescriptor) *jsonschema.Schema {
+	switch fd.Kind() {
+	case protoreflect.BoolKind:
+		return &jsonschema.Schema{Type: "boolean"}
+	case protoreflect.Int32Kind, protoreflect.Sint32Kind, protoreflect.Sfixed32Kind,
+		protoreflect.Uint32Kind, protoreflect.Fixed32Kind:
+		return &jsonschema.Schema{Type: "integer"}
+	case protoreflect.Int64Kind, protoreflect.Sint64Kind, protoreflect.Sfixed64Kind:
+		return integerOrString(int64Pattern)
+	case protoreflect.Uint64Kind, protoreflect.Fixed64Kind:
+		return integerOrString(uint64Pattern)
+	case protoreflect.FloatKind, protoreflect.DoubleKind:
+		return &jsonschema.Schema{Type: "number"}
+	case protoreflect.StringKind:
+		return &jsonschema.Schema{Type: "string"}
+	case protoreflect.BytesKind:
+		return &jsonschema.Schema{Type: "string", ContentEncoding: "base64"}
+	case protoreflect.EnumKind:
+		return enumSchema(fd.Enum())
+	case protoreflect.MessageKind, protoreflect.GroupKind:
+		return b.message(fd.Message())
+	default:
+		return &jsonschema.Schema{}
+	}
+}
+
+// integerOrString matches protojson, which writes 64-bit integers as strings but accepts both.
+func integerOrString(pattern string) *jsonschema.Schema {
+	return &jsonschema.Schema{
+		AnyOf: []*jsonschema.Schema{
+			{Type: "integer"},
+			{Type: "string", Pattern: pattern},
+		},
+	}
+}
+
+func enumSchema(ed protoreflect.EnumDescriptor) *jsonschema.Schema {
+	values := ed.Values()
+	enum := make([]interface{}, 0, values.Len())
+	toString := usesToString(ed)
+	for i := 0; i < values.Len(); i++ {
+		v := values.Get(i)
+		if toString {
+			if name := wellKnownValueToString(v); name != "" {
+				enum = append(enum, name)
+			}
+		} else {
+			enum = append(enum, string(v.Name()))
+		}
+	}
+	return &jsonschema.Schema{Type: "string", Enum: enum}
+}
+
+func usesToString(ed protoreflect.EnumDescriptor) bool {
+	values := ed.Values()
+	for i := 0; i < values.Len(); i++ {
+		if wellKnownValueToString(values.Get(i)) != "" {
+			return true
+		}
+	}
+	return false
+}
+
+func wellKnownTypeSchema(md protoreflect.MessageDescriptor) *jsonschema.Schema {
+	switch md.FullName() {
+	case "google.protobuf.Timestamp":
+		return &jsonschema.Schema{Type: "string", Format: "date-time"}
+	case "google.protobuf.Duration":
+		return &jsonschema.Schema{Type: "string", Pattern: durationPattern}
+	case "google.protobuf.FieldMask":
+		return &jsonschema.Schema{Type: "string"}
+	case "google.protobuf.Struct":
+		return &jsonschema.Schema{Type: "object"}
+	case "google.protobuf.ListValue":
+		return &jsonschema.Schema{Type: "array"}
+	case "google.protobuf.Value":
+		return jsonschema.TrueSchema
+	case "google.protobuf.Empty":
+		return &jsonschema.Schema{Type: "object", AdditionalProperties: jsonschema.FalseSchema}
+	case "google.protobuf.Any":
+		return &jsonschema.Schema{Type: "object", Required: []string{"@type"}}
+	case "google.protobuf.BoolValue", "google.protobuf.Int32Value", "google.protobuf.UInt32Value",
+		"google.protobuf.Int64Value", "google.protobuf.UInt64Value", "google.protobuf.FloatValue",
+		"google.protobuf.DoubleValue", "google.protobuf.StringValue", "google.protobuf.BytesValue":
+		// wrappers are written as their inner value
+		return newProtoSchemaBuilder().singular(md.Fields().ByName("value"))
+	default:
+		return nil
+	}
+}
+
+// toProtoEnumNames replaces `to_string` enum names in a decoded JSON config with enum value names
+// understood by protojson.
+func toProtoEnumNames(md protoreflect.MessageDescriptor, v interface{}) interface{} {
+	obj, ok := v.(map[string]interface{})
+	if !ok || wellKnownTypeSchema(md) != nil {
+		return v
+	}
+	for key, item := range obj {
+		fd := md.Fields().ByJSONName(key)
+		if fd == nil {
+			continue
+		}
+		convert := func(item interface{}) interface{} {
+			return toProtoFieldValue(fd, item)
+		}
+		switch {
+		case fd.IsMap():
+			if m, isMap := item.(map[string]interface{}); isMap {
+				for k, mv := range m {
+					m[k] = toProtoFieldValue(fd.MapValue(), mv)
+				}
+			}
+		case fd.IsList():
+			if list, isList := item.([]interface{}); isList {
+				for i, lv := range list {
+					list[i] = convert(lv)
+				}
+			}
+		default:
+			obj[key] = convert(item)
+		}
+	}
+	return obj
+}
+
+func toProtoFieldValue(fd protoreflect.FieldDescriptor, v interface{}) interface{} {
+	switch fd.Kind() { //nolint:exhaustive // only enums and messages need conversion
+	case protoreflect.EnumKind:
+		s, ok := v.(string)
+		if !ok || !usesToString(fd.Enum()) {
+			return v
+		}
+		values := fd.Enum().Values()
+		for i := 0; i < values.Len(); i++ {
+			if wellKnownValueToString(values.Get(i)) == s {
+				return string(values.Get(i).Name())
+			}
+		}
+		return v
+	case protoreflect.MessageKind, protoreflect.GroupKind:
+		return toProtoEnumNames(fd.Message(), v)
+	default:
+		return v
+	}
+}
@@ -0,0 +1,190 @@
+package tuihub
+
+import (
+	"encoding/json"
+	"testing"
+
+	librarian "github.com/tuihub/protos/pkg/librarian/v1"
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/reflect/protodesc"
+	"google.golang.org/protobuf/reflect/protoreflect"
+	"google.golang.org/protobuf/reflect/protoregistry"
+	"google.golang.org/protobuf/types/descriptorpb"
+	"google.golang.org/protobuf/types/dynamicpb"
+	_ "google.golang.org/protobuf/types/known/durationpb"
+)
+
+// testProtoConfig is a dynamic message equivalent to
+//
+//	message Config {
+//	  string name = 1;
+//	  int64 limit = 2;
+//	  librarian.v1.WellKnownAppInfoSource source = 3;
+//	  repeated librarian.v1.WellKnownAppInfoSource sources = 4;
+//	  map<string, int32> weights = 5;
+//	  google.protobuf.Duration interval = 6;
+//	  oneof auth {
+//	    string token = 7;
+//	    string cookie = 8;
+//	  }
+//	}
+func testProtoConfig(t *testing.T) protoreflect.MessageDescriptor {
+	t.Helper()
+	field := func(name string, number int32, typ descriptorpb.FieldDescriptorProto_Type,
+		label descriptorpb.FieldDescriptorProto_Label, typeName string) *descriptorpb.FieldDescriptorProto {
+		f := &descriptorpb.FieldDescriptorProto{
+			Name:   proto.String(name),
+			Number: proto.Int32(number),
+			Type:   typ.Enum(),
+			Label:  label.Enum(),
+		}
+		if typeName != "" {
+			f.TypeName = proto.String(typeName)
+		}
+		return f
+	}
+	const (
+		optional = descriptorpb.FieldDescriptorProto_LABEL_OPTIONAL
+		repeated = descriptorpb.FieldDescriptorProto_LABEL_REPEATED
+		source   = ".librarian.v1.WellKnownAppInfoSource"
+	)
+	token := field("token", 7, descriptorpb.FieldDescriptorProto_TYPE_STRING, optional, "")
+	token.OneofIndex = proto.Int32(0)
+	cookie := field("cookie", 8, descriptorpb.FieldDescriptorProto_TYPE_STRING, optional, "")
+	cookie.OneofIndex = proto.Int32(0)
+	fdp := &descriptorpb.FileDescriptorProto{
+		Name:    proto.String("tuihub_test/config.proto"),
+		Package: proto.String("tuihub_test"),
+		Syntax:  proto.String("proto3"),
+		Dependency: []string{
+			"librarian/v1/wellknown.proto",
+			"google/protobuf/duration.proto",
+		},
+		MessageType: []*descriptorpb.DescriptorProto{{
+			Name: proto.String("Config"),
+			Field: []*descriptorpb.FieldDescriptorProto{
+				field("name", 1, descriptorpb.FieldDescriptorProto_TYPE_STRING, optional, ""),
+				field("limit", 2, descriptorpb.FieldDescriptorProto_TYPE_INT64, optional, ""),
+				field("source", 3, descriptorpb.FieldDescriptorProto_TYPE_ENUM, optional, source),
+				field("sources", 4, descriptorpb.FieldDescriptorProto_TYPE_ENUM, repeated, source),
+				field("weights", 5, descriptorpb.FieldDescriptorProto_TYPE_MESSAGE, repeated,
+					".tuihub_test.Config.WeightsEntry"),
+				field("interval", 6, descriptorpb.FieldDescriptorProto_TYPE_MESSAGE, optional,
+					".google.protobuf.Duration"),
+				token,
+				cookie,
+			},
+			NestedType: []*descriptorpb.DescriptorProto{{
+				Name: proto.String("WeightsEntry"),
+				Field: []*descriptorpb.FieldDescriptorProto{
+					field("key", 1, descriptorpb.FieldDescriptorProto_TYPE_STRING, optional, ""),
+					field("value", 2, descriptorpb.FieldDescriptorProto_TYPE_INT32, optional, ""),
+				},
+				Options: &descriptorpb.MessageOptions{MapEntry: proto.Bool(true)},
+			}},
+			OneofDecl: []*descriptorpb.OneofDescriptorProto{{Name: proto.String("auth")}},
+		}},
+	}
+	fd, err := protodesc.NewFile(fdp, protoregistry.GlobalFiles)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return fd.Messages().ByName("Config")
+}
+
+func TestReflectProtoJSONSchema(t *testing.T) {
+	md := testProtoConfig(t)
+	schema, err := ReflectProtoJSONSchema(dynamicpb.NewMessage(md))
+	if err != nil {
+		t.Fatal(err)
+	}
+	var s struct {
+		Type                 string                     `json:"type"`
+		AdditionalProperties bool                       `json:"additionalProperties"`
+		Properties           map[string]json.RawMessage `json:"properties"`
+	}
+	if err = json.Unmarshal([]byte(schema), &s); err != nil {
+		t.Fatal(err)
+	}
+	if s.Type != "object" || s.AdditionalProperties {
+		t.Errorf("root = %s", schema)
+	}
+	for name, want := range map[string]string{
+		"name":     `{"type":"string"}`,
+		"source":   `{"type":"string","enum":["steam","vndb","bangumi"]}`,
+		"sources":  `{"items":{"type":"string","enum":["steam","vndb","bangumi"]},"type":"array"}`,
+		"weights":  `{"additionalProperties":{"type":"integer"},"type":"object"}`,
+		"interval": `{"type":"string","pattern":"^-?[0-9]+(\\.[0-9]+)?s$"}`,
+	} {
+		if got := string(s.Properties[name]); got != want {
+			t.Errorf("%s = %s, want %s", name, got, want)
+		}
+	}
+
+	valid := []string{
+		`{}`,
+		`{"name":"a","limit":"10","source":"steam","sources":["vndb"],"weights":{"a":1},"interval":"1.5s"}`,
+		`{"limit":10,"token":"t"}`,
+	}
+	for _, config := range valid {
+		if err = ValidateAgainstSchema(schema, config); err != nil {
+			t.Errorf("ValidateAgainstSchema(%s) error = %v", config, err)
+		}
+	}
+	invalid := []string{
+		`{"source":"WELL_KNOWN_APP_INFO_SOURCE_STEAM"}`,
+		`{"limit":"ten"}`,
+		`{"token":"t","cookie":"c"}`,
+		`{"unknown":1}`,
+		`{"weights":{"a":"b"}}`,
+	}
+	for _, config := range invalid {
+		if err = ValidateAgainstSchema(schema, config); err == nil {
+			t.Errorf("ValidateAgainstSchema(%s) succeeded", config)
+		}
+	}
+}
+
+func TestReflectProtoJSONSchemaGenerated(t *testing.T) {
+	schema := MustReflectProtoJSONSchema(new(librarian.FeatureFlag))
+	config := `{"id":"a","name":"b","configJsonSchema":"{}","extra":{"k":"v"}}`
+	if err := ValidateAgainstSchema(schema, config); err != nil {
+		t.Errorf("ValidateAgainstSchema error = %v", err)
+	}
+}
+
+func TestDecodeProtoConfig(t *testing.T) {
+	md := testProtoConfig(t)
+	msg := dynamicpb.NewMessage(md)
+	err := DecodeProtoConfig(`{"name":"a","source":"bangumi","sources":["steam","vndb"],"token":"t"}`, msg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	fields := md.Fields()
+	if got := msg.Get(fields.ByName("source")).Enum(); got !=
+		librarian.WellKnownAppInfoSource_WELL_KNOWN_APP_INFO_SOURCE_BANGUMI.Number() {
+		t.Errorf("source = %v", got)
+	}
+	if got := msg.Get(fields.ByName("sources")).List(); got.Len() != 2 ||
+		got.Get(1).Enum() != librarian.WellKnownAppInfoSource_WELL_KNOWN_APP_INFO_SOURCE_VNDB.Number() {
+		t.Errorf("sources = %v", got)
+	}
+	if got := msg.Get(fields.ByName("token")).String(); got != "t" {
+		t.Errorf("token = %q", got)
+	}
+
+	if err = DecodeProtoConfig("", dynamicpb.NewMessage(md)); err != nil {
+		t.Errorf("DecodeProtoConfig empty error = %v", err)
+	}
+	if err = DecodeProtoConfig(`{"source":"rss"}`, dynamicpb.NewMessage(md)); err == nil {
+		t.Error("DecodeProtoConfig invalid enum succeeded")
+	}
+
+	msg = dynamicpb.NewMessage(md)
+	if err = DecodeProtoConfig(`{"limit":9007199254740993}`, msg); err != nil {
+		t.Fatal(err)
+	}
+	if got := msg.Get(fields.ByName("limit")).Int(); got != 9007199254740993 {
+		t.Errorf("limit = %d, want 9007199254740993", got)
+	}
+}
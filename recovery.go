@@ -0,0 +1,67 @@
+package tuihub
+
+import (
+	"context"
+	"fmt"
+	"runtime/debug"
+
+	"github.com/tuihub/tuihub-go/errors"
+	tuihublogger "github.com/tuihub/tuihub-go/logger"
+
+	"github.com/go-kratos/kratos/v2/log"
+	"github.com/go-kratos/kratos/v2/middleware"
+	"google.golang.org/grpc/codes"
+)
+
+// ErrorReporter receives handler errors that map to Internal or Unknown, including recovered panics.
+// Use it to forward porter bugs to an error tracker.
+type ErrorReporter func(ctx context.Context, err error)
+
+// serverRecovery converts a panic in the handler to an Internal error and logs it with the stack trace.
+func serverRecovery(logger log.Logger) middleware.Middleware {
+	return func(handler middleware.Handler) middleware.Handler {
+		return func(ctx context.Context, req interface{}) (reply interface{}, err error) {
+			defer func() {
+				if rerr := recover(); rerr != nil {
+					keyvals := []interface{}{
+						log.DefaultMessageKey, "panic recovered",
+					}
+					keyvals = append(keyvals, tuihublogger.ContextFields(ctx)...)
+					keyvals = append(keyvals,
+						"panic", fmt.Sprint(rerr),
+						"stack", string(debug.Stack()),
+					)
+					_ = log.WithContext(ctx, logger).Log(log.LevelError, keyvals...)
+					err = errors.Internal("internal error").WithCause(fmt.Errorf("panic: %v", rerr))
+					reply = nil
+				}
+			}()
+			return handler(ctx, req)
+		}
+	}
+}
+
+// serverErrors turns errors returned by the handler into errors with proper gRPC statuses.
+// Plain errors become Internal, and errors caused by the request deadline become DeadlineExceeded.
+func serverErrors(reporter ErrorReporter) middleware.Middleware {
+	return func(handler middleware.Handler) middleware.Handler {
+		return func(ctx context.Context, req interface{}) (interface{}, error) {
+			reply, err := handler(ctx, req)
+			if err == nil {
+				return reply, nil
+			}
+			se := errors.FromError(err)
+			code := se.GRPCStatus().Code()
+			if code == codes.Internal || code == codes.Unknown {
+				if ctxErr := ctx.Err(); ctxErr != nil {
+					// the handler wrapped the expired context in a plain error
+					return nil, errors.FromError(ctxErr).WithCause(err)
+				}
+				if reporter != nil {
+					reporter(ctx, err)
+				}
+			}
+			return nil, se
+		}
+	}
+}
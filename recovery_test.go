@@ -0,0 +1,72 @@
+package tuihub
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/tuihub/tuihub-go/errors"
+
+	"github.com/go-kratos/kratos/v2/log"
+	"google.golang.org/grpc/codes"
+)
+
+type recordLogger struct {
+	keyvals []interface{}
+}
+
+func (l *recordLogger) Log(_ log.Level, keyvals ...interface{}) error {
+	l.keyvals = keyvals
+	return nil
+}
+
+func TestServerRecovery(t *testing.T) {
+	l := new(recordLogger)
+	h := serverRecovery(l)(func(context.Context, interface{}) (interface{}, error) {
+		panic("boom")
+	})
+	reply, err := h(context.Background(), nil)
+	if reply != nil || errors.Code(err) != codes.Internal {
+		t.Errorf("reply = %v, err = %v", reply, err)
+	}
+	logged := fmt.Sprint(l.keyvals...)
+	if !strings.Contains(logged, "boom") || !strings.Contains(logged, "TestServerRecovery") {
+		t.Errorf("panic not logged with stack: %s", logged)
+	}
+}
+
+func TestServerErrors(t *testing.T) {
+	expired, cancel := context.WithDeadline(context.Background(), time.Now())
+	defer cancel()
+	tests := []struct {
+		name     string
+		ctx      context.Context
+		err      error
+		want     codes.Code
+		reported bool
+	}{
+		{"plain", context.Background(), fmt.Errorf("oops"), codes.Internal, true},
+		{"typed", context.Background(), errors.NotFound("no feed"), codes.NotFound, false},
+		{"wrapped deadline", context.Background(), fmt.Errorf("get: %w", context.DeadlineExceeded),
+			codes.DeadlineExceeded, false},
+		{"expired context", expired, fmt.Errorf("read: connection reset"), codes.DeadlineExceeded, false},
+		{"internal", context.Background(), errors.Internal("bug"), codes.Internal, true},
+	}
+	for _, tt := range tests {
+		var reported error
+		h := serverErrors(func(_ context.Context, err error) {
+			reported = err
+		})(func(context.Context, interface{}) (interface{}, error) {
+			return nil, tt.err
+		})
+		_, err := h(tt.ctx, nil)
+		if got := errors.FromError(err).GRPCStatus().Code(); got != tt.want {
+			t.Errorf("%s: code = %v, want %v", tt.name, got, tt.want)
+		}
+		if (reported != nil) != tt.reported {
+			t.Errorf("%s: reported = %v, want %v", tt.name, reported, tt.reported)
+		}
+	}
+}
@@ -0,0 +1,208 @@
+// Package redact masks secrets in protobuf messages before they are logged.
+package redact
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+
+	"google.golang.org/protobuf/encoding/protojson"
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/reflect/protoreflect"
+	"google.golang.org/protobuf/types/descriptorpb"
+)
+
+const Mask = "***"
+
+// DefaultFieldNames are name parts masked regardless of message type.
+// Names are compared case-insensitively with `_` and `-` removed, and match when
+// the field name contains them, so "token" covers `refresh_token` and `botToken`.
+var DefaultFieldNames = []string{ //nolint:gochecknoglobals // default list
+	"token",
+	"password",
+	"passwd",
+	"secret",
+	"api_key",
+	"private_key",
+	"credential",
+}
+
+// configSuffix marks string fields carrying JSON configs, like `config_json` and `context_json`.
+const configSuffix = "_json"
+
+type Redactor struct {
+	fieldNames []string
+	configKeys []string
+}
+
+type Option func(*Redactor)
+
+// WithFieldNames masks proto fields containing the given names in addition to DefaultFieldNames.
+func WithFieldNames(names ...string) Option {
+	return func(r *Redactor) {
+		for _, name := range names {
+			r.fieldNames = append(r.fieldNames, normalize(name))
+		}
+	}
+}
+
+// WithConfigKeys masks keys containing the given names at any depth of JSON config fields.
+// DefaultFieldNames are always masked in JSON configs too.
+func WithConfigKeys(keys ...string) Option {
+	return func(r *Redactor) {
+		for _, key := range keys {
+			r.configKeys = append(r.configKeys, normalize(key))
+		}
+	}
+}
+
+func New(options ...Option) *Redactor {
+	r := &Redactor{
+		fieldNames: nil,
+		configKeys: nil,
+	}
+	for _, name := range DefaultFieldNames {
+		r.fieldNames = append(r.fieldNames, normalize(name))
+		r.configKeys = append(r.configKeys, normalize(name))
+	}
+	for _, o := range options {
+		o(r)
+	}
+	return r
+}
+
+// Message returns a copy of m with sensitive fields masked. m is not modified.
+// A field is sensitive if it is marked with the `debug_redact` option or its name contains a configured name.
+func (r *Redactor) Message(m proto.Message) proto.Message {
+	if m == nil {
+		return nil
+	}
+	c := proto.Clone(m)
+	r.redactMessage(c.ProtoReflect())
+	return c
+}
+
+// String renders v as JSON after redaction, for use as a log value.
+func (r *Redactor) String(v interface{}) string {
+	m, ok := v.(proto.Message)
+	if !ok {
+		return fmt.Sprintf("%T", v)
+	}
+	b, err := protojson.Marshal(r.Message(m))
+	if err != nil {
+		return fmt.Sprintf("%T", v)
+	}
+	return string(b)
+}
+
+// JSON masks configured keys in a JSON document. Invalid JSON is masked as a whole.
+func (r *Redactor) JSON(s string) string {
+	if s == "" {
+		return s
+	}
+	var data interface{}
+	if err := json.Unmarshal([]byte(s), &data); err != nil {
+		return Mask
+	}
+	b, err := json.Marshal(r.redactJSON(data))
+	if err != nil {
+		return Mask
+	}
+	return string(b)
+}
+
+func (r *Redactor) redactMessage(m protoreflect.Message) {
+	m.Range(func(fd protoreflect.FieldDescriptor, v protoreflect.Value) bool {
+		switch {
+		case r.isSensitive(fd):
+			r.maskField(m, fd, v)
+		case fd.IsMap():
+			if fd.MapValue().Kind() == protoreflect.MessageKind {
+				v.Map().Range(func(_ protoreflect.MapKey, mv protoreflect.Value) bool {
+					r.redactMessage(mv.Message())
+					return true
+				})
+			}
+		case fd.IsList():
+			if fd.Kind() == protoreflect.MessageKind || fd.Kind() == protoreflect.GroupKind {
+				for i := 0; i < v.List().Len(); i++ {
+					r.redactMessage(v.List().Get(i).Message())
+				}
+			}
+		case fd.Kind() == protoreflect.MessageKind || fd.Kind() == protoreflect.GroupKind:
+			r.redactMessage(v.Message())
+		case fd.Kind() == protoreflect.StringKind && strings.HasSuffix(string(fd.Name()), configSuffix):
+			m.Set(fd, protoreflect.ValueOfString(r.JSON(v.String())))
+		}
+		return true
+	})
+}
+
+func (r *Redactor) isSensitive(fd protoreflect.FieldDescriptor) bool {
+	if opts, ok := fd.Options().(*descriptorpb.FieldOptions); ok && opts.GetDebugRedact() {
+		return true
+	}
+	if !maskable(fd) {
+		return false
+	}
+	return matches(r.fieldNames, string(fd.Name()))
+}
+
+// maskable excludes scalars like `need_refresh_token` that cannot carry secrets.
+func maskable(fd protoreflect.FieldDescriptor) bool {
+	switch fd.Kind() { //nolint:exhaustive // other kinds are scalars
+	case protoreflect.StringKind, protoreflect.BytesKind, protoreflect.MessageKind, protoreflect.GroupKind:
+		return true
+	default:
+		return false
+	}
+}
+
+func normalize(name string) string {
+	return strings.NewReplacer("_", "", "-", "").Replace(strings.ToLower(name))
+}
+
+func matches(names []string, name string) bool {
+	name = normalize(name)
+	for _, n := range names {
+		if n != "" && strings.Contains(name, n) {
+			return true
+		}
+	}
+	return false
+}
+
+func (r *Redactor) maskField(m protoreflect.Message, fd protoreflect.FieldDescriptor, v protoreflect.Value) {
+	switch {
+	case fd.IsList() && fd.Kind() == protoreflect.StringKind:
+		for i := 0; i < v.List().Len(); i++ {
+			v.List().Set(i, protoreflect.ValueOfString(Mask))
+		}
+	case fd.IsList() || fd.IsMap():
+		m.Clear(fd)
+	case fd.Kind() == protoreflect.StringKind:
+		m.Set(fd, protoreflect.ValueOfString(Mask))
+	case fd.Kind() == protoreflect.BytesKind:
+		m.Set(fd, protoreflect.ValueOfBytes([]byte(Mask)))
+	default:
+		m.Clear(fd)
+	}
+}
+
+func (r *Redactor) redactJSON(v interface{}) interface{} {
+	switch val := v.(type) {
+	case map[string]interface{}:
+		for k, item := range val {
+			if matches(r.configKeys, k) {
+				val[k] = Mask
+			} else {
+				val[k] = r.redactJSON(item)
+			}
+		}
+	case []interface{}:
+		for i, item := range val {
+			val[i] = r.redactJSON(item)
+		}
+	}
+	return v
+}
@@ -0,0 +1,243 @@
+package redact_test
+
+import (
+	"encoding/json"
+	"strings"
+	"testing"
+
+	porter "github.com/tuihub/protos/pkg/librarian/porter/v1"
+	librarian "github.com/tuihub/protos/pkg/librarian/v1"
+	"github.com/tuihub/tuihub-go/redact"
+
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/reflect/protodesc"
+	"google.golang.org/protobuf/reflect/protoreflect"
+	"google.golang.org/protobuf/types/descriptorpb"
+	"google.golang.org/protobuf/types/dynamicpb"
+)
+
+func configOf(t *testing.T, r *redact.Redactor, m proto.Message, get func(proto.Message) string) map[string]interface{} {
+	t.Helper()
+	var config map[string]interface{}
+	if err := json.Unmarshal([]byte(get(r.Message(m))), &config); err != nil {
+		t.Fatal(err)
+	}
+	return config
+}
+
+func TestEnablePorterRequest(t *testing.T) {
+	token := "refresh-me"
+	req := &porter.EnablePorterRequest{SephirahId: 1, RefreshToken: &token}
+	got := redact.New().Message(req).(*porter.EnablePorterRequest) //nolint:errcheck // same type
+	if got.GetRefreshToken() != redact.Mask {
+		t.Errorf("refresh_token = %q", got.GetRefreshToken())
+	}
+	if got.GetSephirahId() != 1 {
+		t.Errorf("sephirah_id = %d", got.GetSephirahId())
+	}
+	if req.GetRefreshToken() != token {
+		t.Error("input modified")
+	}
+}
+
+func TestEnablePorterResponseKeepsScalars(t *testing.T) {
+	got := redact.New().Message(&porter.EnablePorterResponse{NeedRefreshToken: true}).(*porter.EnablePorterResponse) //nolint:errcheck,lll // same type
+	if !got.GetNeedRefreshToken() {
+		t.Error("need_refresh_token masked")
+	}
+}
+
+func TestFeatureRequestConfig(t *testing.T) {
+	config := `{
+		"url": "https://example.com/feed",
+		"bot_token": "123:abc",
+		"apiKey": "k1",
+		"steam-api-key": "k2",
+		"client_secret": "s",
+		"chat": {"id": 42, "password": "p"},
+		"targets": [{"webhook": "https://hook", "Signing_Secret": "x"}],
+		"custom": "c"
+	}`
+	tests := []struct {
+		name string
+		msg  proto.Message
+		get  func(proto.Message) string
+	}{
+		{
+			name: "PullFeedRequest",
+			msg:  &porter.PullFeedRequest{Source: &librarian.FeatureRequest{Id: "rss", ConfigJson: config}},
+			get: func(m proto.Message) string {
+				return m.(*porter.PullFeedRequest).GetSource().GetConfigJson() //nolint:errcheck // same type
+			},
+		},
+		{
+			name: "PushFeedItemsRequest",
+			msg: &porter.PushFeedItemsRequest{
+				Destination: &librarian.FeatureRequest{Id: "telegram", ConfigJson: config},
+				Items:       []*librarian.FeedItem{{Title: "t"}},
+			},
+			get: func(m proto.Message) string {
+				return m.(*porter.PushFeedItemsRequest).GetDestination().GetConfigJson() //nolint:errcheck // same type
+			},
+		},
+	}
+	r := redact.New(redact.WithConfigKeys("custom"))
+	for _, tt := range tests {
+		got := configOf(t, r, tt.msg, tt.get)
+		for _, key := range []string{"bot_token", "apiKey", "steam-api-key", "client_secret", "custom"} {
+			if got[key] != redact.Mask {
+				t.Errorf("%s: %s = %v", tt.name, key, got[key])
+			}
+		}
+		if got["url"] != "https://example.com/feed" {
+			t.Errorf("%s: url = %v", tt.name, got["url"])
+		}
+		chat, _ := got["chat"].(map[string]interface{})
+		if chat["password"] != redact.Mask || chat["id"] != float64(42) {
+			t.Errorf("%s: chat = %v", tt.name, chat)
+		}
+		targets, _ := got["targets"].([]interface{})
+		target, _ := targets[0].(map[string]interface{})
+		if target["Signing_Secret"] != redact.Mask || target["webhook"] != "https://hook" {
+			t.Errorf("%s: targets = %v", tt.name, targets)
+		}
+		if tt.get(tt.msg) != config {
+			t.Errorf("%s: input modified", tt.name)
+		}
+	}
+}
+
+func TestInvalidConfig(t *testing.T) {
+	req := &porter.PullFeedRequest{Source: &librarian.FeatureRequest{Id: "rss", ConfigJson: `{"token": "x"`}}
+	got := redact.New().Message(req).(*porter.PullFeedRequest) //nolint:errcheck // same type
+	if got.GetSource().GetConfigJson() != redact.Mask {
+		t.Errorf("config_json = %q", got.GetSource().GetConfigJson())
+	}
+	if s := redact.New().String(req); strings.Contains(s, `\"x\"`) {
+		t.Errorf("String leaked config: %s", s)
+	}
+}
+
+// newSecretMessage builds a dynamic message:
+//
+//	message Inner { string name = 1; string api_key = 2; }
+//	message Secret {
+//	  string pin = 1 [debug_redact = true];
+//	  repeated string tokens = 2;
+//	  Inner inner = 3;
+//	  repeated Inner list = 4;
+//	  map<string, Inner> by_name = 5;
+//	}
+func newSecretMessage(t *testing.T) protoreflect.MessageDescriptor {
+	t.Helper()
+	str := descriptorpb.FieldDescriptorProto_TYPE_STRING.Enum()
+	msg := descriptorpb.FieldDescriptorProto_TYPE_MESSAGE.Enum()
+	opt := descriptorpb.FieldDescriptorProto_LABEL_OPTIONAL.Enum()
+	rep := descriptorpb.FieldDescriptorProto_LABEL_REPEATED.Enum()
+	field := func(name string, number int32, label *descriptorpb.FieldDescriptorProto_Label,
+		typ *descriptorpb.FieldDescriptorProto_Type, typeName string) *descriptorpb.FieldDescriptorProto {
+		f := &descriptorpb.FieldDescriptorProto{
+			Name:     proto.String(name),
+			JsonName: proto.String(name),
+			Number:   proto.Int32(number),
+			Label:    label,
+			Type:     typ,
+		}
+		if typeName != "" {
+			f.TypeName = proto.String(typeName)
+		}
+		return f
+	}
+	pin := field("pin", 1, opt, str, "")
+	pin.Options = &descriptorpb.FieldOptions{DebugRedact: proto.Bool(true)}
+	fd, err := protodesc.NewFile(&descriptorpb.FileDescriptorProto{
+		Name:    proto.String("redact_test.proto"),
+		Package: proto.String("test"),
+		Syntax:  proto.String("proto3"),
+		MessageType: []*descriptorpb.DescriptorProto{
+			{
+				Name: proto.String("Inner"),
+				Field: []*descriptorpb.FieldDescriptorProto{
+					field("name", 1, opt, str, ""),
+					field("api_key", 2, opt, str, ""),
+				},
+			},
+			{
+				Name: proto.String("Secret"),
+				Field: []*descriptorpb.FieldDescriptorProto{
+					pin,
+					field("tokens", 2, rep, str, ""),
+					field("inner", 3, opt, msg, ".test.Inner"),
+					field("list", 4, rep, msg, ".test.Inner"),
+					field("by_name", 5, rep, msg, ".test.Secret.ByNameEntry"),
+				},
+				NestedType: []*descriptorpb.DescriptorProto{
+					{
+						Name: proto.String("ByNameEntry"),
+						Field: []*descriptorpb.FieldDescriptorProto{
+							field("key", 1, opt, str, ""),
+							field("value", 2, opt, msg, ".test.Inner"),
+						},
+						Options: &descriptorpb.MessageOptions{MapEntry: proto.Bool(true)},
+					},
+				},
+			},
+		},
+	}, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return fd.Messages().ByName("Secret")
+}
+
+func TestNestedAndListFields(t *testing.T) {
+	md := newSecretMessage(t)
+	innerMD := md.Fields().ByName("inner").Message()
+	newInner := func(name string) protoreflect.Message {
+		m := dynamicpb.NewMessage(innerMD)
+		m.Set(innerMD.Fields().ByName("name"), protoreflect.ValueOfString(name))
+		m.Set(innerMD.Fields().ByName("api_key"), protoreflect.ValueOfString("key-"+name))
+		return m
+	}
+	m := dynamicpb.NewMessage(md)
+	m.Set(md.Fields().ByName("pin"), protoreflect.ValueOfString("1234"))
+	tokens := m.Mutable(md.Fields().ByName("tokens")).List()
+	tokens.Append(protoreflect.ValueOfString("t1"))
+	tokens.Append(protoreflect.ValueOfString("t2"))
+	m.Set(md.Fields().ByName("inner"), protoreflect.ValueOfMessage(newInner("a")))
+	list := m.Mutable(md.Fields().ByName("list")).List()
+	list.Append(protoreflect.ValueOfMessage(newInner("b")))
+	byName := m.Mutable(md.Fields().ByName("by_name")).Map()
+	byName.Set(protoreflect.ValueOfString("c").MapKey(), protoreflect.ValueOfMessage(newInner("c")))
+
+	got := redact.New().Message(m).ProtoReflect()
+
+	if v := got.Get(md.Fields().ByName("pin")).String(); v != redact.Mask {
+		t.Errorf("debug_redact pin = %q", v)
+	}
+	gotTokens := got.Get(md.Fields().ByName("tokens")).List()
+	for i := 0; i < gotTokens.Len(); i++ {
+		if v := gotTokens.Get(i).String(); v != redact.Mask {
+			t.Errorf("tokens[%d] = %q", i, v)
+		}
+	}
+	checkInner := func(where string, inner protoreflect.Message, name string) {
+		if v := inner.Get(innerMD.Fields().ByName("api_key")).String(); v != redact.Mask {
+			t.Errorf("%s.api_key = %q", where, v)
+		}
+		if v := inner.Get(innerMD.Fields().ByName("name")).String(); v != name {
+			t.Errorf("%s.name = %q", where, v)
+		}
+	}
+	checkInner("inner", got.Get(md.Fields().ByName("inner")).Message(), "a")
+	checkInner("list[0]", got.Get(md.Fields().ByName("list")).List().Get(0).Message(), "b")
+	checkInner("by_name[c]", got.Get(md.Fields().ByName("by_name")).Map().
+		Get(protoreflect.ValueOfString("c").MapKey()).Message(), "c")
+
+	if v := m.Get(md.Fields().ByName("pin")).String(); v != "1234" {
+		t.Errorf("input modified: pin = %q", v)
+	}
+	if v := m.Get(md.Fields().ByName("inner")).Message().Get(innerMD.Fields().ByName("api_key")).String(); v != "key-a" {
+		t.Errorf("input modified: inner.api_key = %q", v)
+	}
+}
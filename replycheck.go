@@ -0,0 +1,188 @@
+package tuihub
+
+import (
+	"context"
+	"fmt"
+	"net/url"
+	"strings"
+
+	pb "github.com/tuihub/protos/pkg/librarian/porter/v1"
+	librarian "github.com/tuihub/protos/pkg/librarian/v1"
+	"github.com/tuihub/tuihub-go/errors"
+	tuihublogger "github.com/tuihub/tuihub-go/logger"
+
+	"github.com/go-kratos/kratos/v2/log"
+	"github.com/go-kratos/kratos/v2/middleware"
+	"github.com/microcosm-cc/bluemonday"
+	"google.golang.org/protobuf/proto"
+)
+
+// ReplyPolicy decides what happens to handler replies that fail the outbound checks.
+type ReplyPolicy string
+
+const (
+	// ReplyPolicyOff skips outbound checks and sanitization.
+	ReplyPolicyOff ReplyPolicy = "off"
+	// ReplyPolicyLog sanitizes replies and logs violations, the reply is still returned.
+	ReplyPolicyLog ReplyPolicy = "log"
+	// ReplyPolicyReject sanitizes replies and fails requests with violations with Internal.
+	ReplyPolicyReject ReplyPolicy = "reject"
+)
+
+const (
+	maxFeedItems          = 1000
+	maxFeedItemContentLen = 1 << 20
+	maxFeedTextLen        = 64 << 10
+)
+
+type replyViolation struct {
+	field   string
+	message string
+}
+
+// serverReplyCheck verifies PullAppInfo, PullAccount and PullFeed replies match the request, keep
+// within size limits and carry valid URLs, and strips disallowed HTML from feed item content.
+func serverReplyCheck(logger log.Logger, policy ReplyPolicy) middleware.Middleware {
+	sanitizer := bluemonday.UGCPolicy()
+	return func(handler middleware.Handler) middleware.Handler {
+		if policy == "" || policy == ReplyPolicyOff {
+			return handler
+		}
+		return func(ctx context.Context, req interface{}) (interface{}, error) {
+			reply, err := handler(ctx, req)
+			if err != nil {
+				return reply, err
+			}
+			if feed, ok := reply.(*pb.PullFeedResponse); ok {
+				// handlers may cache and return the same reply again, sanitize a copy
+				reply = proto.Clone(feed)
+			}
+			violations := checkReply(req, reply, sanitizer)
+			if len(violations) == 0 {
+				return reply, nil
+			}
+			md := make(map[string]string, len(violations))
+			details := make([]string, 0, len(violations))
+			for _, v := range violations {
+				md[v.field] = v.message
+				details = append(details, fmt.Sprintf("%s: %s", v.field, v.message))
+			}
+			if policy == ReplyPolicyReject {
+				return nil, errors.Internal("invalid reply: %s", strings.Join(details, "; ")).WithMetadata(md)
+			}
+			keyvals := []interface{}{log.DefaultMessageKey, "invalid reply"}
+			keyvals = append(keyvals, tuihublogger.ContextFields(ctx)...)
+			keyvals = append(keyvals, "violations", details)
+			_ = log.WithContext(ctx, logger).Log(log.LevelWarn, keyvals...)
+			return reply, nil
+		}
+	}
+}
+
+func checkReply(req, reply interface{}, sanitizer *bluemonday.Policy) []replyViolation {
+	switch r := reply.(type) {
+	case *pb.PullAppInfoResponse:
+		in, _ := req.(*pb.PullAppInfoRequest)
+		return checkAppInfo(in.GetAppInfoId(), r.GetAppInfo())
+	case *pb.PullAccountResponse:
+		in, _ := req.(*pb.PullAccountRequest)
+		return checkAccount(in.GetAccountId(), r.GetAccount())
+	case *pb.PullFeedResponse:
+		return checkFeed(r.GetData(), sanitizer)
+	default:
+		return nil
+	}
+}
+
+func checkAppInfo(id *librarian.AppInfoID, info *librarian.AppInfo) []replyViolation {
+	if info == nil {
+		return []replyViolation{{"app_info", "value is required"}}
+	}
+	var vs []replyViolation
+	if info.GetInternal() {
+		vs = append(vs, replyViolation{"app_info.internal", "must be false"})
+	}
+	if info.GetSource() != id.GetSource() {
+		vs = append(vs, replyViolation{"app_info.source", fmt.Sprintf("must be requested source %q", id.GetSource())})
+	}
+	if info.GetSourceAppId() != id.GetSourceAppId() {
+		vs = append(vs, replyViolation{"app_info.source_app_id",
+			fmt.Sprintf("must be requested id %q", id.GetSourceAppId())})
+	}
+	vs = appendURLViolation(vs, "app_info.icon_image_url", info.GetIconImageUrl())
+	vs = appendURLViolation(vs, "app_info.background_image_url", info.GetBackgroundImageUrl())
+	vs = appendURLViolation(vs, "app_info.cover_image_url", info.GetCoverImageUrl())
+	return vs
+}
+
+func checkAccount(id *librarian.AccountID, account *librarian.Account) []replyViolation {
+	if account == nil {
+		return []replyViolation{{"account", "value is required"}}
+	}
+	var vs []replyViolation
+	if account.GetPlatform() != id.GetPlatform() {
+		vs = append(vs, replyViolation{"account.platform",
+			fmt.Sprintf("must be requested platform %q", id.GetPlatform())})
+	}
+	if account.GetPlatformAccountId() != id.GetPlatformAccountId() {
+		vs = append(vs, replyViolation{"account.platform_account_id",
+			fmt.Sprintf("must be requested id %q", id.GetPlatformAccountId())})
+	}
+	vs = appendURLViolation(vs, "account.profile_url", account.GetProfileUrl())
+	vs = appendURLViolation(vs, "account.avatar_url", account.GetAvatarUrl())
+	return vs
+}
+
+// checkFeed sanitizes item content and description in place.
+func checkFeed(feed *librarian.Feed, sanitizer *bluemonday.Policy) []replyViolation {
+	if feed == nil {
+		return []replyViolation{{"data", "value is required"}}
+	}
+	var vs []replyViolation
+	vs = appendURLViolation(vs, "data.link", feed.GetLink())
+	vs = appendURLViolation(vs, "data.image.url", feed.GetImage().GetUrl())
+	if len(feed.GetItems()) > maxFeedItems {
+		vs = append(vs, replyViolation{"data.items", fmt.Sprintf("must contain at most %d items", maxFeedItems)})
+	}
+	for i, item := range feed.GetItems() {
+		field := fmt.Sprintf("data.items[%d]", i)
+		if item == nil {
+			vs = append(vs, replyViolation{field, "value is required"})
+			continue
+		}
+		if item.GetGuid() == "" && item.GetLink() == "" {
+			vs = append(vs, replyViolation{field, "must have guid or link"})
+		}
+		if len(item.GetTitle()) > maxFeedTextLen {
+			vs = append(vs, replyViolation{field + ".title", fmt.Sprintf("must be at most %d bytes", maxFeedTextLen)})
+		}
+		item.Description = sanitizer.Sanitize(item.GetDescription())
+		if len(item.GetDescription()) > maxFeedTextLen {
+			vs = append(vs, replyViolation{field + ".description",
+				fmt.Sprintf("must be at most %d bytes", maxFeedTextLen)})
+		}
+		item.Content = sanitizer.Sanitize(item.GetContent())
+		if len(item.GetContent()) > maxFeedItemContentLen {
+			vs = append(vs, replyViolation{field + ".content",
+				fmt.Sprintf("must be at most %d bytes", maxFeedItemContentLen)})
+		}
+		vs = appendURLViolation(vs, field+".link", item.GetLink())
+		vs = appendURLViolation(vs, field+".image.url", item.GetImage().GetUrl())
+		for j, enclosure := range item.GetEnclosures() {
+			vs = appendURLViolation(vs, fmt.Sprintf("%s.enclosures[%d].url", field, j), enclosure.GetUrl())
+		}
+	}
+	return vs
+}
+
+// appendURLViolation requires a non-empty s to be an absolute http or https URL.
+func appendURLViolation(vs []replyViolation, field, s string) []replyViolation {
+	if s == "" {
+		return vs
+	}
+	u, err := url.Parse(s)
+	if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+		return append(vs, replyViolation{field, "must be an absolute http or https URL"})
+	}
+	return vs
+}
@@ -0,0 +1,106 @@
+package tuihub
+
+import (
+	"context"
+	"strings"
+	"testing"
+
+	pb "github.com/tuihub/protos/pkg/librarian/porter/v1"
+	librarian "github.com/tuihub/protos/pkg/librarian/v1"
+	"github.com/tuihub/tuihub-go/errors"
+
+	"google.golang.org/grpc/codes"
+)
+
+func TestServerReplyCheck(t *testing.T) {
+	appReq := &pb.PullAppInfoRequest{AppInfoId: &librarian.AppInfoID{Source: "steam", SourceAppId: "1"}}
+	accountReq := &pb.PullAccountRequest{AccountId: &librarian.AccountID{Platform: "steam", PlatformAccountId: "1"}}
+	tests := []struct {
+		name   string
+		req    interface{}
+		reply  interface{}
+		fields []string
+	}{
+		{"app info", appReq, &pb.PullAppInfoResponse{AppInfo: &librarian.AppInfo{
+			Source: "steam", SourceAppId: "1", IconImageUrl: "https://example.com/a.png",
+		}}, nil},
+		{"wrong app info", appReq, &pb.PullAppInfoResponse{AppInfo: &librarian.AppInfo{
+			Source: "vndb", SourceAppId: "2", IconImageUrl: "javascript:alert(1)",
+		}}, []string{"app_info.source", "app_info.source_app_id", "app_info.icon_image_url"}},
+		{"missing app info", appReq, new(pb.PullAppInfoResponse), []string{"app_info"}},
+		{"account", accountReq, &pb.PullAccountResponse{Account: &librarian.Account{
+			Platform: "steam", PlatformAccountId: "1",
+		}}, nil},
+		{"wrong account", accountReq, &pb.PullAccountResponse{Account: &librarian.Account{
+			Platform: "steam", PlatformAccountId: "2", AvatarUrl: "/a.png",
+		}}, []string{"account.platform_account_id", "account.avatar_url"}},
+		{"feed", nil, &pb.PullFeedResponse{Data: &librarian.Feed{Items: []*librarian.FeedItem{
+			{Guid: "1", Link: "https://example.com/1"},
+		}}}, nil},
+		{"bad feed items", nil, &pb.PullFeedResponse{Data: &librarian.Feed{Items: []*librarian.FeedItem{
+			{Title: "no id"},
+			{Guid: "2", Link: "ftp://example.com", Content: strings.Repeat("a", maxFeedItemContentLen+1)},
+		}}}, []string{"data.items[0]", "data.items[1].link", "data.items[1].content"}},
+		{"too many feed items", nil, &pb.PullFeedResponse{Data: &librarian.Feed{
+			Items: make([]*librarian.FeedItem, maxFeedItems+1),
+		}}, []string{"data.items"}},
+	}
+	for _, tt := range tests {
+		h := serverReplyCheck(new(recordLogger), ReplyPolicyReject)(
+			func(context.Context, interface{}) (interface{}, error) {
+				return tt.reply, nil
+			},
+		)
+		_, err := h(context.Background(), tt.req)
+		if len(tt.fields) == 0 {
+			if err != nil {
+				t.Errorf("%s: err = %v", tt.name, err)
+			}
+			continue
+		}
+		if errors.Code(err) != codes.Internal {
+			t.Errorf("%s: err = %v, want Internal", tt.name, err)
+			continue
+		}
+		md := errors.FromError(err).GetMetadata()
+		for _, field := range tt.fields {
+			if _, ok := md[field]; !ok {
+				t.Errorf("%s: metadata = %v, want field %s", tt.name, md, field)
+			}
+		}
+	}
+}
+
+func TestServerReplyCheckPolicy(t *testing.T) {
+	reply := &pb.PullFeedResponse{Data: &librarian.Feed{Items: []*librarian.FeedItem{
+		{Guid: "1", Content: `<p onclick="x()">hi<script>alert(1)</script></p>`},
+		{Title: "no id"},
+	}}}
+	handler := func(context.Context, interface{}) (interface{}, error) {
+		return reply, nil
+	}
+
+	l := new(recordLogger)
+	got, err := serverReplyCheck(l, ReplyPolicyLog)(handler)(context.Background(), nil)
+	if err != nil || got == nil {
+		t.Fatalf("log policy: reply = %v, err = %v", got, err)
+	}
+	sanitized := got.(*pb.PullFeedResponse) //nolint:errcheck // test reply
+	if content := sanitized.GetData().GetItems()[0].GetContent(); content != "<p>hi</p>" {
+		t.Errorf("content = %q, want sanitized", content)
+	}
+	if content := reply.GetData().GetItems()[0].GetContent(); content == "<p>hi</p>" {
+		t.Error("handler reply sanitized in place")
+	}
+	if len(l.keyvals) == 0 {
+		t.Error("violations not logged")
+	}
+
+	reply.Data.Items[0].Content = "<script></script>"
+	if _, err = serverReplyCheck(l, ReplyPolicyOff)(handler)(context.Background(), nil); err != nil {
+		t.Errorf("off policy: err = %v", err)
+	}
+	if content := reply.GetData().GetItems()[0].GetContent(); content != "<script></script>" {
+		t.Errorf("off policy changed content to %q", content)
+	}
+}
@@ -0,0 +1,70 @@
+package tuihub
+
+import (
+	"context"
+	"sync"
+
+	"google.golang.org/grpc/stats"
+)
+
+type replySentKey struct{}
+
+type replySent struct {
+	mu    sync.Mutex
+	funcs []func(ctx context.Context)
+}
+
+// OnReplySent calls f once the reply of the current request has been sent, and not at all when the
+// request fails. Use it to commit state for replies that must reach Librarian, such as feedsync.Pull.
+// When ctx does not come from a porter server, f is called right away.
+func OnReplySent(ctx context.Context, f func(ctx context.Context)) {
+	r, ok := ctx.Value(replySentKey{}).(*replySent)
+	if !ok {
+		f(ctx)
+		return
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.funcs = append(r.funcs, f)
+}
+
+func withReplySent(ctx context.Context) (context.Context, *replySent) {
+	r := new(replySent)
+	return context.WithValue(ctx, replySentKey{}, r), r
+}
+
+// sent calls the registered functions with a context that outlives the request.
+func (r *replySent) sent(ctx context.Context) {
+	r.mu.Lock()
+	funcs := r.funcs
+	r.funcs = nil
+	r.mu.Unlock()
+	ctx = context.WithoutCancel(ctx)
+	for _, f := range funcs {
+		f(ctx)
+	}
+}
+
+// replySentHandler runs OnReplySent functions of gRPC calls that ended with an OK status.
+type replySentHandler struct{}
+
+func (replySentHandler) TagRPC(ctx context.Context, _ *stats.RPCTagInfo) context.Context {
+	ctx, _ = withReplySent(ctx)
+	return ctx
+}
+
+func (replySentHandler) HandleRPC(ctx context.Context, s stats.RPCStats) {
+	end, ok := s.(*stats.End)
+	if !ok || end.Error != nil {
+		return
+	}
+	if r, found := ctx.Value(replySentKey{}).(*replySent); found {
+		r.sent(ctx)
+	}
+}
+
+func (replySentHandler) TagConn(ctx context.Context, _ *stats.ConnTagInfo) context.Context {
+	return ctx
+}
+
+func (replySentHandler) HandleConn(context.Context, stats.ConnStats) {}
@@ -0,0 +1,58 @@
+package tuihub
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	pb "github.com/tuihub/protos/pkg/librarian/porter/v1"
+	librarian "github.com/tuihub/protos/pkg/librarian/v1"
+
+	"github.com/go-kratos/kratos/v2/log"
+)
+
+type replySentHandlerTest struct {
+	testHandler
+	sent chan string
+}
+
+func (h replySentHandlerTest) PullFeed(ctx context.Context, req *pb.PullFeedRequest) (*pb.PullFeedResponse, error) {
+	OnReplySent(ctx, func(context.Context) { h.sent <- req.GetSource().GetConfigJson() })
+	if req.GetSource().GetConfigJson() == "fail" {
+		return nil, context.DeadlineExceeded
+	}
+	return new(pb.PullFeedResponse), nil
+}
+
+func TestOnReplySent(t *testing.T) {
+	h := replySentHandlerTest{testHandler: testHandler{}, sent: make(chan string, 2)}
+	service := newTestService(true)
+	service.(*serviceServer).LibrarianPorterServiceServer = h //nolint:errcheck // test service
+	client := dialTestServer(t, NewServer(defaultServerConfig(), service, log.DefaultLogger))
+	ctx := context.Background()
+
+	if _, err := client.PullFeed(ctx, &pb.PullFeedRequest{
+		Source: &librarian.FeatureRequest{Id: "rss", ConfigJson: "fail"},
+	}); err == nil {
+		t.Fatal("expected error")
+	}
+	if _, err := client.PullFeed(ctx, &pb.PullFeedRequest{
+		Source: &librarian.FeatureRequest{Id: "rss", ConfigJson: "ok"},
+	}); err != nil {
+		t.Fatal(err)
+	}
+	select {
+	case got := <-h.sent:
+		if got != "ok" {
+			t.Errorf("called for %q", got)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("not called after the reply was sent")
+	}
+
+	called := false
+	OnReplySent(ctx, func(context.Context) { called = true })
+	if !called {
+		t.Error("not called outside of a server")
+	}
+}
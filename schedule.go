@@ -0,0 +1,325 @@
+package tuihub
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"math/rand"
+	nethttp "net/http"
+	"sort"
+	"sync"
+	"time"
+
+	tuihublogger "github.com/tuihub/tuihub-go/logger"
+
+	"github.com/go-kratos/kratos/v2/log"
+	"github.com/robfig/cron/v3"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/metric"
+)
+
+const adminTasksPath = "/admin/tasks"
+
+// Schedule returns the next time a task runs after t. It is satisfied by cron schedules.
+type Schedule interface {
+	Next(t time.Time) time.Time
+}
+
+type everySchedule time.Duration
+
+func (s everySchedule) Next(t time.Time) time.Time {
+	return t.Add(time.Duration(s))
+}
+
+// Every runs a task every d, starting d after the porter runs. d must be positive.
+func Every(d time.Duration) Schedule {
+	return everySchedule(d)
+}
+
+// Cron parses a standard five field cron spec, or a descriptor such as `@hourly` or `@every 5m`.
+func Cron(spec string) (Schedule, error) {
+	return cron.ParseStandard(spec)
+}
+
+// TaskFunc is the work of a scheduled task.
+type TaskFunc func(ctx context.Context) error
+
+type TaskOption func(*task)
+
+// WithJitter delays each run by a random duration up to d, to spread the load on upstreams.
+func WithJitter(d time.Duration) TaskOption {
+	return func(t *task) {
+		t.jitter = d
+	}
+}
+
+// WithTaskTimeout cancels the context of a run after d.
+func WithTaskTimeout(d time.Duration) TaskOption {
+	return func(t *task) {
+		t.timeout = d
+	}
+}
+
+// WithTaskAsUser acquires a client acting as userID before each run, see TaskClient.
+// It requires WithAsUser.
+func WithTaskAsUser(userID int64) TaskOption {
+	return func(t *task) {
+		t.asUser = &userID
+	}
+}
+
+type taskClientKey struct{}
+
+// TaskClient returns the client of a task scheduled with WithTaskAsUser.
+func TaskClient(ctx context.Context) (*LibrarianClient, bool) {
+	c, ok := ctx.Value(taskClientKey{}).(*LibrarianClient)
+	return c, ok
+}
+
+// TaskStatus is the state of a scheduled task, served as JSON on /admin/tasks.
+type TaskStatus struct {
+	Name      string    `json:"name"`
+	Running   bool      `json:"running"`
+	NextRun   time.Time `json:"next_run"`
+	LastStart time.Time `json:"last_start"`
+	LastEnd   time.Time `json:"last_end"`
+	LastError string    `json:"last_error,omitempty"`
+	Runs      int64     `json:"runs"`
+	Failures  int64     `json:"failures"`
+	// Skipped counts runs skipped because the porter was not enabled or the previous run had not finished.
+	Skipped int64 `json:"skipped"`
+}
+
+type task struct {
+	name     string
+	schedule Schedule
+	f        TaskFunc
+	jitter   time.Duration
+	timeout  time.Duration
+	asUser   *int64
+	status   TaskStatus
+}
+
+// scheduler runs tasks between Start and Stop of the porter app.
+type scheduler struct {
+	logger  log.Logger
+	enabled func() bool
+	asUser  func(ctx context.Context, userID int64) (*LibrarianClient, error)
+	now     func() time.Time
+
+	runs     metric.Int64Counter
+	duration metric.Float64Histogram
+
+	mu     sync.Mutex
+	tasks  map[string]*task
+	ctx    context.Context //nolint:containedctx // canceled by Stop
+	cancel context.CancelFunc
+	wg     sync.WaitGroup
+}
+
+func newScheduler(
+	logger log.Logger,
+	enabled func() bool,
+	asUser func(ctx context.Context, userID int64) (*LibrarianClient, error),
+) *scheduler {
+	meter := otel.GetMeterProvider().Meter("github.com/tuihub/tuihub-go")
+	runs, _ := meter.Int64Counter("tuihub.porter.task.runs",
+		metric.WithDescription("Runs of scheduled porter tasks by result."))
+	duration, _ := meter.Float64Histogram("tuihub.porter.task.duration",
+		metric.WithDescription("Duration of scheduled porter task runs."), metric.WithUnit("s"))
+	return &scheduler{
+		logger:   logger,
+		enabled:  enabled,
+		asUser:   asUser,
+		now:      time.Now,
+		runs:     runs,
+		duration: duration,
+		mu:       sync.Mutex{},
+		tasks:    make(map[string]*task),
+		ctx:      nil,
+		cancel:   nil,
+		wg:       sync.WaitGroup{},
+	}
+}
+
+func (s *scheduler) add(name string, schedule Schedule, f TaskFunc, options ...TaskOption) error {
+	if name == "" || schedule == nil || f == nil {
+		return fmt.Errorf("task name, schedule and func are required")
+	}
+	if d, ok := schedule.(everySchedule); ok && d <= 0 {
+		return fmt.Errorf("task %q: interval must be positive, got %s", name, time.Duration(d))
+	}
+	t := &task{
+		name:     name,
+		schedule: schedule,
+		f:        f,
+		jitter:   0,
+		timeout:  0,
+		asUser:   nil,
+		status:   TaskStatus{Name: name}, //nolint:exhaustruct // zero status
+	}
+	for _, o := range options {
+		o(t)
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if _, exist := s.tasks[name]; exist {
+		return fmt.Errorf("task %q already scheduled", name)
+	}
+	s.tasks[name] = t
+	if s.ctx != nil {
+		s.wg.Add(1)
+		go s.loop(s.ctx, t)
+	}
+	return nil
+}
+
+func (s *scheduler) Start(context.Context) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.ctx, s.cancel = context.WithCancel(context.Background())
+	for _, t := range s.tasks {
+		s.wg.Add(1)
+		go s.loop(s.ctx, t)
+	}
+	return nil
+}
+
+// Stop cancels running tasks and waits for them to return until ctx is done.
+func (s *scheduler) Stop(ctx context.Context) error {
+	s.mu.Lock()
+	if s.cancel != nil {
+		s.cancel()
+	}
+	s.mu.Unlock()
+	done := make(chan struct{})
+	go func() {
+		s.wg.Wait()
+		close(done)
+	}()
+	select {
+	case <-done:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+func (s *scheduler) loop(ctx context.Context, t *task) {
+	defer s.wg.Done()
+	for {
+		next := t.schedule.Next(s.now())
+		if next.IsZero() {
+			// cron specs such as `0 0 30 2 *` never match
+			_ = log.WithContext(ctx, s.logger).Log(log.LevelError,
+				"msg", "task stopped, its schedule has no next run", "task", t.name)
+			s.mu.Lock()
+			t.status.NextRun = time.Time{}
+			t.status.LastError = "schedule has no next run"
+			s.mu.Unlock()
+			return
+		}
+		if t.jitter > 0 {
+			next = next.Add(time.Duration(rand.Int63n(int64(t.jitter)))) //nolint:gosec // jitter
+		}
+		s.mu.Lock()
+		t.status.NextRun = next
+		s.mu.Unlock()
+		timer := time.NewTimer(time.Until(next))
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return
+		case <-timer.C:
+		}
+		s.mu.Lock()
+		skip := t.status.Running || !s.enabled()
+		if skip {
+			t.status.Skipped++
+		} else {
+			t.status.Running = true
+			t.status.LastStart = s.now()
+		}
+		s.mu.Unlock()
+		if skip {
+			s.runs.Add(ctx, 1, metric.WithAttributes(taskAttrs(t.name, "skipped")...))
+			continue
+		}
+		s.wg.Add(1)
+		go s.run(ctx, t)
+	}
+}
+
+func (s *scheduler) run(ctx context.Context, t *task) {
+	defer s.wg.Done()
+	ctx = tuihublogger.NewContext(ctx, "task", t.name)
+	if t.timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, t.timeout)
+		defer cancel()
+	}
+	start := s.now()
+	err := s.call(ctx, t)
+
+	s.mu.Lock()
+	t.status.Running = false
+	t.status.LastEnd = s.now()
+	t.status.Runs++
+	t.status.LastError = ""
+	result := "success"
+	if err != nil {
+		t.status.Failures++
+		t.status.LastError = err.Error()
+		result = "failure"
+	}
+	s.mu.Unlock()
+
+	s.runs.Add(ctx, 1, metric.WithAttributes(taskAttrs(t.name, result)...))
+	s.duration.Record(ctx, time.Since(start).Seconds(), metric.WithAttributes(taskAttrs(t.name, result)...))
+	if err != nil {
+		_ = log.WithContext(ctx, s.logger).Log(log.LevelError,
+			append(tuihublogger.ContextFields(ctx), "msg", "task failed", "error", err)...)
+	}
+}
+
+func (s *scheduler) call(ctx context.Context, t *task) (err error) {
+	defer func() {
+		if rerr := recover(); rerr != nil {
+			err = fmt.Errorf("task panic: %v", rerr)
+		}
+	}()
+	if t.asUser != nil {
+		client, clientErr := s.asUser(ctx, *t.asUser)
+		if clientErr != nil {
+			return fmt.Errorf("acquire user client: %w", clientErr)
+		}
+		ctx = context.WithValue(ctx, taskClientKey{}, client)
+	}
+	return t.f(ctx)
+}
+
+func (s *scheduler) status() []TaskStatus {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	statuses := make([]TaskStatus, 0, len(s.tasks))
+	for _, t := range s.tasks {
+		statuses = append(statuses, t.status)
+	}
+	sort.Slice(statuses, func(i, j int) bool {
+		return statuses[i].Name < statuses[j].Name
+	})
+	return statuses
+}
+
+func (s *scheduler) ServeHTTP(w nethttp.ResponseWriter, _ *nethttp.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	_ = json.NewEncoder(w).Encode(s.status())
+}
+
+func taskAttrs(name, result string) []attribute.KeyValue {
+	return []attribute.KeyValue{
+		attribute.String("task", name),
+		attribute.String("result", result),
+	}
+}
@@ -0,0 +1,150 @@
+package tuihub
+
+import (
+	"context"
+	"errors"
+	"net/http/httptest"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/go-kratos/kratos/v2/log"
+)
+
+func newTestScheduler(enabled *atomic.Bool) *scheduler {
+	return newScheduler(log.DefaultLogger, enabled.Load, nil)
+}
+
+func TestSchedulerRuns(t *testing.T) {
+	var enabled atomic.Bool
+	s := newTestScheduler(&enabled)
+	var runs, fails atomic.Int32
+	if err := s.add("count", Every(5*time.Millisecond), func(context.Context) error {
+		runs.Add(1)
+		return nil
+	}); err != nil {
+		t.Fatal(err)
+	}
+	if err := s.add("fail", Every(5*time.Millisecond), func(context.Context) error {
+		fails.Add(1)
+		return errors.New("upstream down")
+	}); err != nil {
+		t.Fatal(err)
+	}
+	if err := s.add("count", Every(time.Second), func(context.Context) error { return nil }); err == nil {
+		t.Error("duplicate task name accepted")
+	}
+
+	if err := s.Start(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	time.Sleep(30 * time.Millisecond)
+	if runs.Load() != 0 {
+		t.Errorf("ran %d times while not enabled", runs.Load())
+	}
+	enabled.Store(true)
+	time.Sleep(50 * time.Millisecond)
+	if err := s.Stop(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	stopped := runs.Load()
+	if stopped == 0 || fails.Load() == 0 {
+		t.Fatalf("runs = %d, fails = %d", stopped, fails.Load())
+	}
+	time.Sleep(20 * time.Millisecond)
+	if runs.Load() != stopped {
+		t.Error("task ran after Stop")
+	}
+
+	status := s.status()
+	if len(status) != 2 || status[0].Name != "count" || status[0].Skipped == 0 || status[0].Runs != int64(stopped) {
+		t.Errorf("count status = %+v", status[0])
+	}
+	if status[1].Failures == 0 || status[1].LastError != "upstream down" {
+		t.Errorf("fail status = %+v", status[1])
+	}
+
+	rec := httptest.NewRecorder()
+	s.ServeHTTP(rec, httptest.NewRequest("GET", adminTasksPath, nil))
+	if !strings.Contains(rec.Body.String(), `"name":"fail"`) {
+		t.Errorf("tasks endpoint = %s", rec.Body.String())
+	}
+}
+
+func TestSchedulerOverlap(t *testing.T) {
+	var enabled atomic.Bool
+	enabled.Store(true)
+	s := newTestScheduler(&enabled)
+	var running, maxRunning atomic.Int32
+	if err := s.add("slow", Every(2*time.Millisecond), func(ctx context.Context) error {
+		if n := running.Add(1); n > maxRunning.Load() {
+			maxRunning.Store(n)
+		}
+		defer running.Add(-1)
+		select {
+		case <-time.After(20 * time.Millisecond):
+		case <-ctx.Done():
+		}
+		panic("slow task bug")
+	}); err != nil {
+		t.Fatal(err)
+	}
+	_ = s.Start(context.Background())
+	time.Sleep(50 * time.Millisecond)
+	_ = s.Stop(context.Background())
+	if maxRunning.Load() != 1 {
+		t.Errorf("max concurrent runs = %d, want 1", maxRunning.Load())
+	}
+	status := s.status()[0]
+	if status.Skipped == 0 || !strings.Contains(status.LastError, "slow task bug") {
+		t.Errorf("status = %+v", status)
+	}
+}
+
+func TestCron(t *testing.T) {
+	schedule, err := Cron("0 * * * *")
+	if err != nil {
+		t.Fatal(err)
+	}
+	from := time.Date(2024, 1, 1, 10, 20, 0, 0, time.UTC)
+	if next := schedule.Next(from); !next.Equal(time.Date(2024, 1, 1, 11, 0, 0, 0, time.UTC)) {
+		t.Errorf("next = %v", next)
+	}
+	if _, err = Cron("every minute"); err == nil {
+		t.Error("invalid spec accepted")
+	}
+}
+
+func TestSchedulerInvalidSchedule(t *testing.T) {
+	var enabled atomic.Bool
+	enabled.Store(true)
+	s := newTestScheduler(&enabled)
+	noop := func(context.Context) error { return nil }
+	for _, d := range []time.Duration{0, -time.Second} {
+		if err := s.add("every", Every(d), noop); err == nil {
+			t.Errorf("Every(%s) accepted", d)
+		}
+	}
+
+	never, err := Cron("0 0 30 2 *")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err = s.add("never", never, noop); err != nil {
+		t.Fatal(err)
+	}
+	if err = s.Start(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+	// the loop returns by itself, so Stop does not wait on a spinning task
+	s.wg.Wait()
+	if err = s.Stop(ctx); err != nil {
+		t.Fatal(err)
+	}
+	if status := s.status()[0]; status.LastError == "" || !status.NextRun.IsZero() {
+		t.Errorf("status = %+v", status)
+	}
+}
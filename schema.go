@@ -0,0 +1,234 @@
+package tuihub
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"reflect"
+	"sort"
+	"strings"
+	"sync"
+
+	"github.com/invopop/jsonschema"
+	validator "github.com/santhosh-tekuri/jsonschema/v5"
+	"google.golang.org/protobuf/reflect/protoreflect"
+)
+
+const schemaResource = "schema.json"
+
+var protoEnumType = reflect.TypeOf((*protoreflect.Enum)(nil)).Elem()
+
+type wellKnownSchema interface {
+	jsonSchemaEnum() []string
+}
+
+// newReflector returns the reflector used by ReflectJSONSchema.
+// WellKnown fields are rendered as string enums of their `to_string` names,
+// raw protobuf enum fields as integer enums.
+func newReflector() *jsonschema.Reflector {
+	r := new(jsonschema.Reflector)
+	r.ExpandedStruct = true
+	r.DoNotReference = true
+	r.Mapper = func(t reflect.Type) *jsonschema.Schema {
+		if w, ok := reflect.Zero(t).Interface().(wellKnownSchema); ok {
+			names := w.jsonSchemaEnum()
+			enum := make([]interface{}, 0, len(names))
+			for _, name := range names {
+				enum = append(enum, name)
+			}
+			return &jsonschema.Schema{Type: "string", Enum: enum}
+		}
+		if t.Implements(protoEnumType) {
+			values := reflect.Zero(t).Interface().(protoreflect.Enum).Descriptor().Values() //nolint:errcheck // checked
+			enum := make([]interface{}, 0, values.Len())
+			for i := 0; i < values.Len(); i++ {
+				enum = append(enum, int32(values.Get(i).Number()))
+			}
+			return &jsonschema.Schema{Type: "integer", Enum: enum}
+		}
+		return nil
+	}
+	return r
+}
+
+// SchemaError is a single violation of a JSON schema.
+type SchemaError struct {
+	// Path is the JSON pointer of the offending value, e.g. "/targets/0/url". Empty for the root.
+	Path    string
+	Message string
+}
+
+func (e SchemaError) Error() string {
+	path := e.Path
+	if path == "" {
+		path = "/"
+	}
+	return fmt.Sprintf("%s: %s", path, e.Message)
+}
+
+// SchemaErrors lists all violations found by ValidateAgainstSchema.
+type SchemaErrors []SchemaError
+
+func (e SchemaErrors) Error() string {
+	msgs := make([]string, 0, len(e))
+	for _, err := range e {
+		msgs = append(msgs, err.Error())
+	}
+	return strings.Join(msgs, "; ")
+}
+
+// ValidateAgainstSchema validates a JSON document against a JSON schema, such as one produced
+// by ReflectJSONSchema. Violations are returned as SchemaErrors.
+func ValidateAgainstSchema(schema string, data string) error {
+	s, err := compileSchema(schema)
+	if err != nil {
+		return err
+	}
+	v, err := unmarshalJSON([]byte(data))
+	if err != nil {
+		return SchemaErrors{{Path: "", Message: err.Error()}}
+	}
+	return validate(s, v)
+}
+
+// unmarshalJSON decodes a JSON document with numbers kept as json.Number,
+// so 64-bit ids are not rounded to float64.
+func unmarshalJSON(data []byte) (interface{}, error) {
+	dec := json.NewDecoder(bytes.NewReader(data))
+	dec.UseNumber()
+	var v interface{}
+	if err := dec.Decode(&v); err != nil {
+		return nil, err
+	}
+	if _, err := dec.Token(); !errors.Is(err, io.EOF) {
+		return nil, errors.New("invalid character after top-level value")
+	}
+	return v, nil
+}
+
+// DecodeConfig decodes a JSON config into T. Missing properties are filled from `default`
+// struct tags, then the result is validated against the schema of T before decoding.
+// An empty config is treated as `{}`.
+func DecodeConfig[T any](config string) (*T, error) {
+	t := new(T)
+	c, err := cachedConfigSchema(t)
+	if err != nil {
+		return nil, err
+	}
+	if config == "" {
+		config = "{}"
+	}
+	v, err := unmarshalJSON([]byte(config))
+	if err != nil {
+		return nil, SchemaErrors{{Path: "", Message: err.Error()}}
+	}
+	// defaults come from struct tags as Go values, round trip them to JSON types
+	b, err := json.Marshal(applyDefaults(c.schema, v))
+	if err != nil {
+		return nil, err
+	}
+	if v, err = unmarshalJSON(b); err != nil {
+		return nil, err
+	}
+	if err = validate(c.compiled, v); err != nil {
+		return nil, err
+	}
+	if err = json.Unmarshal(b, t); err != nil {
+		return nil, err
+	}
+	return t, nil
+}
+
+type configSchema struct {
+	schema   *jsonschema.Schema
+	compiled *validator.Schema
+}
+
+var configSchemas sync.Map // reflect.Type -> *configSchema
+
+func cachedConfigSchema(v interface{}) (*configSchema, error) {
+	t := reflect.TypeOf(v)
+	if c, ok := configSchemas.Load(t); ok {
+		return c.(*configSchema), nil //nolint:errcheck // only *configSchema is stored
+	}
+	s := newReflector().Reflect(v)
+	b, err := s.MarshalJSON()
+	if err != nil {
+		return nil, err
+	}
+	compiled, err := compileSchema(string(b))
+	if err != nil {
+		return nil, err
+	}
+	c := &configSchema{schema: s, compiled: compiled}
+	configSchemas.Store(t, c)
+	return c, nil
+}
+
+func compileSchema(schema string) (*validator.Schema, error) {
+	c := validator.NewCompiler()
+	if err := c.AddResource(schemaResource, strings.NewReader(schema)); err != nil {
+		return nil, fmt.Errorf("invalid schema: %w", err)
+	}
+	s, err := c.Compile(schemaResource)
+	if err != nil {
+		return nil, fmt.Errorf("invalid schema: %w", err)
+	}
+	return s, nil
+}
+
+func validate(s *validator.Schema, v interface{}) error {
+	err := s.Validate(v)
+	if err == nil {
+		return nil
+	}
+	var ve *validator.ValidationError
+	if !errors.As(err, &ve) {
+		return err
+	}
+	var res SchemaErrors
+	collectSchemaErrors(ve, &res)
+	sort.SliceStable(res, func(i, j int) bool { return res[i].Path < res[j].Path })
+	return res
+}
+
+// collectSchemaErrors keeps the leaf causes, which carry the most specific message.
+func collectSchemaErrors(ve *validator.ValidationError, res *SchemaErrors) {
+	if len(ve.Causes) == 0 {
+		*res = append(*res, SchemaError{Path: ve.InstanceLocation, Message: ve.Message})
+		return
+	}
+	for _, cause := range ve.Causes {
+		collectSchemaErrors(cause, res)
+	}
+}
+
+// applyDefaults fills missing object properties from schema defaults, recursively.
+func applyDefaults(s *jsonschema.Schema, v interface{}) interface{} {
+	if s == nil {
+		return v
+	}
+	switch val := v.(type) {
+	case map[string]interface{}:
+		if s.Properties == nil {
+			return v
+		}
+		for pair := s.Properties.Oldest(); pair != nil; pair = pair.Next() {
+			item, ok := val[pair.Key]
+			if !ok {
+				if pair.Value.Default == nil {
+					continue
+				}
+				item = pair.Value.Default
+			}
+			val[pair.Key] = applyDefaults(pair.Value, item)
+		}
+	case []interface{}:
+		for i, item := range val {
+			val[i] = applyDefaults(s.Items, item)
+		}
+	}
+	return v
+}
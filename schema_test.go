@@ -0,0 +1,117 @@
+package tuihub
+
+import (
+	"encoding/json"
+	"errors"
+	"strings"
+	"testing"
+
+	librarian "github.com/tuihub/protos/pkg/librarian/v1"
+)
+
+type testTarget struct {
+	URL string `json:"url" jsonschema:"format=uri"`
+}
+
+type testConfig struct {
+	Name    string                                   `json:"name" jsonschema:"minLength=1"`
+	Limit   int                                      `json:"limit,omitempty" jsonschema:"default=20,minimum=1"`
+	Mode    string                                   `json:"mode,omitempty" jsonschema:"default=fast,enum=fast,enum=slow"`
+	Source  WellKnown[librarian.WellKnownFeedSource] `json:"source,omitempty"`
+	Targets []testTarget                             `json:"targets,omitempty"`
+	ChatID  int64                                    `json:"chat_id,omitempty"`
+}
+
+func TestReflectJSONSchemaWellKnown(t *testing.T) {
+	s, err := ReflectJSONSchema(new(testConfig))
+	if err != nil {
+		t.Fatal(err)
+	}
+	var schema struct {
+		Properties map[string]struct {
+			Type string        `json:"type"`
+			Enum []interface{} `json:"enum"`
+		} `json:"properties"`
+	}
+	if err = json.Unmarshal([]byte(s), &schema); err != nil {
+		t.Fatal(err)
+	}
+	source := schema.Properties["source"]
+	if source.Type != "string" || len(source.Enum) != 1 || source.Enum[0] != "rss" {
+		t.Errorf("source schema = %+v", source)
+	}
+}
+
+func TestDecodeConfig(t *testing.T) {
+	c, err := DecodeConfig[testConfig](`{"name":"a","source":"rss","targets":[{"url":"https://example.com"}]}`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if c.Limit != 20 || c.Mode != "fast" {
+		t.Errorf("defaults not applied: %+v", c)
+	}
+	if c.Source.Value != librarian.WellKnownFeedSource_WELL_KNOWN_FEED_SOURCE_RSS {
+		t.Errorf("source = %v", c.Source.Value)
+	}
+	c, err = DecodeConfig[testConfig](`{"name":"a","limit":5}`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if c.Limit != 5 {
+		t.Errorf("limit = %d, want 5", c.Limit)
+	}
+	c, err = DecodeConfig[testConfig](`{"name":"a","chat_id":-1001234567890123456}`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if c.ChatID != -1001234567890123456 {
+		t.Errorf("chat_id = %d, want -1001234567890123456", c.ChatID)
+	}
+}
+
+func TestDecodeConfigErrors(t *testing.T) {
+	tests := []struct {
+		name     string
+		config   string
+		wantPath string
+	}{
+		{"missing required", `{}`, ""},
+		{"too small", `{"name":"a","limit":0}`, "/limit"},
+		{"bad enum", `{"name":"a","mode":"medium"}`, "/mode"},
+		{"unknown well-known", `{"name":"a","source":"atom"}`, "/source"},
+		{"nested", `{"name":"a","targets":[{"url":"ok"},{"url":1}]}`, "/targets/1/url"},
+		{"invalid json", `{"name":`, ""},
+		{"trailing data", `{"name":"a"} {}`, ""},
+	}
+	for _, tt := range tests {
+		_, err := DecodeConfig[testConfig](tt.config)
+		var errs SchemaErrors
+		if !errors.As(err, &errs) {
+			t.Errorf("%s: err = %v, want SchemaErrors", tt.name, err)
+			continue
+		}
+		found := false
+		for _, e := range errs {
+			if e.Path == tt.wantPath {
+				found = true
+			}
+		}
+		if !found {
+			t.Errorf("%s: no error at %q in %v", tt.name, tt.wantPath, errs)
+		}
+	}
+}
+
+func TestValidateAgainstSchema(t *testing.T) {
+	schema := MustReflectJSONSchema(new(testConfig))
+	if err := ValidateAgainstSchema(schema, `{"name":"a"}`); err != nil {
+		t.Errorf("valid config: %v", err)
+	}
+	err := ValidateAgainstSchema(schema, `{"name":""}`)
+	if err == nil || !strings.Contains(err.Error(), "/name") {
+		t.Errorf("err = %v, want error at /name", err)
+	}
+	if err = ValidateAgainstSchema(`{"type":`, `{}`); err == nil {
+		t.Error("expected error for invalid schema")
+	}
+}
@@ -0,0 +1,80 @@
+package tuihub
+
+import (
+	"context"
+	stderrors "errors"
+	"strings"
+
+	"github.com/tuihub/tuihub-go/errors"
+	"github.com/tuihub/tuihub-go/secrets"
+
+	"github.com/go-kratos/kratos/v2/middleware"
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/reflect/protoreflect"
+)
+
+// configFieldSuffix marks string fields carrying JSON configs, like `config_json` and `context_json`.
+const configFieldSuffix = "_json"
+
+var errHasRef = stderrors.New("config references a secret")
+
+// serverSecrets replaces secret references in JSON configs of requests with their values.
+// It runs after serverLogging, so logged requests keep the references.
+func serverSecrets(store *secrets.Store) middleware.Middleware {
+	return func(handler middleware.Handler) middleware.Handler {
+		return func(ctx context.Context, req interface{}) (interface{}, error) {
+			m, ok := req.(proto.Message)
+			if !ok {
+				return handler(ctx, req)
+			}
+			found := walkConfigs(m.ProtoReflect(), func(protoreflect.Message, protoreflect.FieldDescriptor, string) error {
+				return errHasRef
+			})
+			if found == nil {
+				return handler(ctx, req)
+			}
+			resolved := proto.Clone(m)
+			err := walkConfigs(resolved.ProtoReflect(),
+				func(m protoreflect.Message, fd protoreflect.FieldDescriptor, config string) error {
+					v, err := store.Resolve(ctx, config)
+					if err != nil {
+						return errors.ConfigInvalid("%s: %v", fd.Name(), err)
+					}
+					m.Set(fd, protoreflect.ValueOfString(v))
+					return nil
+				})
+			if err != nil {
+				return nil, err
+			}
+			return handler(ctx, resolved)
+		}
+	}
+}
+
+// walkConfigs calls f with every JSON config field of m referencing a secret, stopping at the first error.
+func walkConfigs(
+	m protoreflect.Message,
+	f func(m protoreflect.Message, fd protoreflect.FieldDescriptor, config string) error,
+) error {
+	var err error
+	m.Range(func(fd protoreflect.FieldDescriptor, v protoreflect.Value) bool {
+		isMessage := fd.Kind() == protoreflect.MessageKind || fd.Kind() == protoreflect.GroupKind
+		switch {
+		case fd.IsMap():
+		case fd.IsList():
+			if isMessage {
+				for i := 0; i < v.List().Len() && err == nil; i++ {
+					err = walkConfigs(v.List().Get(i).Message(), f)
+				}
+			}
+		case isMessage:
+			err = walkConfigs(v.Message(), f)
+		case fd.Kind() == protoreflect.StringKind && strings.HasSuffix(string(fd.Name()), configFieldSuffix):
+			if strings.Contains(v.String(), secrets.RefPrefix) {
+				err = f(m, fd, v.String())
+			}
+		}
+		return err == nil
+	})
+	return err
+}
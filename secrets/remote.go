@@ -0,0 +1,100 @@
+package secrets
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"strings"
+
+	capi "github.com/hashicorp/consul/api"
+)
+
+const (
+	defaultVaultMount = "secret"
+	defaultVaultKey   = "value"
+	maxErrorBody      = 512
+)
+
+// Consul reads secret `steam_api_key` from the Consul KV key `<prefix>steam_api_key`.
+func Consul(client *capi.Client, prefix string) Provider {
+	return ProviderFunc(func(ctx context.Context, name string) (string, error) {
+		pair, _, err := client.KV().Get(prefix+name, new(capi.QueryOptions).WithContext(ctx))
+		if err != nil {
+			return "", err
+		}
+		if pair == nil {
+			return "", ErrNotFound
+		}
+		return string(pair.Value), nil
+	})
+}
+
+// VaultConfig configures a Vault KV version 2 secrets engine.
+type VaultConfig struct {
+	// Address and Token default to VAULT_ADDR and VAULT_TOKEN.
+	Address string
+	Token   string
+	// Mount is the mount path of the engine, defaults to `secret`.
+	Mount      string
+	HTTPClient *http.Client
+}
+
+// Vault reads secret `steam#api_key` from key `api_key` of the Vault secret at path `steam`.
+// The key defaults to `value`.
+func Vault(c VaultConfig) Provider {
+	if c.Address == "" {
+		c.Address = os.Getenv("VAULT_ADDR")
+	}
+	if c.Token == "" {
+		c.Token = os.Getenv("VAULT_TOKEN")
+	}
+	if c.Mount == "" {
+		c.Mount = defaultVaultMount
+	}
+	if c.HTTPClient == nil {
+		c.HTTPClient = http.DefaultClient
+	}
+	return ProviderFunc(func(ctx context.Context, name string) (string, error) {
+		path, key, found := strings.Cut(name, "#")
+		if !found {
+			key = defaultVaultKey
+		}
+		u := strings.TrimRight(c.Address, "/") + "/v1/" + c.Mount + "/data/" + strings.TrimLeft(path, "/")
+		req, err := http.NewRequestWithContext(ctx, http.MethodGet, u, nil)
+		if err != nil {
+			return "", err
+		}
+		req.Header.Set("X-Vault-Token", c.Token)
+		resp, err := c.HTTPClient.Do(req)
+		if err != nil {
+			return "", err
+		}
+		defer resp.Body.Close()
+		if resp.StatusCode == http.StatusNotFound {
+			return "", ErrNotFound
+		}
+		if resp.StatusCode != http.StatusOK {
+			b, _ := io.ReadAll(io.LimitReader(resp.Body, maxErrorBody))
+			return "", fmt.Errorf("vault returned %s: %s", resp.Status, strings.TrimSpace(string(b)))
+		}
+		var body struct {
+			Data struct {
+				Data map[string]interface{} `json:"data"`
+			} `json:"data"`
+		}
+		if err = json.NewDecoder(resp.Body).Decode(&body); err != nil {
+			return "", err
+		}
+		v, ok := body.Data.Data[key]
+		if !ok {
+			return "", ErrNotFound
+		}
+		if s, isString := v.(string); isString {
+			return s, nil
+		}
+		return fmt.Sprint(v), nil
+	})
+}
@@ -0,0 +1,336 @@
+// Package secrets reads upstream credentials such as API keys and bot tokens from env, files,
+// Consul KV or Vault, and keeps them out of feature configs and logs.
+//
+// Feature configs reference secrets instead of carrying them, the porter replaces references
+// before handlers see the config:
+//
+//	{"api_key": "secret://steam_api_key"}
+//
+// Configs come from callers, so only the names allowed by WithRefs can be referenced.
+package secrets
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/tuihub/tuihub-go/redact"
+
+	"github.com/go-kratos/kratos/v2/log"
+)
+
+const (
+	// RefPrefix starts a string in a JSON config that references a secret by name.
+	RefPrefix = "secret://"
+
+	defaultRefreshInterval = 5 * time.Minute
+	// minRedactLen keeps short values such as "1" from masking unrelated log text.
+	minRedactLen = 6
+)
+
+var (
+	// ErrNotFound is returned by providers for unknown secrets.
+	ErrNotFound = errors.New("secret not found")
+	// ErrRefNotAllowed is returned by Resolve for references to secrets not allowed by WithRefs.
+	ErrRefNotAllowed = errors.New("secret may not be referenced from configs")
+)
+
+// Provider reads the current value of a secret.
+type Provider interface {
+	Get(ctx context.Context, name string) (string, error)
+}
+
+// ProviderFunc adapts a function to Provider.
+type ProviderFunc func(ctx context.Context, name string) (string, error)
+
+func (f ProviderFunc) Get(ctx context.Context, name string) (string, error) {
+	return f(ctx, name)
+}
+
+// Env reads secret `steam_api_key` from env `<prefix>STEAM_API_KEY`.
+func Env(prefix string) Provider {
+	replacer := strings.NewReplacer("-", "_", ".", "_", "/", "_")
+	return ProviderFunc(func(_ context.Context, name string) (string, error) {
+		v, ok := os.LookupEnv(prefix + strings.ToUpper(replacer.Replace(name)))
+		if !ok {
+			return "", ErrNotFound
+		}
+		return v, nil
+	})
+}
+
+// File reads secret `steam_api_key` from the file `<dir>/steam_api_key`, as mounted by
+// Docker and Kubernetes secrets. A trailing newline is removed.
+func File(dir string) Provider {
+	return ProviderFunc(func(_ context.Context, name string) (string, error) {
+		if name == "" || name != filepath.Base(name) || strings.HasPrefix(name, ".") {
+			return "", fmt.Errorf("invalid secret name %q", name)
+		}
+		data, err := os.ReadFile(filepath.Join(dir, name))
+		if errors.Is(err, os.ErrNotExist) {
+			return "", ErrNotFound
+		}
+		if err != nil {
+			return "", err
+		}
+		return strings.TrimRight(string(data), "\r\n"), nil
+	})
+}
+
+// Chain returns the value of the first provider that has the secret.
+func Chain(providers ...Provider) Provider {
+	return ProviderFunc(func(ctx context.Context, name string) (string, error) {
+		for _, p := range providers {
+			v, err := p.Get(ctx, name)
+			if !errors.Is(err, ErrNotFound) {
+				return v, err
+			}
+		}
+		return "", ErrNotFound
+	})
+}
+
+// Store caches secrets read from a provider and refreshes them between Start and Stop,
+// calling OnChange callbacks when a value rotates.
+type Store struct {
+	provider Provider
+	interval time.Duration
+	refs     map[string]bool
+
+	mu        sync.RWMutex
+	values    map[string]string
+	callbacks map[string][]func(ctx context.Context, value string)
+
+	cancel context.CancelFunc
+	done   chan struct{}
+}
+
+type Option func(*Store)
+
+// WithRefreshInterval sets how often secrets are read again, defaults to five minutes.
+func WithRefreshInterval(d time.Duration) Option {
+	return func(s *Store) {
+		if d > 0 {
+			s.interval = d
+		}
+	}
+}
+
+// WithRefs allows configs passed to Resolve to reference the secrets named names.
+// Without it no secret can be referenced, Get is not restricted.
+func WithRefs(names ...string) Option {
+	return func(s *Store) {
+		for _, name := range names {
+			s.refs[name] = true
+		}
+	}
+}
+
+func New(provider Provider, options ...Option) *Store {
+	s := &Store{
+		provider:  provider,
+		interval:  defaultRefreshInterval,
+		refs:      make(map[string]bool),
+		mu:        sync.RWMutex{},
+		values:    make(map[string]string),
+		callbacks: make(map[string][]func(context.Context, string)),
+		cancel:    nil,
+		done:      nil,
+	}
+	for _, o := range options {
+		o(s)
+	}
+	return s
+}
+
+// Get returns the cached value of a secret, reading it from the provider the first time.
+func (s *Store) Get(ctx context.Context, name string) (string, error) {
+	s.mu.RLock()
+	v, ok := s.values[name]
+	s.mu.RUnlock()
+	if ok {
+		return v, nil
+	}
+	v, err := s.provider.Get(ctx, name)
+	if err != nil {
+		return "", fmt.Errorf("secret %s: %w", name, err)
+	}
+	s.mu.Lock()
+	s.values[name] = v
+	s.mu.Unlock()
+	return v, nil
+}
+
+// OnChange calls f with the new value after the secret has rotated.
+func (s *Store) OnChange(name string, f func(ctx context.Context, value string)) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.callbacks[name] = append(s.callbacks[name], f)
+}
+
+// Refresh reads every cached secret again. Secrets that fail to read keep their value.
+func (s *Store) Refresh(ctx context.Context) error {
+	s.mu.RLock()
+	names := make([]string, 0, len(s.values))
+	for name := range s.values {
+		names = append(names, name)
+	}
+	s.mu.RUnlock()
+	var errs []error
+	for _, name := range names {
+		v, err := s.provider.Get(ctx, name)
+		if err != nil {
+			errs = append(errs, fmt.Errorf("secret %s: %w", name, err))
+			continue
+		}
+		s.mu.Lock()
+		changed := s.values[name] != v
+		s.values[name] = v
+		callbacks := s.callbacks[name]
+		s.mu.Unlock()
+		if changed {
+			for _, f := range callbacks {
+				f(ctx, v)
+			}
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// Start refreshes secrets periodically until Stop, it lets a Store run as a kratos server.
+func (s *Store) Start(context.Context) error {
+	ctx, cancel := context.WithCancel(context.Background())
+	s.mu.Lock()
+	s.cancel = cancel
+	s.done = make(chan struct{})
+	done := s.done
+	s.mu.Unlock()
+	go func() {
+		defer close(done)
+		ticker := time.NewTicker(s.interval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+				if err := s.Refresh(ctx); err != nil {
+					log.Context(ctx).Warnf("refresh secrets: %v", err)
+				}
+			}
+		}
+	}()
+	return nil
+}
+
+func (s *Store) Stop(ctx context.Context) error {
+	s.mu.Lock()
+	cancel, done := s.cancel, s.done
+	s.mu.Unlock()
+	if cancel == nil {
+		return nil
+	}
+	cancel()
+	select {
+	case <-done:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// Resolve replaces secret references at any depth of a JSON config with their values.
+// References to secrets not allowed by WithRefs fail with ErrRefNotAllowed.
+// Unknown and not allowed secrets are reported as errors.ConfigInvalid by the porter.
+func (s *Store) Resolve(ctx context.Context, config string) (string, error) {
+	if !strings.Contains(config, RefPrefix) {
+		return config, nil
+	}
+	dec := json.NewDecoder(strings.NewReader(config))
+	dec.UseNumber()
+	var data interface{}
+	if err := dec.Decode(&data); err != nil {
+		// invalid configs are left to the handler
+		return config, nil //nolint:nilerr // not a config to resolve
+	}
+	data, err := s.resolve(ctx, data)
+	if err != nil {
+		return "", err
+	}
+	b, err := json.Marshal(data)
+	if err != nil {
+		return "", err
+	}
+	return string(b), nil
+}
+
+func (s *Store) resolve(ctx context.Context, v interface{}) (interface{}, error) {
+	var err error
+	switch val := v.(type) {
+	case string:
+		if name, ok := strings.CutPrefix(val, RefPrefix); ok {
+			if !s.refs[name] {
+				return nil, fmt.Errorf("secret %s: %w", name, ErrRefNotAllowed)
+			}
+			return s.Get(ctx, name)
+		}
+	case map[string]interface{}:
+		for k, item := range val {
+			if val[k], err = s.resolve(ctx, item); err != nil {
+				return nil, err
+			}
+		}
+	case []interface{}:
+		for i, item := range val {
+			if val[i], err = s.resolve(ctx, item); err != nil {
+				return nil, err
+			}
+		}
+	}
+	return v, nil
+}
+
+// RedactLogger returns a logger masking the values of cached secrets in string log values.
+func (s *Store) RedactLogger(l log.Logger) log.Logger {
+	return &redactLogger{logger: l, store: s}
+}
+
+type redactLogger struct {
+	logger log.Logger
+	store  *Store
+}
+
+func (l *redactLogger) Log(level log.Level, keyvals ...interface{}) error {
+	l.store.mu.RLock()
+	var oldnew []string
+	for _, v := range l.store.values {
+		if len(v) >= minRedactLen {
+			oldnew = append(oldnew, v, redact.Mask)
+		}
+	}
+	l.store.mu.RUnlock()
+	if len(oldnew) == 0 {
+		return l.logger.Log(level, keyvals...)
+	}
+	r := strings.NewReplacer(oldnew...)
+	masked := make([]interface{}, len(keyvals))
+	for i, kv := range keyvals {
+		switch v := kv.(type) {
+		case string:
+			masked[i] = r.Replace(v)
+		case error:
+			masked[i] = r.Replace(v.Error())
+		case fmt.Stringer:
+			masked[i] = r.Replace(v.String())
+		default:
+			masked[i] = kv
+		}
+	}
+	return l.logger.Log(level, masked...)
+}
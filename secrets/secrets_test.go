@@ -0,0 +1,165 @@
+package secrets_test
+
+import (
+	"bytes"
+	"context"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/tuihub/tuihub-go/secrets"
+
+	"github.com/go-kratos/kratos/v2/log"
+	capi "github.com/hashicorp/consul/api"
+)
+
+func TestProviders(t *testing.T) {
+	ctx := context.Background()
+	t.Setenv("TEST_SECRET_STEAM_API_KEY", "from-env")
+	dir := t.TempDir()
+	if err := os.WriteFile(filepath.Join(dir, "telegram_bot_token"), []byte("from-file\n"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	p := secrets.Chain(secrets.Env("TEST_SECRET_"), secrets.File(dir))
+
+	for name, want := range map[string]string{
+		"steam_api_key":      "from-env",
+		"steam-api-key":      "from-env",
+		"telegram_bot_token": "from-file",
+	} {
+		if v, err := p.Get(ctx, name); err != nil || v != want {
+			t.Errorf("Get(%s) = %q, %v", name, v, err)
+		}
+	}
+	if _, err := p.Get(ctx, "missing"); !errors.Is(err, secrets.ErrNotFound) {
+		t.Errorf("missing: err = %v", err)
+	}
+	if _, err := secrets.File(dir).Get(ctx, "../etc/passwd"); err == nil || errors.Is(err, secrets.ErrNotFound) {
+		t.Errorf("path traversal: err = %v", err)
+	}
+}
+
+func TestRemoteProviders(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/v1/kv/porter/steam_api_key":
+			fmt.Fprintf(w, `[{"Key":"porter/steam_api_key","Value":%q}]`,
+				base64.StdEncoding.EncodeToString([]byte("from-consul")))
+		case "/v1/secret/data/steam":
+			if r.Header.Get("X-Vault-Token") != "root" {
+				w.WriteHeader(http.StatusForbidden)
+				return
+			}
+			fmt.Fprint(w, `{"data":{"data":{"api_key":"from-vault","value":"default"}}}`)
+		default:
+			w.WriteHeader(http.StatusNotFound)
+		}
+	}))
+	defer srv.Close()
+	ctx := context.Background()
+
+	client, err := capi.NewClient(&capi.Config{Address: strings.TrimPrefix(srv.URL, "http://")})
+	if err != nil {
+		t.Fatal(err)
+	}
+	consul := secrets.Consul(client, "porter/")
+	if v, err := consul.Get(ctx, "steam_api_key"); err != nil || v != "from-consul" {
+		t.Errorf("consul = %q, %v", v, err)
+	}
+	if _, err = consul.Get(ctx, "missing"); !errors.Is(err, secrets.ErrNotFound) {
+		t.Errorf("consul missing: err = %v", err)
+	}
+
+	vault := secrets.Vault(secrets.VaultConfig{Address: srv.URL, Token: "root", Mount: "", HTTPClient: nil})
+	for name, want := range map[string]string{"steam#api_key": "from-vault", "steam": "default"} {
+		if v, err := vault.Get(ctx, name); err != nil || v != want {
+			t.Errorf("vault %s = %q, %v", name, v, err)
+		}
+	}
+	for _, name := range []string{"steam#missing", "other"} {
+		if _, err = vault.Get(ctx, name); !errors.Is(err, secrets.ErrNotFound) {
+			t.Errorf("vault %s: err = %v", name, err)
+		}
+	}
+}
+
+func TestRotation(t *testing.T) {
+	var mu sync.Mutex
+	value := "token-1"
+	s := secrets.New(secrets.ProviderFunc(func(context.Context, string) (string, error) {
+		mu.Lock()
+		defer mu.Unlock()
+		return value, nil
+	}), secrets.WithRefreshInterval(5*time.Millisecond))
+	ctx := context.Background()
+	if v, _ := s.Get(ctx, "bot_token"); v != "token-1" {
+		t.Fatalf("Get = %q", v)
+	}
+	rotated := make(chan string, 1)
+	s.OnChange("bot_token", func(_ context.Context, v string) {
+		rotated <- v
+	})
+	_ = s.Start(ctx)
+	defer s.Stop(ctx)
+
+	mu.Lock()
+	value = "token-2"
+	mu.Unlock()
+	select {
+	case v := <-rotated:
+		if v != "token-2" {
+			t.Errorf("rotated to %q", v)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("rotation not noticed")
+	}
+	if v, _ := s.Get(ctx, "bot_token"); v != "token-2" {
+		t.Errorf("Get after rotation = %q", v)
+	}
+}
+
+func TestResolveAndRedact(t *testing.T) {
+	s := secrets.New(secrets.ProviderFunc(func(_ context.Context, name string) (string, error) {
+		if name == "steam_api_key" {
+			return "0123456789ABCDEF", nil
+		}
+		return "", secrets.ErrNotFound
+	}), secrets.WithRefs("steam_api_key", "missing"))
+	ctx := context.Background()
+
+	// large numbers survive the round trip
+	got, err := s.Resolve(ctx,
+		`{"api_key":"secret://steam_api_key","ids":[76561198000000000],"nested":[{"k":"secret://steam_api_key"}]}`)
+	want := `{"api_key":"0123456789ABCDEF","ids":[76561198000000000],"nested":[{"k":"0123456789ABCDEF"}]}`
+	if err != nil || got != want {
+		t.Errorf("Resolve = %s, %v", got, err)
+	}
+	if got, _ = s.Resolve(ctx, `{"url":"x"}`); got != `{"url":"x"}` {
+		t.Errorf("Resolve without references = %s", got)
+	}
+	if _, err = s.Resolve(ctx, `{"token":"secret://missing"}`); !errors.Is(err, secrets.ErrNotFound) {
+		t.Errorf("unknown secret: err = %v", err)
+	}
+	// with secrets.Env("") any env var could be referenced
+	_, err = s.Resolve(ctx, `{"api_key":"secret://steam_api_key","path":"secret://PATH"}`)
+	if !errors.Is(err, secrets.ErrRefNotAllowed) {
+		t.Errorf("unlisted secret: err = %v", err)
+	}
+
+	var buf bytes.Buffer
+	l := s.RedactLogger(log.NewStdLogger(&buf))
+	_ = l.Log(log.LevelError,
+		"msg", "GET /api?key=0123456789ABCDEF failed",
+		"error", errors.New("bad key 0123456789ABCDEF"))
+	if strings.Contains(buf.String(), "0123456789ABCDEF") {
+		t.Errorf("secret logged: %s", buf.String())
+	}
+}
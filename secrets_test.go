@@ -0,0 +1,51 @@
+package tuihub
+
+import (
+	"context"
+	"testing"
+
+	pb "github.com/tuihub/protos/pkg/librarian/porter/v1"
+	librarian "github.com/tuihub/protos/pkg/librarian/v1"
+	"github.com/tuihub/tuihub-go/errors"
+	"github.com/tuihub/tuihub-go/secrets"
+)
+
+func TestServerSecrets(t *testing.T) {
+	store := secrets.New(secrets.ProviderFunc(func(_ context.Context, name string) (string, error) {
+		switch name {
+		case "rss_token":
+			return "resolved-token", nil
+		case "telegram_token":
+			return "unlisted-token", nil
+		}
+		return "", secrets.ErrNotFound
+	}), secrets.WithRefs("rss_token", "unknown"))
+	var seen string
+	handler := serverSecrets(store)(func(_ context.Context, req interface{}) (interface{}, error) {
+		seen = req.(*pb.PullFeedRequest).GetSource().GetConfigJson() //nolint:errcheck // test request
+		return nil, nil
+	})
+	ctx := context.Background()
+
+	req := &pb.PullFeedRequest{Source: &librarian.FeatureRequest{Id: "rss", ConfigJson: `{"token":"secret://rss_token"}`}}
+	if _, err := handler(ctx, req); err != nil {
+		t.Fatal(err)
+	}
+	if seen != `{"token":"resolved-token"}` {
+		t.Errorf("handler config = %s", seen)
+	}
+	if req.GetSource().GetConfigJson() != `{"token":"secret://rss_token"}` {
+		t.Errorf("request modified: %s", req.GetSource().GetConfigJson())
+	}
+
+	req.Source.ConfigJson = `{"token":"secret://unknown"}`
+	if _, err := handler(ctx, req); !errors.IsConfigInvalid(err) {
+		t.Errorf("unknown secret: err = %v", err)
+	}
+
+	seen = ""
+	req.Source.ConfigJson = `{"token":"secret://telegram_token"}`
+	if _, err := handler(ctx, req); !errors.IsConfigInvalid(err) || seen != "" {
+		t.Errorf("unlisted secret: err = %v, handler config = %s", err, seen)
+	}
+}
@@ -0,0 +1,71 @@
+// Package tuihubtest runs a porter in process for handler tests.
+package tuihubtest
+
+import (
+	"context"
+	"net"
+	"testing"
+
+	porter "github.com/tuihub/protos/pkg/librarian/porter/v1"
+	"github.com/tuihub/tuihub-go"
+
+	"github.com/go-kratos/kratos/v2/log"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/credentials/insecure"
+	"google.golang.org/grpc/test/bufconn"
+)
+
+const bufSize = 1 << 20
+
+// Harness serves a porter over an in-memory connection, acting as the enabling Sephirah.
+type Harness struct {
+	porter.LibrarianPorterServiceClient
+}
+
+// New starts service in process and enables it, so calls on the harness reach the handler through
+// the same checks as in production. The porter is stopped when the test finishes.
+func New(
+	tb testing.TB,
+	info *porter.GetPorterInformationResponse,
+	service porter.LibrarianPorterServiceServer,
+) *Harness {
+	tb.Helper()
+	lis := bufconn.Listen(bufSize)
+	srv, err := tuihub.ServeLocal(info, service, lis, log.NewStdLogger(testWriter{tb}))
+	if err != nil {
+		tb.Fatal(err)
+	}
+	conn, err := grpc.NewClient(
+		"passthrough:///bufnet",
+		grpc.WithContextDialer(func(ctx context.Context, _ string) (net.Conn, error) {
+			return lis.DialContext(ctx)
+		}),
+		grpc.WithTransportCredentials(insecure.NewCredentials()),
+	)
+	if err != nil {
+		tb.Fatal(err)
+	}
+	tb.Cleanup(func() {
+		_ = conn.Close()
+		srv.Server.Stop()
+	})
+	h := &Harness{
+		LibrarianPorterServiceClient: porter.NewLibrarianPorterServiceClient(conn),
+	}
+	if _, err = h.EnablePorter(context.Background(), &porter.EnablePorterRequest{
+		SephirahId:   1,
+		RefreshToken: nil,
+	}); err != nil {
+		tb.Fatal(err)
+	}
+	return h
+}
+
+type testWriter struct {
+	tb testing.TB
+}
+
+func (w testWriter) Write(p []byte) (int, error) {
+	w.tb.Log(string(p))
+	return len(p), nil
+}
@@ -0,0 +1,101 @@
+package tuihubtest_test
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	porter "github.com/tuihub/protos/pkg/librarian/porter/v1"
+	librarian "github.com/tuihub/protos/pkg/librarian/v1"
+	"github.com/tuihub/tuihub-go/tuihubtest"
+
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+type feedHandler struct {
+	porter.UnimplementedLibrarianPorterServiceServer
+}
+
+func (feedHandler) PullFeed(_ context.Context, req *porter.PullFeedRequest) (*porter.PullFeedResponse, error) {
+	switch req.GetSource().GetConfigJson() {
+	case "panic":
+		panic("feed handler bug")
+	case "fail":
+		return nil, errors.New("upstream returned garbage")
+	}
+	return &porter.PullFeedResponse{
+		Data: &librarian.Feed{
+			Title: req.GetSource().GetConfigJson(),
+		},
+	}, nil
+}
+
+func info() *porter.GetPorterInformationResponse {
+	return &porter.GetPorterInformationResponse{
+		BinarySummary: &librarian.PorterBinarySummary{Name: "test"},
+		GlobalName:    "github.com/tuihub/tuihub-go/tuihubtest",
+		FeatureSummary: &librarian.FeatureSummary{
+			FeedSources: []*librarian.FeatureFlag{{Id: "rss", Name: "RSS"}},
+		},
+	}
+}
+
+func TestHarnessPullFeed(t *testing.T) {
+	h := tuihubtest.New(t, info(), feedHandler{})
+	ctx := context.Background()
+
+	resp, err := h.PullFeed(ctx, &porter.PullFeedRequest{
+		Source: &librarian.FeatureRequest{Id: "rss", ConfigJson: `{"url":"x"}`},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if resp.GetData().GetTitle() != `{"url":"x"}` {
+		t.Errorf("title = %q", resp.GetData().GetTitle())
+	}
+
+	_, err = h.PullFeed(ctx, &porter.PullFeedRequest{
+		Source: &librarian.FeatureRequest{Id: "atom"},
+	})
+	if status.Code(err) != codes.InvalidArgument {
+		t.Errorf("unsupported source: err = %v", err)
+	}
+
+	_, err = h.PullAccount(ctx, &porter.PullAccountRequest{
+		AccountId: &librarian.AccountID{Platform: "steam", PlatformAccountId: "1"},
+	})
+	if status.Code(err) != codes.InvalidArgument {
+		t.Errorf("unsupported platform: err = %v", err)
+	}
+}
+
+func TestHarnessHandlerErrors(t *testing.T) {
+	h := tuihubtest.New(t, info(), feedHandler{})
+	ctx := context.Background()
+	for _, config := range []string{"panic", "fail"} {
+		_, err := h.PullFeed(ctx, &porter.PullFeedRequest{
+			Source: &librarian.FeatureRequest{Id: "rss", ConfigJson: config},
+		})
+		if status.Code(err) != codes.Internal {
+			t.Errorf("%s: err = %v, want Internal", config, err)
+		}
+	}
+	// the porter survives the panic
+	if _, err := h.PullFeed(ctx, &porter.PullFeedRequest{
+		Source: &librarian.FeatureRequest{Id: "rss", ConfigJson: "{}"},
+	}); err != nil {
+		t.Errorf("after panic: err = %v", err)
+	}
+}
+
+func TestHarnessGetPorterInformation(t *testing.T) {
+	h := tuihubtest.New(t, info(), feedHandler{})
+	resp, err := h.GetPorterInformation(context.Background(), new(porter.GetPorterInformationRequest))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if resp.GetGlobalName() != info().GetGlobalName() {
+		t.Errorf("global name = %q", resp.GetGlobalName())
+	}
+}
@@ -0,0 +1,134 @@
+package tuihub
+
+import (
+	"context"
+	stderrors "errors"
+	"fmt"
+	"strings"
+
+	"github.com/tuihub/tuihub-go/errors"
+
+	validatepb "buf.build/gen/go/bufbuild/protovalidate/protocolbuffers/go/buf/validate"
+	"github.com/bufbuild/protovalidate-go"
+	"github.com/go-kratos/kratos/v2/middleware"
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/reflect/protoreflect"
+)
+
+const maxSearchAppInfoNameLen = 256
+
+// porterFieldConstraints are rules for porter requests that the protos do not declare yet,
+// applied in addition to the buf.validate rules of the protos.
+var porterFieldConstraints = map[protoreflect.FullName]*validatepb.FieldConstraints{ //nolint:gochecknoglobals // constant rules
+	"librarian.porter.v1.PullAccountRequest.account_id": {
+		Required: true,
+		Cel:      []*validatepb.Constraint{accountIDConstraint()},
+	},
+	"librarian.porter.v1.PullAppInfoRequest.app_info_id": {
+		Required: true,
+		Cel: []*validatepb.Constraint{{
+			Id:         "app_info_id.external",
+			Message:    "must be an external app id with source and source_app_id",
+			Expression: "!this.internal && this.source != '' && this.source_app_id != ''",
+		}},
+	},
+	"librarian.porter.v1.PullAccountAppInfoRelationRequest.account_id": {
+		Required: true,
+		Cel:      []*validatepb.Constraint{accountIDConstraint()},
+	},
+	"librarian.porter.v1.PullAccountAppInfoRelationRequest.relation_type": {
+		Type: &validatepb.FieldConstraints_Enum{Enum: &validatepb.EnumRules{
+			DefinedOnly: proto.Bool(true),
+			NotIn:       []int32{0},
+		}},
+	},
+	"librarian.porter.v1.SearchAppInfoRequest.name": {
+		Type: &validatepb.FieldConstraints_String_{String_: &validatepb.StringRules{
+			MinLen: proto.Uint64(1),
+			MaxLen: proto.Uint64(maxSearchAppInfoNameLen),
+		}},
+	},
+	"librarian.porter.v1.PullFeedRequest.source":           {Required: true},
+	"librarian.porter.v1.PushFeedItemsRequest.destination": {Required: true},
+	"librarian.porter.v1.ExecFeedItemActionRequest.action": {Required: true},
+}
+
+func accountIDConstraint() *validatepb.Constraint {
+	return &validatepb.Constraint{
+		Id:         "account_id.complete",
+		Message:    "must have platform and platform_account_id",
+		Expression: "this.platform != '' && this.platform_account_id != ''",
+	}
+}
+
+type porterConstraintResolver struct {
+	protovalidate.StandardConstraintResolver
+}
+
+func (r porterConstraintResolver) ResolveFieldConstraints(
+	desc protoreflect.FieldDescriptor,
+) *validatepb.FieldConstraints {
+	if c, ok := porterFieldConstraints[desc.FullName()]; ok {
+		return c
+	}
+	return r.StandardConstraintResolver.ResolveFieldConstraints(desc)
+}
+
+func newValidator() (*protovalidate.Validator, error) {
+	return protovalidate.New(
+		protovalidate.WithStandardConstraintInterceptor(
+			func(res protovalidate.StandardConstraintResolver) protovalidate.StandardConstraintResolver {
+				return porterConstraintResolver{res}
+			},
+		),
+	)
+}
+
+// serverValidation validates requests and replies against buf.validate rules.
+// Invalid requests fail with InvalidArgument and invalid replies with Internal,
+// both carrying violated field paths and messages as metadata.
+func serverValidation() middleware.Middleware {
+	v, err := newValidator()
+	return func(handler middleware.Handler) middleware.Handler {
+		return func(ctx context.Context, req interface{}) (interface{}, error) {
+			if err != nil {
+				return nil, errors.Internal("create validator: %s", err.Error()).WithCause(err)
+			}
+			if msg, ok := req.(proto.Message); ok {
+				if verr := v.Validate(msg); verr != nil {
+					return nil, validationError(errors.InvalidArgument, "invalid request", verr)
+				}
+			}
+			reply, herr := handler(ctx, req)
+			if herr != nil {
+				return reply, herr
+			}
+			if msg, ok := reply.(proto.Message); ok {
+				if verr := v.Validate(msg); verr != nil {
+					return nil, validationError(errors.Internal, "invalid reply", verr)
+				}
+			}
+			return reply, nil
+		}
+	}
+}
+
+// validationError converts violations to an error created by newError,
+// other validation failures such as rule compilation errors are Internal.
+func validationError(
+	newError func(format string, a ...interface{}) *errors.Error,
+	msg string,
+	err error,
+) *errors.Error {
+	var ve *protovalidate.ValidationError
+	if !stderrors.As(err, &ve) {
+		return errors.Internal("%s: %s", msg, err.Error()).WithCause(err)
+	}
+	md := make(map[string]string, len(ve.Violations))
+	details := make([]string, 0, len(ve.Violations))
+	for _, violation := range ve.Violations {
+		md[violation.GetFieldPath()] = violation.GetMessage()
+		details = append(details, fmt.Sprintf("%s: %s", violation.GetFieldPath(), violation.GetMessage()))
+	}
+	return newError("%s: %s", msg, strings.Join(details, "; ")).WithMetadata(md).WithCause(err)
+}
@@ -0,0 +1,67 @@
+package tuihub
+
+import (
+	"context"
+	"strings"
+	"testing"
+
+	pb "github.com/tuihub/protos/pkg/librarian/porter/v1"
+	librarian "github.com/tuihub/protos/pkg/librarian/v1"
+	"github.com/tuihub/tuihub-go/errors"
+
+	"google.golang.org/grpc/codes"
+	"google.golang.org/protobuf/proto"
+)
+
+func TestServerValidation(t *testing.T) {
+	tests := []struct {
+		name  string
+		req   proto.Message
+		reply proto.Message
+		want  codes.Code
+		field string
+	}{
+		{"pull feed", &pb.PullFeedRequest{Source: &librarian.FeatureRequest{Id: "rss"}}, nil, codes.OK, ""},
+		{"pull feed without source", new(pb.PullFeedRequest), nil, codes.InvalidArgument, "source"},
+		{"search empty name", new(pb.SearchAppInfoRequest), nil, codes.InvalidArgument, "name"},
+		{"search long name", &pb.SearchAppInfoRequest{Name: strings.Repeat("a", maxSearchAppInfoNameLen+1)}, nil,
+			codes.InvalidArgument, "name"},
+		{"incomplete account id", &pb.PullAccountRequest{
+			AccountId: &librarian.AccountID{Platform: "steam"},
+		}, nil, codes.InvalidArgument, "account_id"},
+		{"internal app id", &pb.PullAppInfoRequest{
+			AppInfoId: &librarian.AppInfoID{Internal: true, Source: "steam", SourceAppId: "1"},
+		}, nil, codes.InvalidArgument, "app_info_id"},
+		{"unspecified relation", &pb.PullAccountAppInfoRelationRequest{
+			AccountId: &librarian.AccountID{Platform: "steam", PlatformAccountId: "1"},
+		}, nil, codes.InvalidArgument, "relation_type"},
+		{"relation", &pb.PullAccountAppInfoRelationRequest{
+			RelationType: librarian.AccountAppRelationType_ACCOUNT_APP_RELATION_TYPE_OWN,
+			AccountId:    &librarian.AccountID{Platform: "steam", PlatformAccountId: "1"},
+		}, nil, codes.OK, ""},
+		{"invalid request skips reply", new(pb.SearchAppInfoRequest), &librarian.PagingResponse{TotalSize: -1},
+			codes.InvalidArgument, "name"},
+		{"invalid reply", &pb.SearchAppInfoRequest{Name: "a"}, &librarian.PagingResponse{TotalSize: -1},
+			codes.Internal, "total_size"},
+	}
+	for _, tt := range tests {
+		called := false
+		h := serverValidation()(func(context.Context, interface{}) (interface{}, error) {
+			called = true
+			return tt.reply, nil
+		})
+		_, err := h(context.Background(), tt.req)
+		if got := errors.Code(err); got != tt.want {
+			t.Errorf("%s: code = %v, want %v (%v)", tt.name, got, tt.want, err)
+			continue
+		}
+		if tt.want == codes.InvalidArgument && called {
+			t.Errorf("%s: handler called with invalid request", tt.name)
+		}
+		if tt.field != "" {
+			if _, ok := errors.FromError(err).GetMetadata()[tt.field]; !ok {
+				t.Errorf("%s: metadata = %v, want field %s", tt.name, errors.FromError(err).GetMetadata(), tt.field)
+			}
+		}
+	}
+}
@@ -0,0 +1,269 @@
+// Package webhook is a notify destination posting pushed feed items to a configured URL.
+//
+// Mount a Server with NotifyDestination in the feature summary of a porter:
+//
+//	FeatureSummary: &librarian.FeatureSummary{
+//		NotifyDestinations: []*librarian.FeatureFlag{webhook.NotifyDestination()},
+//	}
+package webhook
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+	"text/template"
+	"time"
+
+	porter "github.com/tuihub/protos/pkg/librarian/porter/v1"
+	librarian "github.com/tuihub/protos/pkg/librarian/v1"
+	"github.com/tuihub/tuihub-go"
+	"github.com/tuihub/tuihub-go/errors"
+
+	"google.golang.org/protobuf/encoding/protojson"
+	"google.golang.org/protobuf/proto"
+)
+
+const (
+	// DestinationID is the id of the notify destination.
+	DestinationID = "webhook"
+
+	SignatureHeader = "X-Tuihub-Signature"
+	TimestampHeader = "X-Tuihub-Timestamp"
+
+	defaultBodyTemplate = `{"items":{{json .Items}}}`
+	defaultBackoff      = time.Second
+	defaultMaxBackoff   = 30 * time.Second
+	maxErrorBody        = 512
+)
+
+// Config is the config of the notify destination. Header values are logged unless their names
+// are added to ServerConfig.RedactConfigKeys, keep credentials in Secret where possible.
+type Config struct {
+	URL    string `json:"url" jsonschema:"title=URL,description=http or https URL to send items to,format=uri,pattern=^https?://"` //nolint:lll // struct tag
+	Method string `json:"method,omitempty" jsonschema:"enum=POST,enum=PUT,default=POST"`
+	// Headers are added to every request.
+	Headers map[string]string `json:"headers,omitempty"`
+	// BodyTemplate is a Go text/template executed with .Destination and .Items,
+	// `json` renders a value as JSON. The default posts `{"items":[...]}`.
+	BodyTemplate string `json:"body_template,omitempty" jsonschema:"description=Go template of the request body"`
+	ContentType  string `json:"content_type,omitempty" jsonschema:"default=application/json"`
+	// Secret signs requests with HMAC-SHA256 of `<timestamp>.<body>`, sent as SignatureHeader.
+	Secret     string `json:"secret,omitempty" jsonschema:"description=HMAC-SHA256 signing secret"`
+	MaxRetries int    `json:"max_retries,omitempty" jsonschema:"minimum=0,maximum=10,default=3"`
+}
+
+// NotifyDestination returns the feature flag to put in FeatureSummary.NotifyDestinations.
+func NotifyDestination() *librarian.FeatureFlag {
+	return &librarian.FeatureFlag{
+		Id:               DestinationID,
+		Name:             "Webhook",
+		Description:      "Send feed items to an HTTP endpoint",
+		ConfigJsonSchema: tuihub.MustReflectJSONSchema(new(Config)),
+		RequireContext:   false,
+		Extra:            nil,
+	}
+}
+
+// Server implements PushFeedItems for the webhook destination.
+type Server struct {
+	porter.UnimplementedLibrarianPorterServiceServer
+
+	client     *http.Client
+	backoff    time.Duration
+	maxBackoff time.Duration
+	now        func() time.Time
+}
+
+type Option func(*Server)
+
+// WithHTTPClient sets the client requests are sent with, defaults to http.DefaultClient.
+func WithHTTPClient(c *http.Client) Option {
+	return func(s *Server) {
+		s.client = c
+	}
+}
+
+// WithBackoff sets the delay before the first retry, doubled on every retry up to limit.
+// Defaults to one second and 30 seconds. Retry-After of the endpoint is honored within limit.
+func WithBackoff(initial, limit time.Duration) Option {
+	return func(s *Server) {
+		s.backoff = initial
+		s.maxBackoff = limit
+	}
+}
+
+func New(options ...Option) *Server {
+	s := &Server{
+		UnimplementedLibrarianPorterServiceServer: porter.UnimplementedLibrarianPorterServiceServer{},
+		client:     http.DefaultClient,
+		backoff:    defaultBackoff,
+		maxBackoff: defaultMaxBackoff,
+		now:        time.Now,
+	}
+	for _, o := range options {
+		o(s)
+	}
+	return s
+}
+
+type bodyData struct {
+	Destination string
+	Items       []*librarian.FeedItem
+}
+
+func (s *Server) PushFeedItems(ctx context.Context, req *porter.PushFeedItemsRequest) (
+	*porter.PushFeedItemsResponse, error) {
+	config, err := tuihub.DecodeConfig[Config](req.GetDestination().GetConfigJson())
+	if err != nil {
+		return nil, errors.ConfigInvalid("%s", err.Error())
+	}
+	tmpl := config.BodyTemplate
+	if tmpl == "" {
+		tmpl = defaultBodyTemplate
+	}
+	t, err := template.New("body").Funcs(template.FuncMap{"json": toJSON}).Parse(tmpl)
+	if err != nil {
+		return nil, errors.ConfigInvalid("body_template: %s", err.Error())
+	}
+	var body bytes.Buffer
+	if err = t.Execute(&body, bodyData{
+		Destination: req.GetDestination().GetId(),
+		Items:       req.GetItems(),
+	}); err != nil {
+		return nil, errors.ConfigInvalid("body_template: %s", err.Error())
+	}
+	if err = s.send(ctx, config, body.Bytes()); err != nil {
+		return nil, err
+	}
+	return new(porter.PushFeedItemsResponse), nil
+}
+
+// send posts body, retrying network errors, 429 and 5xx responses.
+func (s *Server) send(ctx context.Context, config *Config, body []byte) error {
+	delay := s.backoff
+	for attempt := 0; ; attempt++ {
+		retryAfter, err := s.do(ctx, config, body)
+		if err == nil {
+			return nil
+		}
+		if !errors.IsRetryable(err) || attempt >= config.MaxRetries {
+			return err
+		}
+		wait := delay
+		if retryAfter > wait {
+			wait = retryAfter
+		}
+		if wait > s.maxBackoff {
+			wait = s.maxBackoff
+		}
+		timer := time.NewTimer(wait)
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return ctx.Err()
+		case <-timer.C:
+		}
+		delay *= 2
+	}
+}
+
+// do sends one request and returns the Retry-After delay of a rejected request.
+func (s *Server) do(ctx context.Context, config *Config, body []byte) (time.Duration, error) {
+	req, err := http.NewRequestWithContext(ctx, config.Method, config.URL, bytes.NewReader(body))
+	if err != nil {
+		return 0, errors.ConfigInvalid("%s", err.Error())
+	}
+	for k, v := range config.Headers {
+		req.Header.Set(k, v)
+	}
+	req.Header.Set("Content-Type", config.ContentType)
+	if config.Secret != "" {
+		ts := strconv.FormatInt(s.now().Unix(), 10)
+		req.Header.Set(TimestampHeader, ts)
+		req.Header.Set(SignatureHeader, Sign(config.Secret, ts, body))
+	}
+	resp, err := s.client.Do(req)
+	if err != nil {
+		if ctx.Err() != nil {
+			return 0, ctx.Err()
+		}
+		return 0, errors.UpstreamUnavailable("webhook request failed: %s", redactURL(err, config.URL))
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode >= 200 && resp.StatusCode < 300 {
+		return 0, nil
+	}
+	msg, _ := io.ReadAll(io.LimitReader(resp.Body, maxErrorBody))
+	retryAfter := parseRetryAfter(resp.Header.Get("Retry-After"), s.now())
+	switch {
+	case resp.StatusCode == http.StatusTooManyRequests:
+		return retryAfter, errors.RateLimited(retryAfter, "webhook responded %s", resp.Status)
+	case resp.StatusCode >= http.StatusInternalServerError:
+		return retryAfter, errors.UpstreamUnavailable("webhook responded %s: %s",
+			resp.Status, strings.TrimSpace(string(msg)))
+	default:
+		return 0, errors.InvalidArgument("webhook responded %s: %s", resp.Status, strings.TrimSpace(string(msg)))
+	}
+}
+
+// Sign returns the SignatureHeader value for a request body sent at timestamp ts, in Unix seconds.
+// Receivers recompute it to verify requests.
+func Sign(secret, ts string, body []byte) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write([]byte(ts))
+	mac.Write([]byte("."))
+	mac.Write(body)
+	return "sha256=" + hex.EncodeToString(mac.Sum(nil))
+}
+
+func parseRetryAfter(v string, now time.Time) time.Duration {
+	if v == "" {
+		return 0
+	}
+	if seconds, err := strconv.Atoi(v); err == nil && seconds > 0 {
+		return time.Duration(seconds) * time.Second
+	}
+	if t, err := http.ParseTime(v); err == nil && t.After(now) {
+		return t.Sub(now)
+	}
+	return 0
+}
+
+// redactURL removes the URL from err, webhook URLs often carry tokens.
+func redactURL(err error, url string) string {
+	return strings.ReplaceAll(err.Error(), url, "<webhook url>")
+}
+
+// toJSON renders feed items and other protobuf messages with protojson, other values with encoding/json.
+func toJSON(v interface{}) (string, error) {
+	switch val := v.(type) {
+	case proto.Message:
+		b, err := protojson.Marshal(val)
+		return string(b), err
+	case []*librarian.FeedItem:
+		items := make([]json.RawMessage, 0, len(val))
+		for _, item := range val {
+			b, err := protojson.Marshal(item)
+			if err != nil {
+				return "", err
+			}
+			items = append(items, b)
+		}
+		b, err := json.Marshal(items)
+		return string(b), err
+	default:
+		b, err := json.Marshal(val)
+		if err != nil {
+			return "", fmt.Errorf("json: %w", err)
+		}
+		return string(b), nil
+	}
+}
@@ -0,0 +1,176 @@
+package webhook
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	porter "github.com/tuihub/protos/pkg/librarian/porter/v1"
+	librarian "github.com/tuihub/protos/pkg/librarian/v1"
+	"github.com/tuihub/tuihub-go"
+	"github.com/tuihub/tuihub-go/errors"
+)
+
+type received struct {
+	header http.Header
+	body   string
+}
+
+// endpoint answers with statuses in order, then 200.
+func endpoint(t *testing.T, statuses ...int) (*httptest.Server, func() []received) {
+	t.Helper()
+	var (
+		mu  sync.Mutex
+		got []received
+	)
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		b, _ := io.ReadAll(r.Body)
+		mu.Lock()
+		got = append(got, received{header: r.Header.Clone(), body: string(b)})
+		n := len(got)
+		mu.Unlock()
+		if n <= len(statuses) {
+			if statuses[n-1] == http.StatusTooManyRequests {
+				w.Header().Set("Retry-After", "1")
+			}
+			w.WriteHeader(statuses[n-1])
+		}
+	}))
+	t.Cleanup(srv.Close)
+	return srv, func() []received {
+		mu.Lock()
+		defer mu.Unlock()
+		return append([]received(nil), got...)
+	}
+}
+
+func push(s *Server, config string) error {
+	_, err := s.PushFeedItems(context.Background(), &porter.PushFeedItemsRequest{
+		Destination: &librarian.FeatureRequest{Id: DestinationID, ConfigJson: config},
+		Items:       []*librarian.FeedItem{{Title: "a \"quoted\" title", Link: "https://example.com/1"}},
+	})
+	return err
+}
+
+func config(t *testing.T, c map[string]interface{}) string {
+	t.Helper()
+	b, err := json.Marshal(c)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return string(b)
+}
+
+func TestPushFeedItems(t *testing.T) {
+	srv, got := endpoint(t)
+	s := New(WithHTTPClient(srv.Client()))
+	s.now = func() time.Time { return time.Unix(1700000000, 0) }
+	err := push(s, config(t, map[string]interface{}{
+		"url":     srv.URL,
+		"headers": map[string]string{"X-Custom": "1"},
+		"secret":  "s3cret",
+	}))
+	if err != nil {
+		t.Fatal(err)
+	}
+	reqs := got()
+	if len(reqs) != 1 {
+		t.Fatalf("requests = %d", len(reqs))
+	}
+	r := reqs[0]
+	var body struct {
+		Items []struct {
+			Title string `json:"title"`
+		} `json:"items"`
+	}
+	if err = json.Unmarshal([]byte(r.body), &body); err != nil || body.Items[0].Title != `a "quoted" title` {
+		t.Errorf("body = %s, err = %v", r.body, err)
+	}
+	if r.header.Get("X-Custom") != "1" || r.header.Get("Content-Type") != "application/json" {
+		t.Errorf("headers = %v", r.header)
+	}
+	if r.header.Get(TimestampHeader) != "1700000000" ||
+		r.header.Get(SignatureHeader) != Sign("s3cret", "1700000000", []byte(r.body)) {
+		t.Errorf("signature headers = %v", r.header)
+	}
+}
+
+func TestPushFeedItemsTemplate(t *testing.T) {
+	srv, got := endpoint(t)
+	s := New(WithHTTPClient(srv.Client()))
+	err := push(s, config(t, map[string]interface{}{
+		"url":           srv.URL,
+		"method":        "PUT",
+		"content_type":  "text/plain",
+		"body_template": `{{.Destination}}:{{range .Items}}{{.Title}} {{json .Link}}{{end}}`,
+	}))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if body := got()[0].body; body != `webhook:a "quoted" title "https://example.com/1"` {
+		t.Errorf("body = %s", body)
+	}
+}
+
+func TestPushFeedItemsRetry(t *testing.T) {
+	tests := []struct {
+		name     string
+		statuses []int
+		retries  int
+		requests int
+		check    func(error) bool
+	}{
+		{"recovers", []int{http.StatusServiceUnavailable, http.StatusTooManyRequests}, 3, 3,
+			func(err error) bool { return err == nil }},
+		{"gives up", []int{http.StatusBadGateway, http.StatusBadGateway, http.StatusBadGateway}, 1, 2,
+			errors.IsRetryable},
+		{"rate limited", []int{http.StatusTooManyRequests}, 0, 1, errors.IsRateLimited},
+		{"client error", []int{http.StatusBadRequest}, 3, 1, errors.IsInvalidArgument},
+	}
+	for _, tt := range tests {
+		srv, got := endpoint(t, tt.statuses...)
+		s := New(WithHTTPClient(srv.Client()), WithBackoff(time.Millisecond, 10*time.Millisecond))
+		err := push(s, config(t, map[string]interface{}{"url": srv.URL, "max_retries": tt.retries}))
+		if !tt.check(err) {
+			t.Errorf("%s: err = %v", tt.name, err)
+		}
+		if n := len(got()); n != tt.requests {
+			t.Errorf("%s: requests = %d, want %d", tt.name, n, tt.requests)
+		}
+	}
+}
+
+func TestPushFeedItemsConfig(t *testing.T) {
+	s := New()
+	for _, c := range []string{
+		`{}`,
+		`{"url":"ftp://example.com"}`,
+		`{"url":"https://example.com","method":"GET"}`,
+		`{"url":"https://example.com","body_template":"{{"}`,
+	} {
+		if err := push(s, c); !errors.IsConfigInvalid(err) {
+			t.Errorf("%s: err = %v, want ConfigInvalid", c, err)
+		}
+	}
+	schema := NotifyDestination().GetConfigJsonSchema()
+	if err := tuihub.ValidateAgainstSchema(schema, `{"url":"https://example.com"}`); err != nil {
+		t.Errorf("schema rejects minimal config: %v", err)
+	}
+	if !strings.Contains(schema, `"secret"`) {
+		t.Errorf("schema = %s", schema)
+	}
+}
+
+func TestUnreachableURLNotLeaked(t *testing.T) {
+	s := New(WithBackoff(time.Millisecond, time.Millisecond))
+	err := push(s, `{"url":"http://127.0.0.1:1/hook?token=abc","max_retries":0}`)
+	if !errors.IsRetryable(err) || strings.Contains(err.Error(), "abc") {
+		t.Errorf("err = %v", err)
+	}
+}
@@ -0,0 +1,101 @@
+package tuihub
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+
+	librarian "github.com/tuihub/protos/pkg/librarian/v1"
+
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/reflect/protoreflect"
+)
+
+func WellKnownToString(e protoreflect.Enum) string {
+	return wellKnownValueToString(e.Descriptor().Values().ByNumber(e.Number()))
+}
+
+func wellKnownValueToString(v protoreflect.EnumValueDescriptor) string {
+	if v == nil {
+		return ""
+	}
+	return fmt.Sprint(proto.GetExtension(v.Options(), librarian.E_ToString))
+}
+
+// ParseWellKnown returns the enum value whose `to_string` name or enum value name is s.
+// Empty and unknown strings are errors.
+func ParseWellKnown[T protoreflect.Enum](s string) (T, error) {
+	var zero T
+	if s == "" {
+		return zero, fmt.Errorf("empty %s", zero.Descriptor().Name())
+	}
+	values := zero.Descriptor().Values()
+	for i := 0; i < values.Len(); i++ {
+		v := values.Get(i)
+		if wellKnownValueToString(v) == s || string(v.Name()) == s {
+			return zero.Type().New(v.Number()).(T), nil //nolint:errcheck // created from T
+		}
+	}
+	return zero, fmt.Errorf("unknown %s %q", zero.Descriptor().Name(), s)
+}
+
+// WellKnownValues lists all values of T that have a `to_string` name, in declaration order.
+// Unspecified values are skipped.
+func WellKnownValues[T protoreflect.Enum]() []T {
+	var zero T
+	values := zero.Descriptor().Values()
+	res := make([]T, 0, values.Len())
+	for i := 0; i < values.Len(); i++ {
+		v := values.Get(i)
+		if wellKnownValueToString(v) == "" {
+			continue
+		}
+		res = append(res, zero.Type().New(v.Number()).(T)) //nolint:errcheck // created from T
+	}
+	return res
+}
+
+// WellKnownStrings lists the `to_string` names of WellKnownValues, e.g. for UI dropdowns.
+func WellKnownStrings[T protoreflect.Enum]() []string {
+	values := WellKnownValues[T]()
+	res := make([]string, 0, len(values))
+	for _, v := range values {
+		res = append(res, WellKnownToString(v))
+	}
+	return res
+}
+
+// WellKnown wraps an enum to marshal it to JSON as its `to_string` name.
+type WellKnown[T protoreflect.Enum] struct {
+	Value T
+}
+
+func (w WellKnown[T]) String() string {
+	return WellKnownToString(w.Value)
+}
+
+func (w WellKnown[T]) MarshalJSON() ([]byte, error) {
+	s := WellKnownToString(w.Value)
+	if s == "" {
+		return nil, errors.New("well-known value has no string form")
+	}
+	return json.Marshal(s)
+}
+
+func (w *WellKnown[T]) UnmarshalJSON(b []byte) error {
+	var s string
+	if err := json.Unmarshal(b, &s); err != nil {
+		return err
+	}
+	v, err := ParseWellKnown[T](s)
+	if err != nil {
+		return err
+	}
+	w.Value = v
+	return nil
+}
+
+// jsonSchemaEnum lists allowed JSON values for schema reflection.
+func (w WellKnown[T]) jsonSchemaEnum() []string {
+	return WellKnownStrings[T]()
+}
@@ -0,0 +1,72 @@
+package tuihub
+
+import (
+	"encoding/json"
+	"reflect"
+	"testing"
+
+	librarian "github.com/tuihub/protos/pkg/librarian/v1"
+)
+
+func TestParseWellKnown(t *testing.T) {
+	tests := []struct {
+		in      string
+		want    librarian.WellKnownAppInfoSource
+		wantErr bool
+	}{
+		{"steam", librarian.WellKnownAppInfoSource_WELL_KNOWN_APP_INFO_SOURCE_STEAM, false},
+		{"bangumi", librarian.WellKnownAppInfoSource_WELL_KNOWN_APP_INFO_SOURCE_BANGUMI, false},
+		{"WELL_KNOWN_APP_INFO_SOURCE_VNDB", librarian.WellKnownAppInfoSource_WELL_KNOWN_APP_INFO_SOURCE_VNDB, false},
+		{"", librarian.WellKnownAppInfoSource_WELL_KNOWN_APP_INFO_SOURCE_UNSPECIFIED, true},
+		{"Steam", librarian.WellKnownAppInfoSource_WELL_KNOWN_APP_INFO_SOURCE_UNSPECIFIED, true},
+		{"rss", librarian.WellKnownAppInfoSource_WELL_KNOWN_APP_INFO_SOURCE_UNSPECIFIED, true},
+	}
+	for _, tt := range tests {
+		got, err := ParseWellKnown[librarian.WellKnownAppInfoSource](tt.in)
+		if (err != nil) != tt.wantErr {
+			t.Errorf("ParseWellKnown(%q) error = %v, wantErr %v", tt.in, err, tt.wantErr)
+		}
+		if got != tt.want {
+			t.Errorf("ParseWellKnown(%q) = %v, want %v", tt.in, got, tt.want)
+		}
+	}
+}
+
+func TestWellKnownStrings(t *testing.T) {
+	got := WellKnownStrings[librarian.WellKnownAppInfoSource]()
+	want := []string{"steam", "vndb", "bangumi"}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("WellKnownStrings = %v, want %v", got, want)
+	}
+	if n := len(WellKnownValues[librarian.WellKnownFeedSource]()); n != 1 {
+		t.Errorf("WellKnownValues[WellKnownFeedSource] has %d values, want 1", n)
+	}
+}
+
+func TestWellKnownJSON(t *testing.T) {
+	type config struct {
+		Source WellKnown[librarian.WellKnownFeedSource] `json:"source"`
+	}
+	b, err := json.Marshal(config{
+		Source: WellKnown[librarian.WellKnownFeedSource]{Value: librarian.WellKnownFeedSource_WELL_KNOWN_FEED_SOURCE_RSS},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(b) != `{"source":"rss"}` {
+		t.Errorf("marshal = %s", b)
+	}
+	var c config
+	if err = json.Unmarshal(b, &c); err != nil {
+		t.Fatal(err)
+	}
+	if c.Source.Value != librarian.WellKnownFeedSource_WELL_KNOWN_FEED_SOURCE_RSS {
+		t.Errorf("unmarshal = %v", c.Source.Value)
+	}
+	if err = json.Unmarshal([]byte(`{"source":"atom"}`), &c); err == nil {
+		t.Error("expected error for unknown source")
+	}
+	if _, err = json.Marshal(config{}); err == nil {
+		t.Error("expected error for unspecified source")
+	}
+}
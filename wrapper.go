@@ -2,19 +2,18 @@
 
 import (
 	"context"
-	"fmt"
 	"sync"
 	"time"
 
 	pb "github.com/tuihub/protos/pkg/librarian/porter/v1"
 	sephirah "github.com/tuihub/protos/pkg/librarian/sephirah/v1"
-	librarian "github.com/tuihub/protos/pkg/librarian/v1"
+	"github.com/tuihub/tuihub-go/errors"
+	tuihublogger "github.com/tuihub/tuihub-go/logger"
 
-	"github.com/go-kratos/kratos/v2/errors"
 	"github.com/go-kratos/kratos/v2/log"
 	"github.com/go-kratos/kratos/v2/middleware"
-	"github.com/go-kratos/kratos/v2/middleware/logging"
 	"github.com/go-kratos/kratos/v2/transport/grpc"
+	ggrpc "google.golang.org/grpc"
 )
 
 const (
@@ -72,7 +71,7 @@ func (s *serviceWrapper) EnablePorter(ctx context.Context, req *pb.EnablePorterR
 				}
 				return nil
 			} else if s.lastHeartbeat.Add(defaultHeartbeatTimeout).After(time.Now()) {
-				return fmt.Errorf("porter already enabled by %d", s.Token.enabler)
+				return errors.NotEnabled("porter already enabled by %d", s.Token.enabler)
 			}
 		}
 		s.Token = new(tokenInfo)
@@ -102,7 +101,7 @@ func (s *serviceWrapper) EnablePorter(ctx context.Context, req *pb.EnablePorterR
 	if err := f(); err != nil {
 		return nil, err
 	}
-	if resp, err := s.LibrarianPorterServiceServer.EnablePorter(ctx, req); isUnimplementedError(err) {
+	if resp, err := s.LibrarianPorterServiceServer.EnablePorter(ctx, req); errors.IsUnimplemented(err) {
 		return &pb.EnablePorterResponse{
 			StatusMessage:    "",
 			NeedRefreshToken: needRefreshToken,
@@ -114,15 +113,25 @@ func (s *serviceWrapper) EnablePorter(ctx context.Context, req *pb.EnablePorterR
 	}
 }
 func (s *serviceWrapper) Enabled() bool {
+	s.tokenMu.Lock()
+	defer s.tokenMu.Unlock()
 	return s.Token != nil
 }
 
-func NewServer(c *ServerConfig, service pb.LibrarianPorterServiceServer, logger log.Logger) *grpc.Server {
-	var middlewares = []middleware.Middleware{
-		logging.Server(logger),
+// accessToken returns the token issued by the enabling Sephirah, false while the porter is not enabled.
+func (s *serviceWrapper) accessToken() (string, bool) {
+	s.tokenMu.Lock()
+	defer s.tokenMu.Unlock()
+	if s.Token == nil {
+		return "", false
 	}
+	return s.Token.AccessToken, true
+}
+
+func NewServer(c *ServerConfig, service pb.LibrarianPorterServiceServer, logger log.Logger) *grpc.Server {
 	var opts = []grpc.ServerOption{
-		grpc.Middleware(middlewares...),
+		grpc.Middleware(serverMiddlewares(c, service, logger)...),
+		grpc.Options(ggrpc.StatsHandler(replySentHandler{})),
 	}
 	if c.Network != "" {
 		opts = append(opts, grpc.Network(c.Network))
@@ -135,11 +144,55 @@ func NewServer(c *ServerConfig, service pb.LibrarianPorterServiceServer, logger
 	} else {
 		opts = append(opts, grpc.Timeout(time.Minute))
 	}
+	opts = append(opts, c.GRPCOptions...)
 	srv := grpc.NewServer(opts...)
 	pb.RegisterLibrarianPorterServiceServer(srv, service)
 	return srv
 }
 
+// serverMiddlewares returns the middlewares shared by the gRPC server and the HTTP gateway.
+func serverMiddlewares(
+	c *ServerConfig,
+	service pb.LibrarianPorterServiceServer,
+	logger log.Logger,
+) []middleware.Middleware {
+	info, _ := service.GetPorterInformation(context.Background(), new(pb.GetPorterInformationRequest))
+	var middlewares = []middleware.Middleware{
+		tuihublogger.Server("porter", info.GetGlobalName()),
+		serverLogging(logger, c),
+		serverErrors(c.ErrorReporter),
+		serverRecovery(logger),
+		serverEnabled(service),
+		serverValidation(),
+		serverReplyCheck(logger, c.ReplyPolicy),
+	}
+	if c.Secrets != nil {
+		middlewares = append(middlewares, serverSecrets(c.Secrets))
+	}
+	return append(middlewares, c.Middlewares...)
+}
+
+// serverEnabled rejects calls of a porter that is not enabled before requests are validated.
+// GetPorterInformation and EnablePorter are always allowed.
+func serverEnabled(service pb.LibrarianPorterServiceServer) middleware.Middleware {
+	e, ok := service.(interface{ Enabled() bool })
+	return func(handler middleware.Handler) middleware.Handler {
+		if !ok {
+			return handler
+		}
+		return func(ctx context.Context, req interface{}) (interface{}, error) {
+			switch req.(type) {
+			case *pb.GetPorterInformationRequest, *pb.EnablePorterRequest:
+			default:
+				if !e.Enabled() {
+					return nil, errors.NotEnabled("Unauthorized caller")
+				}
+			}
+			return handler(ctx, req)
+		}
+	}
+}
+
 type serviceServer struct {
 	*serviceWrapper
 }
@@ -161,86 +214,67 @@ func (s *serviceServer) EnablePorter(ctx context.Context, req *pb.EnablePorterRe
 func (s *serviceServer) PullAccount(ctx context.Context, req *pb.PullAccountRequest) (
 	*pb.PullAccountResponse, error) {
 	if !s.serviceWrapper.Enabled() {
-		return nil, errors.Forbidden("Unauthorized caller", "")
-	}
-	if req.GetAccountId() == nil ||
-		req.GetAccountId().GetPlatform() == "" ||
-		req.GetAccountId().GetPlatformAccountId() == "" {
-		return nil, errors.BadRequest("Invalid account id", "")
+		return nil, errors.NotEnabled("Unauthorized caller")
 	}
 	for _, account := range s.serviceWrapper.Info.GetFeatureSummary().GetAccountPlatforms() {
 		if account.GetId() == req.GetAccountId().GetPlatform() {
 			return s.serviceWrapper.LibrarianPorterServiceServer.PullAccount(ctx, req)
 		}
 	}
-	return nil, errors.BadRequest("Unsupported account platform", "")
+	return nil, errors.Unsupported("Unsupported account platform")
 }
 func (s *serviceServer) PullAppInfo(ctx context.Context, req *pb.PullAppInfoRequest) (*pb.PullAppInfoResponse, error) {
 	if !s.serviceWrapper.Enabled() {
-		return nil, errors.Forbidden("Unauthorized caller", "")
-	}
-	if req.GetAppInfoId() == nil ||
-		req.GetAppInfoId().GetInternal() ||
-		req.GetAppInfoId().GetSource() == "" ||
-		req.GetAppInfoId().GetSourceAppId() == "" {
-		return nil, errors.BadRequest("Invalid app id", "")
+		return nil, errors.NotEnabled("Unauthorized caller")
 	}
 	for _, source := range s.serviceWrapper.Info.GetFeatureSummary().GetAppInfoSources() {
 		if source.GetId() == req.GetAppInfoId().GetSource() {
 			return s.serviceWrapper.LibrarianPorterServiceServer.PullAppInfo(ctx, req)
 		}
 	}
-	return nil, errors.BadRequest("Unsupported app source", "")
+	return nil, errors.Unsupported("Unsupported app source")
 }
 func (s *serviceServer) PullAccountAppInfoRelation(ctx context.Context, req *pb.PullAccountAppInfoRelationRequest) (
 	*pb.PullAccountAppInfoRelationResponse, error) {
 	if !s.serviceWrapper.Enabled() {
-		return nil, errors.Forbidden("Unauthorized caller", "")
-	}
-	if req.GetAccountId() == nil ||
-		req.GetRelationType() == librarian.AccountAppRelationType_ACCOUNT_APP_RELATION_TYPE_UNSPECIFIED ||
-		req.GetAccountId().GetPlatform() == "" || req.GetAccountId().GetPlatformAccountId() == "" {
-		return nil, errors.BadRequest("Invalid account id", "")
+		return nil, errors.NotEnabled("Unauthorized caller")
 	}
 	for _, account := range s.serviceWrapper.Info.GetFeatureSummary().GetAccountPlatforms() {
 		if account.GetId() == req.GetAccountId().GetPlatform() {
 			return s.serviceWrapper.LibrarianPorterServiceServer.PullAccountAppInfoRelation(ctx, req)
 		}
 	}
-	return nil, errors.BadRequest("Unsupported account", "")
+	return nil, errors.Unsupported("Unsupported account")
 }
 func (s *serviceServer) SearchAppInfo(ctx context.Context, req *pb.SearchAppInfoRequest) (*pb.SearchAppInfoResponse, error) {
 	if !s.serviceWrapper.Enabled() {
-		return nil, errors.Forbidden("Unauthorized caller", "")
-	}
-	if req.GetName() == "" {
-		return nil, errors.BadRequest("Invalid app name", "")
+		return nil, errors.NotEnabled("Unauthorized caller")
 	}
 	if len(s.serviceWrapper.Info.GetFeatureSummary().GetAppInfoSources()) > 0 {
 		return s.serviceWrapper.LibrarianPorterServiceServer.SearchAppInfo(ctx, req)
 	}
-	return nil, errors.BadRequest("Unsupported app source", "")
+	return nil, errors.Unsupported("Unsupported app source")
 }
 func (s *serviceServer) PullFeed(ctx context.Context, req *pb.PullFeedRequest) (*pb.PullFeedResponse, error) {
 	if !s.serviceWrapper.Enabled() {
-		return nil, errors.Forbidden("Unauthorized caller", "")
+		return nil, errors.NotEnabled("Unauthorized caller")
 	}
 	for _, source := range s.serviceWrapper.Info.GetFeatureSummary().GetFeedSources() {
 		if source.GetId() == req.GetSource().GetId() {
 			return s.serviceWrapper.LibrarianPorterServiceServer.PullFeed(ctx, req)
 		}
 	}
-	return nil, errors.BadRequest("Unsupported feed source", "")
+	return nil, errors.Unsupported("Unsupported feed source")
 }
 func (s *serviceServer) PushFeedItems(ctx context.Context, req *pb.PushFeedItemsRequest) (
 	*pb.PushFeedItemsResponse, error) {
 	if !s.serviceWrapper.Enabled() {
-		return nil, errors.Forbidden("Unauthorized caller", "")
+		return nil, errors.NotEnabled("Unauthorized caller")
 	}
 	for _, destination := range s.serviceWrapper.Info.GetFeatureSummary().GetNotifyDestinations() {
 		if destination.GetId() == req.GetDestination().GetId() {
 			return s.serviceWrapper.LibrarianPorterServiceServer.PushFeedItems(ctx, req)
 		}
 	}
-	return nil, errors.BadRequest("Unsupported notify destination", "")
+	return nil, errors.Unsupported("Unsupported notify destination")
 }
@@ -0,0 +1,147 @@
+package tuihub
+
+import (
+	"context"
+	"net"
+	"sync"
+	"testing"
+	"time"
+
+	pb "github.com/tuihub/protos/pkg/librarian/porter/v1"
+	librarian "github.com/tuihub/protos/pkg/librarian/v1"
+	"github.com/tuihub/tuihub-go/errors"
+
+	"github.com/go-kratos/kratos/v2/log"
+	"github.com/go-kratos/kratos/v2/middleware"
+	"github.com/go-kratos/kratos/v2/middleware/selector"
+	"github.com/go-kratos/kratos/v2/transport"
+	kgrpc "github.com/go-kratos/kratos/v2/transport/grpc"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/credentials/insecure"
+	"google.golang.org/grpc/test/bufconn"
+)
+
+type testHandler struct {
+	pb.UnimplementedLibrarianPorterServiceServer
+}
+
+func (testHandler) PullFeed(context.Context, *pb.PullFeedRequest) (*pb.PullFeedResponse, error) {
+	return new(pb.PullFeedResponse), nil
+}
+
+func (testHandler) PushFeedItems(context.Context, *pb.PushFeedItemsRequest) (*pb.PushFeedItemsResponse, error) {
+	return new(pb.PushFeedItemsResponse), nil
+}
+
+// newTestService wraps testHandler like NewPorter, optionally enabled by Sephirah 1.
+func newTestService(enabled bool) pb.LibrarianPorterServiceServer {
+	info := &pb.GetPorterInformationResponse{
+		BinarySummary: &librarian.PorterBinarySummary{Name: "test"},
+		GlobalName:    "test",
+		FeatureSummary: &librarian.FeatureSummary{
+			FeedSources:        []*librarian.FeatureFlag{{Id: "rss"}},
+			NotifyDestinations: []*librarian.FeatureFlag{{Id: "telegram"}},
+		},
+	}
+	var token *tokenInfo
+	if enabled {
+		token = &tokenInfo{enabler: 1, AccessToken: "", refreshToken: ""}
+	}
+	return NewService(&serviceWrapper{
+		LibrarianPorterServiceServer: testHandler{},
+		Info:                         info,
+		Logger:                       log.DefaultLogger,
+		Client:                       nil,
+		RequireToken:                 false,
+		Token:                        token,
+		tokenMu:                      sync.Mutex{},
+		lastHeartbeat:                time.Now(),
+		lastRefreshToken:             time.Time{},
+	})
+}
+
+// dialTestServer serves srv over an in-memory connection.
+func dialTestServer(t *testing.T, srv *kgrpc.Server) pb.LibrarianPorterServiceClient {
+	t.Helper()
+	lis := bufconn.Listen(1 << 20)
+	go func() {
+		_ = srv.Server.Serve(lis)
+	}()
+	t.Cleanup(srv.Server.Stop)
+	conn, err := grpc.NewClient(
+		"passthrough:///bufnet",
+		grpc.WithContextDialer(func(ctx context.Context, _ string) (net.Conn, error) {
+			return lis.DialContext(ctx)
+		}),
+		grpc.WithTransportCredentials(insecure.NewCredentials()),
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { _ = conn.Close() })
+	return pb.NewLibrarianPorterServiceClient(conn)
+}
+
+func TestNewServerMiddlewares(t *testing.T) {
+	var (
+		mu      sync.Mutex
+		audited []string
+	)
+	audit := func(handler middleware.Handler) middleware.Handler {
+		return func(ctx context.Context, req interface{}) (interface{}, error) {
+			if tr, ok := transport.FromServerContext(ctx); ok {
+				mu.Lock()
+				audited = append(audited, tr.Operation())
+				mu.Unlock()
+			}
+			return handler(ctx, req)
+		}
+	}
+	c := defaultServerConfig()
+	c.Middlewares = []middleware.Middleware{
+		selector.Server(audit).Path(pb.LibrarianPorterService_PushFeedItems_FullMethodName).Build(),
+	}
+	client := dialTestServer(t, NewServer(c, newTestService(true), log.DefaultLogger))
+
+	ctx := context.Background()
+	if _, err := client.PullFeed(ctx, &pb.PullFeedRequest{Source: &librarian.FeatureRequest{Id: "rss"}}); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := client.PushFeedItems(ctx, &pb.PushFeedItemsRequest{
+		Destination: &librarian.FeatureRequest{Id: "telegram"},
+	}); err != nil {
+		t.Fatal(err)
+	}
+	mu.Lock()
+	defer mu.Unlock()
+	if len(audited) != 1 || audited[0] != pb.LibrarianPorterService_PushFeedItems_FullMethodName {
+		t.Errorf("audited = %v", audited)
+	}
+}
+
+func TestServerMiddlewaresNotEnabled(t *testing.T) {
+	service := newTestService(false)
+	handler := middleware.Chain(serverMiddlewares(defaultServerConfig(), service, log.DefaultLogger)...)(
+		func(ctx context.Context, req interface{}) (interface{}, error) {
+			return service.PullFeed(ctx, req.(*pb.PullFeedRequest)) //nolint:errcheck // test request
+		})
+	// the request is invalid, but the caller must enable the porter first
+	_, err := handler(context.Background(), new(pb.PullFeedRequest))
+	if !errors.IsPermissionDenied(err) {
+		t.Errorf("err = %v, want PermissionDenied", err)
+	}
+}
+
+func TestWithServerConfig(t *testing.T) {
+	c := &ServerConfig{Addr: ":1", LogRequest: true}
+	p := new(Porter)
+	WithServerConfig(c)(p)
+	WithMiddleware(func(h middleware.Handler) middleware.Handler { return h })(p)
+	c.Addr = ":2"
+	if p.serverConfig.Addr != ":1" {
+		t.Errorf("config not copied: %q", p.serverConfig.Addr)
+	}
+	if len(p.middlewares) != 1 {
+		t.Errorf("middlewares = %d", len(p.middlewares))
+	}
+}